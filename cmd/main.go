@@ -12,16 +12,28 @@
 package main
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // expense time zones resolve without tzdata in the image
 
 	_ "github.com/IvanLouren/GoSplit/docs"
+	"github.com/IvanLouren/GoSplit/internal/activity"
+	"github.com/IvanLouren/GoSplit/internal/allocations"
+	"github.com/IvanLouren/GoSplit/internal/attachments"
 	"github.com/IvanLouren/GoSplit/internal/auth"
 	"github.com/IvanLouren/GoSplit/internal/balances"
+	"github.com/IvanLouren/GoSplit/internal/categories"
+	"github.com/IvanLouren/GoSplit/internal/comments"
 	"github.com/IvanLouren/GoSplit/internal/expenses"
 	"github.com/IvanLouren/GoSplit/internal/groups"
+	"github.com/IvanLouren/GoSplit/internal/rates"
 	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/internal/settleup"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/internal/trash"
 	"github.com/IvanLouren/GoSplit/internal/users"
 	"github.com/IvanLouren/GoSplit/pkg/database"
 	"github.com/IvanLouren/GoSplit/pkg/middleware"
@@ -35,6 +47,55 @@ func main() {
 
 	database.Connect()
 
+	port := os.Getenv("APP_PORT")
+	if port == "" {
+		port = "8080"
+	}
+	blobStore, err := attachments.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	retentionDays, err := trash.RetentionFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	autoConfirmDays, err := settlements.AutoConfirmFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	shared := newServices(database.DB, blobStore, retentionDays)
+
+	// purge the trash of every group once an hour
+	go shared.trash.RunRetention(time.Hour)
+	// confirm the settlements left pending too long once an hour
+	go shared.settlements.RunAutoConfirm(autoConfirmDays, time.Hour)
+
+	log.Println("server starting on :" + port)
+	log.Fatal(http.ListenAndServe(":"+port, newRouter(database.DB, shared)))
+}
+
+// services are the services both the routes and the background jobs use,
+// built once so they are configured the same way.
+type services struct {
+	expenses       *expenses.Service
+	settlements    *settlements.Service
+	attachments    *attachments.Service
+	attachmentURLs *attachments.URLSigner
+	trash          *trash.Service
+}
+
+func newServices(db *sql.DB, blobStore attachments.BlobStore, retentionDays int) *services {
+	s := &services{
+		expenses:       expenses.NewService(db),
+		settlements:    settlements.NewService(db),
+		attachmentURLs: attachments.NewURLSigner(os.Getenv("JWT_SECRET"), attachments.URLTTL),
+	}
+	s.attachments = attachments.NewService(db, blobStore, s.attachmentURLs)
+	s.trash = trash.NewService(s.expenses, s.settlements, s.attachments, retentionDays)
+	return s
+}
+
+func newRouter(db *sql.DB, shared *services) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
@@ -43,29 +104,66 @@ func main() {
 	})
 
 	// init auths
-	authService := auth.NewService(database.DB)
+	authService := auth.NewService(db)
 	authHandler := auth.NewHandler(authService)
 
 	// init groups
-	groupService := groups.NewService(database.DB)
+	groupService := groups.NewService(db)
 	groupHandler := groups.NewHandler(groupService)
 
 	// init expenses
-	expenseService := expenses.NewService(database.DB)
+	expenseService := shared.expenses
 	expenseHandler := expenses.NewHandler(expenseService)
 
 	// init settlements
-	settlementService := settlements.NewService(database.DB)
+	settlementService := shared.settlements
 	settlementHandler := settlements.NewHandler(settlementService)
 
+	// init exchange rates
+	rateService := rates.NewService(db)
+	rateHandler := rates.NewHandler(rateService)
+
+	// init categories
+	categoryService := categories.NewService(db)
+	categoryHandler := categories.NewHandler(categoryService)
+
+	// init attachments
+	attachmentHandler := attachments.NewHandler(shared.attachments, shared.attachmentURLs)
+
+	// init comments
+	commentService := comments.NewService(db)
+	commentHandler := comments.NewHandler(commentService)
+
+	// init tags
+	tagService := tags.NewService(db)
+	tagHandler := tags.NewHandler(tagService)
+
+	// init trash
+	trashHandler := trash.NewHandler(shared.trash)
+
+	// init activity
+	activityService := activity.NewService(db)
+	activityHandler := activity.NewHandler(activityService)
+
 	// init balances
-	balanceService := balances.NewService(database.DB)
+	balanceService := balances.NewService(db)
 	balanceHandler := balances.NewHandler(balanceService)
 
+	// init settle-up
+	settleUpHandler := settleup.NewHandler(settleup.NewService(balanceService, settlementService))
+
+	// init allocations
+	allocationHandler := allocations.NewHandler(allocations.NewService(db))
+
 	//init users
-	userService := users.NewService(database.DB)
+	userService := users.NewService(db)
 	userHandler := users.NewHandler(userService)
 
+	// member wraps group-scoped routes: the caller must be logged in and belong to the {id} group
+	member := func(handler http.HandlerFunc) http.Handler {
+		return middleware.AuthRequired(middleware.GroupMemberRequired(groupService, handler))
+	}
+
 	// auth routes
 	mux.HandleFunc("POST /api/auth/register", authHandler.Register)
 	mux.HandleFunc("POST /api/auth/login", authHandler.Login)
@@ -73,37 +171,105 @@ func main() {
 	// group routes
 	mux.Handle("POST /api/groups", middleware.AuthRequired(http.HandlerFunc(groupHandler.CreateGroup)))
 	mux.Handle("GET /api/groups", middleware.AuthRequired(http.HandlerFunc(groupHandler.GetGroups)))
-	mux.Handle("GET /api/groups/{id}", middleware.AuthRequired(http.HandlerFunc(groupHandler.GetGroup)))
-	mux.Handle("PUT /api/groups/{id}", middleware.AuthRequired(http.HandlerFunc(groupHandler.UpdateGroup)))
-	mux.Handle("DELETE /api/groups/{id}", middleware.AuthRequired(http.HandlerFunc(groupHandler.DeleteGroup)))
-	mux.Handle("POST /api/groups/{id}/members", middleware.AuthRequired(http.HandlerFunc(groupHandler.AddMember)))
-	mux.Handle("DELETE /api/groups/{id}/members/{user_id}", middleware.AuthRequired(http.HandlerFunc(groupHandler.RemoveMember)))
+	mux.Handle("GET /api/groups/{id}", member(groupHandler.GetGroup))
+	mux.Handle("PUT /api/groups/{id}", member(groupHandler.UpdateGroup))
+	mux.Handle("DELETE /api/groups/{id}", member(groupHandler.DeleteGroup))
+	mux.Handle("PUT /api/groups/{id}/owner", member(groupHandler.TransferOwnership))
+	mux.Handle("PUT /api/groups/{id}/lock", member(groupHandler.LockPeriod))
+	mux.Handle("GET /api/groups/{id}/members", member(groupHandler.GetMembers))
+	mux.Handle("POST /api/groups/{id}/members", member(groupHandler.AddMember))
+	mux.Handle("PUT /api/groups/{id}/members/{user_id}", member(groupHandler.UpdateMemberRole))
+	mux.Handle("DELETE /api/groups/{id}/members/{user_id}", member(groupHandler.RemoveMember))
 
 	// expense routes
-	mux.Handle("POST /api/groups/{id}/expenses", middleware.AuthRequired(http.HandlerFunc(expenseHandler.CreateExpense)))
-	mux.Handle("GET /api/groups/{id}/expenses", middleware.AuthRequired(http.HandlerFunc(expenseHandler.GetExpenses)))
-	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}", middleware.AuthRequired(http.HandlerFunc(expenseHandler.GetExpense)))
-	mux.Handle("PUT /api/groups/{id}/expenses/{expenseId}", middleware.AuthRequired(http.HandlerFunc(expenseHandler.UpdateExpense)))
-	mux.Handle("DELETE /api/groups/{id}/expenses/{expenseId}", middleware.AuthRequired(http.HandlerFunc(expenseHandler.DeleteExpense)))
+	mux.Handle("POST /api/groups/{id}/expenses", member(expenseHandler.CreateExpense))
+	mux.Handle("GET /api/groups/{id}/expenses", member(expenseHandler.GetExpenses))
+	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}", member(expenseHandler.GetExpense))
+	mux.Handle("PUT /api/groups/{id}/expenses/{expenseId}", member(expenseHandler.UpdateExpense))
+	mux.Handle("DELETE /api/groups/{id}/expenses/{expenseId}", member(expenseHandler.DeleteExpense))
+	mux.Handle("POST /api/groups/{id}/expenses/{expenseId}/restore", member(expenseHandler.RestoreExpense))
+	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}/history", member(expenseHandler.GetHistory))
+	mux.Handle("POST /api/groups/{id}/expenses/{expenseId}/revert", member(expenseHandler.RevertExpense))
 
 	// settlement routes
-	mux.Handle("POST /api/groups/{id}/settlements", middleware.AuthRequired(http.HandlerFunc(settlementHandler.CreateSettlement)))
-	mux.Handle("GET /api/groups/{id}/settlements", middleware.AuthRequired(http.HandlerFunc(settlementHandler.GetSettlements)))
+	mux.Handle("POST /api/groups/{id}/settlements", member(settlementHandler.CreateSettlement))
+	mux.Handle("GET /api/groups/{id}/settlements", member(settlementHandler.GetSettlements))
+	mux.Handle("GET /api/groups/{id}/settlements/{settlementId}", member(settlementHandler.GetSettlement))
+	mux.Handle("PUT /api/groups/{id}/settlements/{settlementId}", member(settlementHandler.UpdateSettlement))
+	mux.Handle("DELETE /api/groups/{id}/settlements/{settlementId}", member(settlementHandler.DeleteSettlement))
+	mux.Handle("POST /api/groups/{id}/settlements/{settlementId}/restore", member(settlementHandler.RestoreSettlement))
+	mux.Handle("POST /api/groups/{id}/settlements/{settlementId}/confirm", member(settlementHandler.ConfirmSettlement))
+	mux.Handle("POST /api/groups/{id}/settlements/{settlementId}/reject", member(settlementHandler.RejectSettlement))
+	mux.Handle("POST /api/groups/{id}/settlements/{settlementId}/cancel", member(settlementHandler.CancelSettlement))
+	mux.Handle("PUT /api/groups/{id}/settlements/{settlementId}/allocations", member(settlementHandler.AllocateSettlement))
+
+	// trash routes
+	mux.Handle("GET /api/groups/{id}/trash", member(trashHandler.GetTrash))
+
+	// exchange rate routes
+	mux.Handle("GET /api/groups/{id}/rates", member(rateHandler.GetRates))
+	mux.Handle("POST /api/groups/{id}/rates", member(rateHandler.SetRate))
+	mux.Handle("POST /api/groups/{id}/rates/import", member(rateHandler.ImportRates))
+
+	// category routes
+	mux.Handle("GET /api/groups/{id}/categories", member(categoryHandler.GetCategories))
+	mux.Handle("POST /api/groups/{id}/categories", member(categoryHandler.CreateCategory))
+	mux.Handle("PUT /api/groups/{id}/categories/{categoryId}", member(categoryHandler.RenameCategory))
+	mux.Handle("DELETE /api/groups/{id}/categories/{categoryId}", member(categoryHandler.DeleteCategory))
+	mux.Handle("GET /api/groups/{id}/category-rules", member(categoryHandler.GetRules))
+	mux.Handle("POST /api/groups/{id}/category-rules", member(categoryHandler.CreateRule))
+	mux.Handle("POST /api/groups/{id}/category-rules/apply", member(expenseHandler.ApplyCategoryRules))
+	mux.Handle("PUT /api/groups/{id}/category-rules/{ruleId}", member(categoryHandler.UpdateRule))
+	mux.Handle("DELETE /api/groups/{id}/category-rules/{ruleId}", member(categoryHandler.DeleteRule))
+
+	// attachment routes
+	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}/attachments", member(attachmentHandler.GetAttachments))
+	mux.Handle("POST /api/groups/{id}/expenses/{expenseId}/attachments", member(attachmentHandler.UploadAttachment))
+	mux.Handle("DELETE /api/groups/{id}/expenses/{expenseId}/attachments/{attachmentId}", member(attachmentHandler.DeleteAttachment))
+	// signed links, so images can load without a bearer token
+	mux.HandleFunc("GET /api/attachments/{attachmentId}/content", attachmentHandler.GetContent)
+
+	// comment routes
+	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}/comments", member(commentHandler.GetExpenseComments))
+	mux.Handle("POST /api/groups/{id}/expenses/{expenseId}/comments", member(commentHandler.CreateExpenseComment))
+	mux.Handle("GET /api/groups/{id}/settlements/{settlementId}/comments", member(commentHandler.GetSettlementComments))
+	mux.Handle("POST /api/groups/{id}/settlements/{settlementId}/comments", member(commentHandler.CreateSettlementComment))
+	mux.Handle("PUT /api/groups/{id}/comments/{commentId}", member(commentHandler.UpdateComment))
+	mux.Handle("DELETE /api/groups/{id}/comments/{commentId}", member(commentHandler.DeleteComment))
+
+	// tag routes
+	mux.Handle("GET /api/groups/{id}/tags", member(tagHandler.GetTags))
+	mux.Handle("POST /api/groups/{id}/tags", member(tagHandler.CreateTag))
+	mux.Handle("PUT /api/groups/{id}/tags/{tagId}", member(tagHandler.RenameTag))
+	mux.Handle("DELETE /api/groups/{id}/tags/{tagId}", member(tagHandler.DeleteTag))
+
+	// activity routes
+	mux.Handle("GET /api/groups/{id}/activity", member(activityHandler.GetGroupActivity))
+	mux.Handle("POST /api/groups/{id}/activity/read", member(activityHandler.MarkGroupActivityRead))
+	mux.Handle("GET /api/groups/{id}/settlements/{settlementId}/activity", member(activityHandler.GetSettlementActivity))
 
 	// balance routes
-	mux.Handle("GET /api/groups/{id}/balances", middleware.AuthRequired(http.HandlerFunc(balanceHandler.GetBalances)))
+	mux.Handle("GET /api/groups/{id}/balances", member(balanceHandler.GetBalances))
+	mux.Handle("GET /api/groups/{id}/balances/simplified", member(balanceHandler.GetDebts))
+	mux.Handle("GET /api/groups/{id}/balances/pairs", member(balanceHandler.GetPairBalances))
+	mux.Handle("GET /api/groups/{id}/balances/pairs/{user_id}", member(balanceHandler.GetUserPairBalances))
+
+	// settle-up routes
+	mux.Handle("POST /api/groups/{id}/settle-up", member(settleUpHandler.SettleUp))
+
+	// allocation routes
+	mux.Handle("GET /api/groups/{id}/expenses/{expenseId}/settlement", member(allocationHandler.GetExpenseSettlement))
+	mux.Handle("GET /api/groups/{id}/expense-settlements", member(allocationHandler.GetExpenseSettlements))
 
 	// user routes
 	mux.Handle("GET /api/users/me", middleware.AuthRequired(http.HandlerFunc(userHandler.GetMe)))
 	mux.Handle("PUT /api/users/me", middleware.AuthRequired(http.HandlerFunc(userHandler.UpdateMe)))
+	mux.Handle("GET /api/users/me/summary", middleware.AuthRequired(http.HandlerFunc(userHandler.GetSummary)))
+	mux.Handle("GET /api/users/me/activity", middleware.AuthRequired(http.HandlerFunc(activityHandler.GetMyActivity)))
+	mux.Handle("POST /api/users/me/activity/read", middleware.AuthRequired(http.HandlerFunc(activityHandler.MarkMyActivityRead)))
 
 	// swagger UI
 	mux.Handle("GET /swagger/", httpSwagger.WrapHandler)
 
-	port := os.Getenv("APP_PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Println("server starting on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, mux))
+	return mux
 }
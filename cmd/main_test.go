@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/attachments"
+	"github.com/IvanLouren/GoSplit/internal/auth"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/groups"
+	"github.com/IvanLouren/GoSplit/internal/trash"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// registerAndLogin creates a user and returns its ID and a bearer token.
+func registerAndLogin(t *testing.T, name, email string) (uuid.UUID, string) {
+	service := auth.NewService(testDB)
+
+	user, err := service.Register(name, email, "password123")
+	if err != nil {
+		t.Fatalf("failed to register user: %s", err)
+	}
+
+	token, err := service.Login(email, "password123")
+	if err != nil {
+		t.Fatalf("failed to log user: %s", err)
+	}
+	return user.ID, token
+}
+
+// newTestRouter returns the router with attachments kept in a temporary
+// directory.
+func newTestRouter(t *testing.T) *http.ServeMux {
+	return newRouter(testDB, newServices(testDB, attachments.NewLocalStore(t.TempDir()), trash.DefaultRetentionDays))
+}
+
+func doRequest(router http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGroupRoutes_NonMember(t *testing.T) {
+	ownerID, ownerToken := registerAndLogin(t, "Owner", "owner@test.com")
+	outsiderID, outsiderToken := registerAndLogin(t, "Outsider", "outsider@test.com")
+
+	group, err := groups.NewService(testDB).CreateGroup("Trip to Rome", models.DefaultCurrency, ownerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	splits := []expenses.SplitInput{
+		{UserID: ownerID, Amount: models.NewMoney(9000, "")},
+	}
+	expense, err := expenses.NewService(testDB).CreateExpense(group.ID, ownerID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	router := newTestRouter(t)
+	groupPath := "/api/groups/" + group.ID.String()
+	expensePath := groupPath + "/expenses/" + expense.ID.String()
+
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", groupPath, ""},
+		{"PUT", groupPath, `{"name":"Hijacked"}`},
+		{"DELETE", groupPath, ""},
+		{"PUT", groupPath + "/owner", `{"user_id":"` + outsiderID.String() + `"}`},
+		{"GET", groupPath + "/members", ""},
+		{"POST", groupPath + "/members", `{"user_id":"` + outsiderID.String() + `"}`},
+		{"PUT", groupPath + "/members/" + ownerID.String(), `{"role":"viewer"}`},
+		{"DELETE", groupPath + "/members/" + ownerID.String(), ""},
+		{"POST", groupPath + "/expenses", `{"description":"Taxi","amount":10,"splits":[]}`},
+		{"GET", groupPath + "/expenses", ""},
+		{"GET", expensePath, ""},
+		{"PUT", expensePath, `{"description":"Lunch","amount":10,"splits":[]}`},
+		{"DELETE", expensePath, ""},
+		{"POST", groupPath + "/settlements", `{"paid_to":"` + ownerID.String() + `","amount":10}`},
+		{"GET", groupPath + "/settlements", ""},
+		{"GET", groupPath + "/balances", ""},
+		{"GET", groupPath + "/balances/simplified", ""},
+		{"GET", groupPath + "/balances/pairs", ""},
+		{"GET", groupPath + "/balances/pairs/" + ownerID.String(), ""},
+		{"GET", groupPath + "/rates", ""},
+		{"POST", groupPath + "/rates", `{"base":"EUR","quote":"CHF","rate":0.93}`},
+		{"POST", groupPath + "/rates/import", "Date,CHF\n2024-01-02,0.93\n"},
+	}
+
+	for _, route := range routes {
+		rec := doRequest(router, route.method, route.path, outsiderToken, route.body)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected status 404 for non-member, got %d", route.method, route.path, rec.Code)
+		}
+	}
+
+	// nothing the outsider tried may have gone through
+	rec := doRequest(router, "GET", expensePath, ownerToken, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected owner to still read the expense, got %d", rec.Code)
+	}
+	rec = doRequest(router, "GET", groupPath, ownerToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected owner to read the group, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "Hijacked") {
+		t.Errorf("expected group name to be unchanged, got %s", rec.Body.String())
+	}
+}
+
+func TestGroupRoutes_UnknownGroup(t *testing.T) {
+	_, token := registerAndLogin(t, "Lost", "lost@test.com")
+
+	router := newTestRouter(t)
+	rec := doRequest(router, "GET", "/api/groups/"+uuid.New().String(), token, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown group, got %d", rec.Code)
+	}
+
+	rec = doRequest(router, "GET", "/api/groups/not-a-uuid/expenses", token, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid group ID, got %d", rec.Code)
+	}
+}
+
+func TestGroupRoutes_ExpenseOfOtherGroup(t *testing.T) {
+	userID, token := registerAndLogin(t, "Member", "member@test.com")
+
+	groupService := groups.NewService(testDB)
+	group, err := groupService.CreateGroup("Flat", models.DefaultCurrency, userID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	otherGroup, err := groupService.CreateGroup("Office", models.DefaultCurrency, userID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	splits := []expenses.SplitInput{
+		{UserID: userID, Amount: models.NewMoney(2000, "")},
+	}
+	expense, err := expenses.NewService(testDB).CreateExpense(otherGroup.ID, userID, expenses.ExpenseInput{
+		Description: "Coffee",
+		Amount:      models.NewMoney(2000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	router := newTestRouter(t)
+	rec := doRequest(router, "GET", "/api/groups/"+group.ID.String()+"/expenses/"+expense.ID.String(), token, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for expense of another group, got %d", rec.Code)
+	}
+}
+
+func TestGroupRoutes_Roles(t *testing.T) {
+	ownerID, ownerToken := registerAndLogin(t, "Role Owner", "role-owner@test.com")
+	adminID, adminToken := registerAndLogin(t, "Role Admin", "role-admin@test.com")
+	memberID, memberToken := registerAndLogin(t, "Role Member", "role-member@test.com")
+	viewerID, viewerToken := registerAndLogin(t, "Role Viewer", "role-viewer@test.com")
+
+	groupService := groups.NewService(testDB)
+	group, err := groupService.CreateGroup("Household", models.DefaultCurrency, ownerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	for userID, role := range map[uuid.UUID]models.Role{adminID: models.RoleAdmin, memberID: models.RoleMember, viewerID: models.RoleViewer} {
+		if err := groupService.AddMember(group.ID, userID, role, ownerID); err != nil {
+			t.Fatalf("failed to add member: %s", err)
+		}
+	}
+
+	expenseService := expenses.NewService(testDB)
+	ownerExpense, err := expenseService.CreateExpense(group.ID, ownerID, expenses.ExpenseInput{
+		Description: "Rent",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      []expenses.SplitInput{{UserID: ownerID, Amount: models.NewMoney(9000, "")}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	memberExpense, err := expenseService.CreateExpense(group.ID, memberID, expenses.ExpenseInput{
+		Description: "Groceries",
+		Amount:      models.NewMoney(3000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      []expenses.SplitInput{{UserID: memberID, Amount: models.NewMoney(3000, "")}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	router := newTestRouter(t)
+	groupPath := "/api/groups/" + group.ID.String()
+	expenseBody := `{"description":"Lunch","amount":10,"splits":[{"user_id":"` + memberID.String() + `","amount":10}]}`
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"viewer reads expenses", viewerToken, "GET", groupPath + "/expenses", "", http.StatusOK},
+		{"viewer adds expense", viewerToken, "POST", groupPath + "/expenses", expenseBody, http.StatusForbidden},
+		{"viewer records settlement", viewerToken, "POST", groupPath + "/settlements", `{"paid_to":"` + ownerID.String() + `","amount":10}`, http.StatusForbidden},
+		{"member renames group", memberToken, "PUT", groupPath, `{"name":"Mine"}`, http.StatusForbidden},
+		{"member removes viewer", memberToken, "DELETE", groupPath + "/members/" + viewerID.String(), "", http.StatusForbidden},
+		{"member edits other's expense", memberToken, "PUT", groupPath + "/expenses/" + ownerExpense.ID.String(), expenseBody, http.StatusForbidden},
+		{"member edits own expense", memberToken, "PUT", groupPath + "/expenses/" + memberExpense.ID.String(), expenseBody, http.StatusOK},
+		{"admin deletes group", adminToken, "DELETE", groupPath, "", http.StatusForbidden},
+		{"admin promotes to admin", adminToken, "PUT", groupPath + "/members/" + memberID.String(), `{"role":"admin"}`, http.StatusForbidden},
+		{"admin transfers ownership", adminToken, "PUT", groupPath + "/owner", `{"user_id":"` + adminID.String() + `"}`, http.StatusForbidden},
+		{"admin demotes member", adminToken, "PUT", groupPath + "/members/" + viewerID.String(), `{"role":"viewer"}`, http.StatusOK},
+		{"admin renames group", adminToken, "PUT", groupPath, `{"name":"Household 2"}`, http.StatusOK},
+		{"member sets rate", memberToken, "POST", groupPath + "/rates", `{"base":"EUR","quote":"CHF","rate":0.93}`, http.StatusForbidden},
+		{"admin sets rate", adminToken, "POST", groupPath + "/rates", `{"base":"EUR","quote":"CHF","rate":0.93}`, http.StatusCreated},
+		{"member reads rates", memberToken, "GET", groupPath + "/rates", "", http.StatusOK},
+		{"owner removes self", ownerToken, "DELETE", groupPath + "/members/" + ownerID.String(), "", http.StatusConflict},
+		{"viewer leaves", viewerToken, "DELETE", groupPath + "/members/" + viewerID.String(), "", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		rec := doRequest(router, tt.method, tt.path, tt.token, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d (%s)", tt.name, tt.want, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+func TestCreateExpense_ExactSplits(t *testing.T) {
+	userID, token := registerAndLogin(t, "Exact", "exact@test.com")
+
+	group, err := groups.NewService(testDB).CreateGroup("Cents", models.DefaultCurrency, userID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	friendID, _ := registerAndLogin(t, "Exact Friend", "exact-friend@test.com")
+	if err := groups.NewService(testDB).AddMember(group.ID, friendID, models.RoleMember, userID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	router := newTestRouter(t)
+	path := "/api/groups/" + group.ID.String() + "/expenses"
+	split := func(amount string) string {
+		return `{"user_id":"` + userID.String() + `","amount":` + amount + `}`
+	}
+	friendSplit := `{"user_id":"` + friendID.String() + `","amount":0.2}`
+
+	rec := doRequest(router, "POST", path, token, `{"description":"Snacks","amount":0.3,"splits":[`+split("0.1")+`,`+friendSplit+`]}`)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected 0.10 + 0.20 to equal 0.30, got status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"amount":0.30`) {
+		t.Errorf("expected amount encoded as 0.30, got %s", rec.Body.String())
+	}
+
+	rec = doRequest(router, "POST", path, token, `{"description":"Snacks","amount":10,"splits":[`+split("10.01")+`]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a one cent mismatch to be rejected, got status %d", rec.Code)
+	}
+
+	rec = doRequest(router, "POST", path, token, `{"description":"Snacks","amount":10.001,"splits":[`+split("10.001")+`]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected sub-cent amounts to be rejected, got status %d", rec.Code)
+	}
+}
+
+func TestCreateExpense_SplitTypes(t *testing.T) {
+	userID, token := registerAndLogin(t, "Splitter", "splitter@test.com")
+	friendID, _ := registerAndLogin(t, "Splitter Friend", "splitter-friend@test.com")
+
+	group, err := groups.NewService(testDB).CreateGroup("Splits", models.DefaultCurrency, userID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := groups.NewService(testDB).AddMember(group.ID, friendID, models.RoleMember, userID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	router := newTestRouter(t)
+	path := "/api/groups/" + group.ID.String() + "/expenses"
+	users := `{"user_id":"` + userID.String() + `"},{"user_id":"` + friendID.String() + `"}`
+
+	rec := doRequest(router, "POST", path, token, `{"description":"Taxi","amount":10,"split_type":"equal","splits":[`+users+`]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"split_type":"equal"`) {
+		t.Errorf("expected split type equal in response, got %s", rec.Body.String())
+	}
+
+	rec = doRequest(router, "POST", path, token, `{"description":"Taxi","amount":10,"split_type":"percentage","splits":[`+users+`]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected percentages not adding up to 100 to be rejected, got status %d", rec.Code)
+	}
+
+	rec = doRequest(router, "POST", path, token, `{"description":"Taxi","amount":10,"split_type":"thirds","splits":[`+users+`]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown split type to be rejected, got status %d", rec.Code)
+	}
+}
+
+func TestUpdateExpense_CoPayer(t *testing.T) {
+	ownerID, _ := registerAndLogin(t, "Payer Owner", "payer-owner@test.com")
+	coPayerID, coPayerToken := registerAndLogin(t, "Co-Payer", "co-payer@test.com")
+	otherID, otherToken := registerAndLogin(t, "Not Paying", "not-paying@test.com")
+
+	groupService := groups.NewService(testDB)
+	group, err := groupService.CreateGroup("Shared Bills", models.DefaultCurrency, ownerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	for _, id := range []uuid.UUID{coPayerID, otherID} {
+		if err := groupService.AddMember(group.ID, id, models.RoleMember, ownerID); err != nil {
+			t.Fatalf("failed to add member: %s", err)
+		}
+	}
+
+	router := newTestRouter(t)
+	splits := `"splits":[{"user_id":"` + ownerID.String() + `"},{"user_id":"` + coPayerID.String() + `"},{"user_id":"` + otherID.String() + `"}]`
+	payers := `"payers":[{"user_id":"` + ownerID.String() + `","amount":20},{"user_id":"` + coPayerID.String() + `","amount":10}]`
+	rec := doRequest(router, "POST", "/api/groups/"+group.ID.String()+"/expenses", coPayerToken,
+		`{"description":"Power","amount":30,"split_type":"equal",`+splits+`,`+payers+`}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var expense models.Expense
+	if err := json.NewDecoder(rec.Body).Decode(&expense); err != nil {
+		t.Fatalf("failed to decode expense: %s", err)
+	}
+	if expense.PaidBy != ownerID || len(expense.Payers) != 2 {
+		t.Errorf("expected 2 payers led by the owner, got paid_by %s and %d payers", expense.PaidBy, len(expense.Payers))
+	}
+
+	path := "/api/groups/" + group.ID.String() + "/expenses/" + expense.ID.String()
+	body := `{"description":"Power bill","amount":30,"split_type":"equal",` + splits + `}`
+	if rec := doRequest(router, "PUT", path, coPayerToken, body); rec.Code != http.StatusOK {
+		t.Errorf("expected a co-payer to edit the expense, got status %d", rec.Code)
+	}
+	if rec := doRequest(router, "PUT", path, otherToken, body); rec.Code != http.StatusForbidden {
+		t.Errorf("expected a member who didn't pay to be forbidden, got status %d", rec.Code)
+	}
+
+	bad := `"payers":[{"user_id":"` + coPayerID.String() + `","amount":10}]`
+	rec = doRequest(router, "POST", "/api/groups/"+group.ID.String()+"/expenses", coPayerToken,
+		`{"description":"Power","amount":30,"split_type":"equal",`+splits+`,`+bad+`}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected payers short of the total to be rejected, got status %d", rec.Code)
+	}
+}
+
+func TestCreateExpense_PaidBy(t *testing.T) {
+	recorderID, recorderToken := registerAndLogin(t, "Recorder", "recorder@test.com")
+	payerID, _ := registerAndLogin(t, "Actual Payer", "actual-payer@test.com")
+	outsiderID, _ := registerAndLogin(t, "Not In Group", "not-in-group@test.com")
+
+	groupService := groups.NewService(testDB)
+	group, err := groupService.CreateGroup("Road Trip", models.DefaultCurrency, payerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := groupService.AddMember(group.ID, recorderID, models.RoleMember, payerID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	router := newTestRouter(t)
+	path := "/api/groups/" + group.ID.String() + "/expenses"
+	splits := `"splits":[{"user_id":"` + recorderID.String() + `"},{"user_id":"` + payerID.String() + `"}]`
+
+	rec := doRequest(router, "POST", path, recorderToken, `{"description":"Fuel","amount":50,"split_type":"equal","paid_by":"`+payerID.String()+`",`+splits+`}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var expense models.Expense
+	if err := json.NewDecoder(rec.Body).Decode(&expense); err != nil {
+		t.Fatalf("failed to decode expense: %s", err)
+	}
+	if expense.PaidBy != payerID {
+		t.Errorf("expected paid_by %s, got %s", payerID, expense.PaidBy)
+	}
+	if expense.CreatedBy != recorderID {
+		t.Errorf("expected created_by %s, got %s", recorderID, expense.CreatedBy)
+	}
+
+	// the recorder can still edit what they recorded
+	body := `{"description":"Fuel and tolls","amount":50,"split_type":"equal",` + splits + `}`
+	if rec := doRequest(router, "PUT", path+"/"+expense.ID.String(), recorderToken, body); rec.Code != http.StatusOK {
+		t.Errorf("expected the recorder to edit the expense, got status %d", rec.Code)
+	}
+
+	rec = doRequest(router, "POST", path, recorderToken, `{"description":"Fuel","amount":50,"split_type":"equal","paid_by":"`+outsiderID.String()+`",`+splits+`}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for a payer outside the group, got %d", rec.Code)
+	}
+	var invalid expenses.ValidationError
+	if err := json.NewDecoder(rec.Body).Decode(&invalid); err != nil {
+		t.Fatalf("failed to decode validation error: %s", err)
+	}
+	if len(invalid.Fields["paid_by"]) != 1 || invalid.Fields["paid_by"][0] != outsiderID {
+		t.Errorf("expected the outsider under paid_by, got %v", invalid.Fields)
+	}
+
+	outsiderSplits := `"splits":[{"user_id":"` + recorderID.String() + `"},{"user_id":"` + outsiderID.String() + `"}]`
+	rec = doRequest(router, "POST", path, recorderToken, `{"description":"Fuel","amount":50,"split_type":"equal",`+outsiderSplits+`}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for a participant outside the group, got %d", rec.Code)
+	}
+}
+
+func TestCreateExpense_Itemized(t *testing.T) {
+	dinerID, dinerToken := registerAndLogin(t, "Diner", "diner@test.com")
+	guestID, _ := registerAndLogin(t, "Guest", "guest@test.com")
+	outsiderID, _ := registerAndLogin(t, "Other Table", "other-table@test.com")
+
+	groupService := groups.NewService(testDB)
+	group, err := groupService.CreateGroup("Dinner Club", models.DefaultCurrency, dinerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := groupService.AddMember(group.ID, guestID, models.RoleMember, dinerID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	router := newTestRouter(t)
+	path := "/api/groups/" + group.ID.String() + "/expenses"
+	items := `"items":[{"description":"Pizza","price":20,"user_ids":["` + dinerID.String() + `","` + guestID.String() + `"]},` +
+		`{"description":"Steak","price":30,"user_ids":["` + guestID.String() + `"]}]`
+
+	rec := doRequest(router, "POST", path, dinerToken, `{"description":"Dinner","amount":55,"split_type":"itemized","tip":5,`+items+`}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var expense models.Expense
+	if err := json.NewDecoder(rec.Body).Decode(&expense); err != nil {
+		t.Fatalf("failed to decode expense: %s", err)
+	}
+	if expense.Receipt == nil || len(expense.Receipt.Items) != 2 {
+		t.Fatalf("expected a receipt with 2 items, got %+v", expense.Receipt)
+	}
+	if expense.Receipt.Tip.Minor != 500 {
+		t.Errorf("expected a tip of 5.00, got %s", expense.Receipt.Tip)
+	}
+
+	rec = doRequest(router, "POST", path, dinerToken, `{"description":"Dinner","amount":55,"split_type":"itemized","tip":6,`+items+`}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 when the receipt doesn't add up, got %d", rec.Code)
+	}
+
+	outsiderItems := `"items":[{"description":"Wine","price":20,"user_ids":["` + outsiderID.String() + `"]}]`
+	rec = doRequest(router, "POST", path, dinerToken, `{"description":"Wine","amount":20,"split_type":"itemized",`+outsiderItems+`}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for an item shared with a non-member, got %d", rec.Code)
+	}
+	var invalid expenses.ValidationError
+	if err := json.NewDecoder(rec.Body).Decode(&invalid); err != nil {
+		t.Fatalf("failed to decode validation error: %s", err)
+	}
+	if len(invalid.Fields["items"]) != 1 || invalid.Fields["items"][0] != outsiderID {
+		t.Errorf("expected the outsider under items, got %v", invalid.Fields)
+	}
+}
+
+func TestGetExpenses_Query(t *testing.T) {
+	userID, token := registerAndLogin(t, "Lister", "lister@test.com")
+
+	group, err := groups.NewService(testDB).CreateGroup("Archive", models.DefaultCurrency, userID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	router := newTestRouter(t)
+	path := "/api/groups/" + group.ID.String() + "/expenses"
+	splits := `"splits":[{"user_id":"` + userID.String() + `"}]`
+	for _, body := range []string{
+		`{"description":"Stamps","amount":5,"split_type":"equal",` + splits + `}`,
+		`{"description":"Printer","amount":120,"split_type":"equal",` + splits + `}`,
+	} {
+		if rec := doRequest(router, "POST", path, token, body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(router, "GET", path+"?sort=amount_desc&limit=1", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var page models.ExpensePage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode page: %s", err)
+	}
+	if len(page.Expenses) != 1 || page.Expenses[0].Description != "Printer" || page.NextCursor == "" {
+		t.Fatalf("expected the printer and a next cursor, got %+v", page)
+	}
+
+	rec = doRequest(router, "GET", path+"?sort=amount_desc&limit=1&cursor="+page.NextCursor, token, "")
+	page = models.ExpensePage{}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode page: %s", err)
+	}
+	if len(page.Expenses) != 1 || page.Expenses[0].Description != "Stamps" || page.NextCursor != "" {
+		t.Errorf("expected the stamps on the last page, got %+v", page)
+	}
+
+	for _, query := range []string{"?sort=random", "?limit=0", "?from=yesterday", "?paid_by=me", "?min_amount=1.234", "?cursor=abc"} {
+		if rec := doRequest(router, "GET", path+query, token, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
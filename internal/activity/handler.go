@@ -0,0 +1,207 @@
+package activity
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetGroupActivity godoc
+// @Summary      List a group's activity
+// @Description  Returns a page of who did what in the group, newest first, with the caller's unread entries marked. Pass next_cursor back as cursor for the next page.
+// @Tags         activity
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Group ID"
+// @Param        cursor  query     string  false  "next_cursor of the previous page"
+// @Param        limit   query     int     false  "Page size, 50 by default and at most 200"
+// @Success      200     {object}  models.ActivityPage
+// @Failure      400     {string}  string  "invalid ID or query parameter"
+// @Failure      401     {string}  string  "unauthorized"
+// @Failure      403     {string}  string  "forbidden"
+// @Failure      500     {string}  string  "internal error"
+// @Router       /api/groups/{id}/activity [get]
+func (h *Handler) GetGroupActivity(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.service.GetGroupActivity(groupID, userID, r.URL.Query().Get("cursor"), limit)
+	writePage(w, page, err)
+}
+
+// GetSettlementActivity godoc
+// @Summary      List a settlement's activity
+// @Description  Returns who created, edited, confirmed, rejected, cancelled, deleted or restored the settlement, newest first, with what it was before and after each change.
+// @Tags         activity
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true   "Group ID"
+// @Param        settlementId  path      string  true   "Settlement ID"
+// @Param        cursor        query     string  false  "next_cursor of the previous page"
+// @Param        limit         query     int     false  "Page size, 50 by default and at most 200"
+// @Success      200           {object}  models.ActivityPage
+// @Failure      400           {string}  string  "invalid ID or query parameter"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/activity [get]
+func (h *Handler) GetSettlementActivity(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	settlementID, err := uuid.Parse(r.PathValue("settlementId"))
+	if err != nil {
+		http.Error(w, "invalid settlement ID", http.StatusBadRequest)
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.service.GetObjectActivity(groupID, settlementID, userID, r.URL.Query().Get("cursor"), limit)
+	writePage(w, page, err)
+}
+
+// MarkGroupActivityRead godoc
+// @Summary      Mark a group's activity as read
+// @Description  Marks every entry of the group's activity so far as read by the caller.
+// @Tags         activity
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      204
+// @Failure      400  {string}  string  "invalid ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/activity/read [post]
+func (h *Handler) MarkGroupActivityRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.MarkGroupRead(groupID, userID); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMyActivity godoc
+// @Summary      List the activity of all the current user's groups
+// @Description  Returns a page of who did what in every group the caller is a member of, newest first, with their unread entries marked. Pass next_cursor back as cursor for the next page.
+// @Tags         activity
+// @Produce      json
+// @Security     BearerAuth
+// @Param        cursor  query     string  false  "next_cursor of the previous page"
+// @Param        limit   query     int     false  "Page size, 50 by default and at most 200"
+// @Success      200     {object}  models.ActivityPage
+// @Failure      400     {string}  string  "invalid query parameter"
+// @Failure      401     {string}  string  "unauthorized"
+// @Failure      500     {string}  string  "internal error"
+// @Router       /api/users/me/activity [get]
+func (h *Handler) GetMyActivity(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	limit, ok := pageLimit(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.service.GetUserActivity(userID, r.URL.Query().Get("cursor"), limit)
+	writePage(w, page, err)
+}
+
+// MarkMyActivityRead godoc
+// @Summary      Mark the activity of all the current user's groups as read
+// @Tags         activity
+// @Security     BearerAuth
+// @Success      204
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/users/me/activity/read [post]
+func (h *Handler) MarkMyActivityRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.MarkAllRead(userID); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pageLimit reads the limit query parameter, zero when it is missing. It
+// writes the error response itself and returns false when it is invalid.
+func pageLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+func writePage(w http.ResponseWriter, page models.ActivityPage, err error) {
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,110 @@
+package activity
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Entry is a change to record in a group's activity. Before and After are
+// stored as JSON, and as null when nil.
+type Entry struct {
+	GroupID    uuid.UUID
+	ActorID    uuid.UUID
+	Verb       models.ActivityVerb
+	ObjectType models.ActivityKind
+	ObjectID   uuid.UUID
+	Before     any
+	After      any
+}
+
+// Record adds entry to the group's activity within tx, so it is only kept
+// when the change it describes is.
+func Record(tx *sql.Tx, entry Entry) error {
+	before, err := summaryJSON(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := summaryJSON(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO activity (group_id, actor_id, verb, object_type, object_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.GroupID, entry.ActorID, entry.Verb, entry.ObjectType, entry.ObjectID, before, after)
+	return err
+}
+
+func summaryJSON(summary any) ([]byte, error) {
+	if summary == nil {
+		return nil, nil
+	}
+	return json.Marshal(summary)
+}
+
+// ExpenseSummary is what the activity log keeps of an expense.
+type ExpenseSummary struct {
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"`
+	IncurredOn  string       `json:"incurred_on"`
+}
+
+func Expense(expense models.Expense) ExpenseSummary {
+	return ExpenseSummary{
+		Description: expense.Description,
+		Amount:      expense.Amount,
+		Currency:    expense.Currency,
+		IncurredOn:  expense.IncurredOn,
+	}
+}
+
+// SettlementSummary is what the activity log keeps of a settlement.
+type SettlementSummary struct {
+	PaidBy          uuid.UUID               `json:"paid_by"`
+	PaidTo          uuid.UUID               `json:"paid_to"`
+	Amount          models.Money            `json:"amount"`
+	Currency        string                  `json:"currency"`
+	Status          models.SettlementStatus `json:"status"`
+	RejectionReason string                  `json:"rejection_reason,omitempty"`
+}
+
+func Settlement(settlement models.Settlement) SettlementSummary {
+	return SettlementSummary{
+		PaidBy:          settlement.PaidBy,
+		PaidTo:          settlement.PaidTo,
+		Amount:          settlement.Amount,
+		Currency:        settlement.Currency,
+		Status:          settlement.Status,
+		RejectionReason: settlement.RejectionReason,
+	}
+}
+
+// GroupSummary is what the activity log keeps of a group.
+type GroupSummary struct {
+	Name        string          `json:"name"`
+	Currency    string          `json:"currency"`
+	DebtMode    models.DebtMode `json:"debt_mode"`
+	LockedUntil string          `json:"locked_until,omitempty"`
+}
+
+func Group(group models.Group) GroupSummary {
+	return GroupSummary{
+		Name:        group.Name,
+		Currency:    group.Currency,
+		DebtMode:    group.DebtMode,
+		LockedUntil: group.LockedUntil,
+	}
+}
+
+// MemberSummary is what the activity log keeps of a membership.
+type MemberSummary struct {
+	UserID uuid.UUID   `json:"user_id"`
+	Role   models.Role `json:"role"`
+}
+
+func Member(userID uuid.UUID, role models.Role) MemberSummary {
+	return MemberSummary{UserID: userID, Role: role}
+}
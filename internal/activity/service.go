@@ -0,0 +1,141 @@
+package activity
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultLimit is the page size when none is given.
+	DefaultLimit = 50
+	// MaxLimit is the largest page a feed returns.
+	MaxLimit = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// feedFrom joins what every feed query needs: the reader is $1 and only
+// sees the activity of the groups they are a member of.
+const feedFrom = ` FROM activity a
+	JOIN groups g ON g.id = a.group_id
+	JOIN users u ON u.id = a.actor_id
+	JOIN group_members m ON m.group_id = a.group_id AND m.user_id = $1
+	LEFT JOIN activity_reads r ON r.group_id = a.group_id AND r.user_id = $1`
+
+// unread holds for entries by someone else since the reader last marked the
+// group read, or since they joined it.
+const unread = `(a.actor_id <> $1 AND a.created_at > COALESCE(r.read_at, m.joined_at))`
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// cursor is the position after the last entry of a page.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// GetGroupActivity returns a page of the group's activity as userID reads
+// it, newest first, starting after cursor. It returns ErrInvalidCursor for
+// cursors it didn't issue.
+func (s *Service) GetGroupActivity(groupID, userID uuid.UUID, after string, limit int) (models.ActivityPage, error) {
+	return s.feed(userID, `a.group_id = $2`, []any{userID, groupID}, after, limit)
+}
+
+// GetObjectActivity returns a page of the group's activity about one
+// expense, settlement or member, like GetGroupActivity, so it reads as the
+// object's audit trail.
+func (s *Service) GetObjectActivity(groupID, objectID, userID uuid.UUID, after string, limit int) (models.ActivityPage, error) {
+	return s.feed(userID, `a.group_id = $2 AND a.object_id = $3`, []any{userID, groupID, objectID}, after, limit)
+}
+
+// GetUserActivity returns a page of the activity of every group userID is a
+// member of, like GetGroupActivity.
+func (s *Service) GetUserActivity(userID uuid.UUID, after string, limit int) (models.ActivityPage, error) {
+	return s.feed(userID, `TRUE`, []any{userID}, after, limit)
+}
+
+func (s *Service) feed(userID uuid.UUID, where string, args []any, after string, limit int) (models.ActivityPage, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+
+	page := models.ActivityPage{Activity: []models.ActivityEntry{}}
+	err := s.db.QueryRow(`SELECT count(*)`+feedFrom+` WHERE `+where+` AND `+unread, args...).Scan(&page.Unread)
+	if err != nil {
+		return models.ActivityPage{}, err
+	}
+
+	query := `SELECT a.id, a.group_id, g.name, a.actor_id, u.name, a.verb, a.object_type, a.object_id, a.before, a.after, a.created_at, ` +
+		unread + feedFrom + ` WHERE ` + where
+	if after != "" {
+		var c cursor
+		data, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || json.Unmarshal(data, &c) != nil || c.ID == uuid.Nil {
+			return models.ActivityPage{}, ErrInvalidCursor
+		}
+		query += fmt.Sprintf(` AND (a.created_at, a.id) < ($%d::timestamptz, $%d::uuid)`, len(args)+1, len(args)+2)
+		args = append(args, c.CreatedAt, c.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY a.created_at DESC, a.id DESC LIMIT %d`, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return models.ActivityPage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.ActivityEntry
+		var before, after []byte
+		err := rows.Scan(&entry.ID, &entry.GroupID, &entry.GroupName, &entry.ActorID, &entry.ActorName, &entry.Verb,
+			&entry.ObjectType, &entry.ObjectID, &before, &after, &entry.CreatedAt, &entry.Unread)
+		if err != nil {
+			return models.ActivityPage{}, err
+		}
+		entry.Before = before
+		entry.After = after
+		page.Activity = append(page.Activity, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return models.ActivityPage{}, err
+	}
+
+	if len(page.Activity) > limit {
+		page.Activity = page.Activity[:limit]
+		last := page.Activity[limit-1]
+		data, _ := json.Marshal(cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, nil
+}
+
+// MarkGroupRead marks everything in the group's activity so far as read by
+// userID.
+func (s *Service) MarkGroupRead(groupID, userID uuid.UUID) error {
+	_, err := s.db.Exec(`INSERT INTO activity_reads (user_id, group_id, read_at)
+		SELECT user_id, group_id, now() FROM group_members WHERE group_id = $1 AND user_id = $2
+		ON CONFLICT (user_id, group_id) DO UPDATE SET read_at = EXCLUDED.read_at`, groupID, userID)
+	return err
+}
+
+// MarkAllRead marks the activity of every group userID is a member of as
+// read.
+func (s *Service) MarkAllRead(userID uuid.UUID) error {
+	_, err := s.db.Exec(`INSERT INTO activity_reads (user_id, group_id, read_at)
+		SELECT user_id, group_id, now() FROM group_members WHERE user_id = $1
+		ON CONFLICT (user_id, group_id) DO UPDATE SET read_at = EXCLUDED.read_at`, userID)
+	return err
+}
@@ -0,0 +1,212 @@
+package activity_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/activity"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/groups"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestActivity(t *testing.T) {
+	var ana, bruno uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"ana@test.com", &ana}, {"bruno@test.com", &bruno}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	groupService := groups.NewService(testDB)
+	expenseService := expenses.NewService(testDB)
+	settlementService := settlements.NewService(testDB)
+	service := activity.NewService(testDB)
+
+	group, err := groupService.CreateGroup("Flat", "EUR", ana)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := groupService.AddMember(group.ID, bruno, models.RoleMember, ana); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+	expense, err := expenseService.CreateExpense(group.ID, ana, expenses.ExpenseInput{
+		Description: "Internet",
+		Amount:      models.NewMoney(4000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ana}, {UserID: bruno}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if err := expenseService.DeleteExpense(group.ID, expense.ID, ana); err != nil {
+		t.Fatalf("failed to delete expense: %s", err)
+	}
+	settlement, err := settlementService.CreateSettlement(group.ID, bruno, ana, models.NewMoney(2000, ""), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+
+	page, err := service.GetGroupActivity(group.ID, bruno, "", 0)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	want := []struct {
+		verb       models.ActivityVerb
+		objectType models.ActivityKind
+		unread     bool
+	}{
+		{models.VerbCreated, models.ActivitySettlement, false},
+		{models.VerbDeleted, models.ActivityExpense, true},
+		{models.VerbCreated, models.ActivityExpense, true},
+		{models.VerbAdded, models.ActivityMember, false},
+		{models.VerbCreated, models.ActivityGroup, false},
+	}
+	if len(page.Activity) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), page.Activity)
+	}
+	for i, w := range want {
+		entry := page.Activity[i]
+		if entry.Verb != w.verb || entry.ObjectType != w.objectType || entry.Unread != w.unread {
+			t.Errorf("entry %d: expected %s %s unread %t, got %+v", i, w.objectType, w.verb, w.unread, entry)
+		}
+	}
+	if page.Unread != 2 || page.NextCursor != "" {
+		t.Errorf("expected 2 unread entries on a single page, got %d and cursor %q", page.Unread, page.NextCursor)
+	}
+	deleted := page.Activity[1]
+	if deleted.ActorID != ana || deleted.ObjectID != expense.ID || deleted.Before == nil || deleted.After != nil {
+		t.Errorf("expected ana's deletion of the expense with what it was, got %+v", deleted)
+	}
+
+	// pages follow each other without gaps
+	first, err := service.GetGroupActivity(group.ID, bruno, "", 2)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	if len(first.Activity) != 2 || first.NextCursor == "" {
+		t.Fatalf("expected a first page of 2 with a cursor, got %+v", first)
+	}
+	second, err := service.GetGroupActivity(group.ID, bruno, first.NextCursor, 2)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	if len(second.Activity) != 2 || second.Activity[0].ID != page.Activity[2].ID {
+		t.Errorf("expected the second page to start at entry 2, got %+v", second.Activity)
+	}
+	trail, err := service.GetObjectActivity(group.ID, settlement.ID, bruno, "", 0)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	if len(trail.Activity) != 1 || trail.Activity[0].ObjectID != settlement.ID {
+		t.Errorf("expected only the settlement's creation, got %+v", trail.Activity)
+	}
+	if _, err := service.GetGroupActivity(group.ID, bruno, "nope", 2); !errors.Is(err, activity.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	if err := service.MarkGroupRead(group.ID, bruno); err != nil {
+		t.Fatalf("failed to mark read: %s", err)
+	}
+	page, err = service.GetUserActivity(bruno, "", 0)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	if len(page.Activity) != len(want) || page.Unread != 0 || page.Activity[0].GroupName != "Flat" {
+		t.Errorf("expected all of Flat's activity read, got %d unread in %+v", page.Unread, page.Activity)
+	}
+
+	// the actor's own entries are never unread
+	page, err = service.GetGroupActivity(group.ID, ana, "", 0)
+	if err != nil {
+		t.Fatalf("failed to get activity: %s", err)
+	}
+	if page.Unread != 1 || !page.Activity[0].Unread {
+		t.Errorf("expected only bruno's settlement unread for ana, got %d", page.Unread)
+	}
+	if err := service.MarkAllRead(ana); err != nil {
+		t.Fatalf("failed to mark read: %s", err)
+	}
+	if page, _ := service.GetUserActivity(ana, "", 0); page.Unread != 0 {
+		t.Errorf("expected nothing unread, got %d", page.Unread)
+	}
+
+	// outsiders see nothing
+	var carla uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"carla@test.com", "carla@test.com", "hashedpassword").Scan(&carla)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	if page, _ := service.GetGroupActivity(group.ID, carla, "", 0); len(page.Activity) != 0 {
+		t.Errorf("expected no activity for a non-member, got %+v", page.Activity)
+	}
+}
@@ -0,0 +1,324 @@
+package allocations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/database"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAllocation is returned when explicit allocations don't fit the
+// settlement or what its payer owes its payee.
+var ErrInvalidAllocation = errors.New("invalid allocation")
+
+// Querier is implemented by *sql.DB and *sql.Tx.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// owedToPayee matches the expenses in currency $2 that $3 shares and $4 paid
+// part of.
+const owedToPayee = `e.currency = $2
+	AND EXISTS (SELECT 1 FROM expense_splits s WHERE s.expense_id = e.id AND s.user_id = $3)
+	AND EXISTS (SELECT 1 FROM expense_payers p WHERE p.expense_id = e.id AND p.user_id = $4)`
+
+// Allocate replaces the settlement's allocations. With explicit nil the
+// settlement pays off the oldest open debts of its payer to its payee first,
+// in its currency, for as far as it goes; otherwise explicit says how much
+// goes to which expense and ErrInvalidAllocation is returned unless it fits
+// what is still open. Whatever isn't allocated stays a plain payment.
+func Allocate(tx *sql.Tx, settlement models.Settlement, explicit []models.Allocation) ([]models.Allocation, error) {
+	args := []any{settlement.GroupID, settlement.Currency, settlement.PaidBy, settlement.PaidTo}
+
+	// lock the expenses before reading what was allocated to them, so two
+	// settlements between the same people can't both pay off the same debt
+	if _, err := tx.Exec(`SELECT 1 FROM expenses e WHERE e.group_id = $1 AND e.deleted_at IS NULL AND `+owedToPayee+`
+		ORDER BY e.id FOR UPDATE`, args...); err != nil {
+		return nil, err
+	}
+	expenses, err := loadDebts(tx, settlement.ID, settlement.GroupID, owedToPayee, args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []openDebt
+	for _, expense := range expenses {
+		for _, debt := range expense.Debts {
+			if debt.DebtorID == settlement.PaidBy && debt.CreditorID == settlement.PaidTo {
+				open = append(open, openDebt{expenseID: expense.ExpenseID, open: debt.Outstanding.Sub(debt.Pending)})
+			}
+		}
+	}
+
+	allocations := fifo(settlement.Amount, open)
+	if explicit != nil {
+		if allocations, err = checkExplicit(settlement.Amount, open, explicit); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM settlement_allocations WHERE settlement_id = $1`, settlement.ID); err != nil {
+		return nil, err
+	}
+	for _, allocation := range allocations {
+		_, err := tx.Exec(`INSERT INTO settlement_allocations (settlement_id, expense_id, amount) VALUES ($1, $2, $3)`,
+			settlement.ID, allocation.ExpenseID, allocation.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return allocations, nil
+}
+
+// SettlementAllocations returns the allocations of each of the settlements,
+// oldest expense first. Settlements without allocations are left out.
+func SettlementAllocations(q Querier, settlementIDs database.UUIDs) (map[uuid.UUID][]models.Allocation, error) {
+	rows, err := q.Query(`SELECT a.settlement_id, a.expense_id, a.amount, s.currency
+		FROM settlement_allocations a
+		JOIN settlements s ON s.id = a.settlement_id
+		JOIN expenses e ON e.id = a.expense_id
+		WHERE a.settlement_id = ANY($1::uuid[])
+		ORDER BY e.incurred_on, e.created_at, e.id`, settlementIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[uuid.UUID][]models.Allocation)
+	for rows.Next() {
+		var settlementID uuid.UUID
+		var allocation models.Allocation
+		if err := rows.Scan(&settlementID, &allocation.ExpenseID, &allocation.Amount, &allocation.Amount.Currency); err != nil {
+			return nil, err
+		}
+		result[settlementID] = append(result[settlementID], allocation)
+	}
+	return result, rows.Err()
+}
+
+// openDebt is what is left to pay off of one expense's debt.
+type openDebt struct {
+	expenseID uuid.UUID
+	open      models.Money
+}
+
+// fifo allocates amount over the debts in order, each up to what is open on
+// it, until it runs out.
+func fifo(amount models.Money, debts []openDebt) []models.Allocation {
+	allocations := []models.Allocation{}
+	left := amount.Minor
+	for _, debt := range debts {
+		if left == 0 {
+			break
+		}
+		part := min(left, debt.open.Minor)
+		if part <= 0 {
+			continue
+		}
+		allocations = append(allocations, models.Allocation{ExpenseID: debt.expenseID, Amount: models.NewMoney(part, amount.Currency)})
+		left -= part
+	}
+	return allocations
+}
+
+// checkExplicit returns the explicit allocations in the order of the debts,
+// or ErrInvalidAllocation when one is not for an open debt, is more than is
+// open on it, or they add up to more than amount.
+func checkExplicit(amount models.Money, debts []openDebt, explicit []models.Allocation) ([]models.Allocation, error) {
+	requested := make(map[uuid.UUID]models.Money, len(explicit))
+	var total int64
+	for _, allocation := range explicit {
+		if _, ok := requested[allocation.ExpenseID]; ok {
+			return nil, fmt.Errorf("%w: expense %s appears more than once", ErrInvalidAllocation, allocation.ExpenseID)
+		}
+		if !allocation.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: amounts must be positive", ErrInvalidAllocation)
+		}
+		requested[allocation.ExpenseID] = allocation.Amount
+		total += allocation.Amount.Minor
+	}
+	if total > amount.Minor {
+		return nil, fmt.Errorf("%w: allocations add up to more than the settlement", ErrInvalidAllocation)
+	}
+
+	allocations := []models.Allocation{}
+	for _, debt := range debts {
+		part, ok := requested[debt.expenseID]
+		if !ok {
+			continue
+		}
+		if part.Minor > debt.open.Minor {
+			return nil, fmt.Errorf("%w: only %s is open on expense %s", ErrInvalidAllocation, debt.open, debt.expenseID)
+		}
+		allocations = append(allocations, models.Allocation{ExpenseID: debt.expenseID, Amount: models.NewMoney(part.Minor, amount.Currency)})
+		delete(requested, debt.expenseID)
+	}
+	for expenseID := range requested {
+		return nil, fmt.Errorf("%w: the payer owes the payee nothing for expense %s in the settlement's currency", ErrInvalidAllocation, expenseID)
+	}
+	return allocations, nil
+}
+
+// loadDebts returns what every split of the group's expenses matching cond
+// owes each payer, oldest expense first, with what settlements other than
+// exclude were allocated to it. cond is on expenses e and its arguments start
+// at $2.
+func loadDebts(q Querier, exclude, groupID uuid.UUID, cond string, args ...any) ([]models.ExpenseSettlement, error) {
+	rows, err := q.Query(`SELECT e.id, e.description, e.currency, e.incurred_on FROM expenses e
+		WHERE e.group_id = $1 AND e.deleted_at IS NULL AND `+cond+`
+		ORDER BY e.incurred_on, e.created_at, e.id`, append([]any{groupID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []models.ExpenseSettlement
+	var ids database.UUIDs
+	for rows.Next() {
+		var expense models.ExpenseSettlement
+		var incurredOn time.Time
+		if err := rows.Scan(&expense.ExpenseID, &expense.Description, &expense.Currency, &incurredOn); err != nil {
+			return nil, err
+		}
+		expense.IncurredOn = incurredOn.Format(models.DateLayout)
+		expense.Debts = []models.SplitDebt{}
+		expenses = append(expenses, expense)
+		ids = append(ids, expense.ExpenseID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(expenses) == 0 {
+		return expenses, nil
+	}
+
+	payers, err := loadShares(q, `SELECT expense_id, user_id, amount FROM expense_payers
+		WHERE expense_id = ANY($1::uuid[]) ORDER BY expense_id, user_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := loadShares(q, `SELECT expense_id, user_id, amount FROM expense_splits
+		WHERE expense_id = ANY($1::uuid[]) ORDER BY expense_id, user_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := loadAllocated(q, exclude, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range expenses {
+		expense := &expenses[i]
+		debts, err := splitDebts(payers[expense.ExpenseID], splits[expense.ExpenseID], expense.Currency)
+		if err != nil {
+			return nil, err
+		}
+		for _, debt := range debts {
+			key := allocationKey{expense.ExpenseID, debt.DebtorID, debt.CreditorID}
+			debt.Paid = models.NewMoney(allocated[key].paid, expense.Currency)
+			debt.Pending = models.NewMoney(allocated[key].pending, expense.Currency)
+			debt.Outstanding = models.NewMoney(max(debt.Owed.Minor-debt.Paid.Minor, 0), expense.Currency)
+			debt.Settled = debt.Outstanding.IsZero()
+			expense.Debts = append(expense.Debts, debt)
+		}
+	}
+	return expenses, nil
+}
+
+// share is a user's part of an expense, paid or owed.
+type share struct {
+	userID uuid.UUID
+	amount models.Money
+}
+
+// splitDebts works out what each split owes each payer, the way balances
+// does: every split is divided over the payers in proportion to what they
+// paid. What a payer owes themselves is left out.
+func splitDebts(payers, splits []share, currency string) ([]models.SplitDebt, error) {
+	if len(payers) == 0 {
+		return nil, nil
+	}
+	weights := make([]int64, len(payers))
+	for i, payer := range payers {
+		weights[i] = payer.amount.Minor
+	}
+
+	var debts []models.SplitDebt
+	for _, split := range splits {
+		owed, err := split.amount.Allocate(weights)
+		if err != nil {
+			return nil, err
+		}
+		for j, payer := range payers {
+			if payer.userID == split.userID || !owed[j].IsPositive() {
+				continue
+			}
+			debts = append(debts, models.SplitDebt{
+				DebtorID:   split.userID,
+				CreditorID: payer.userID,
+				Owed:       models.NewMoney(owed[j].Minor, currency),
+			})
+		}
+	}
+	return debts, nil
+}
+
+func loadShares(q Querier, query string, ids database.UUIDs) (map[uuid.UUID][]share, error) {
+	rows, err := q.Query(query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[uuid.UUID][]share)
+	for rows.Next() {
+		var expenseID uuid.UUID
+		var s share
+		if err := rows.Scan(&expenseID, &s.userID, &s.amount); err != nil {
+			return nil, err
+		}
+		result[expenseID] = append(result[expenseID], s)
+	}
+	return result, rows.Err()
+}
+
+type allocationKey struct {
+	expenseID, debtor, creditor uuid.UUID
+}
+
+type allocatedAmounts struct {
+	paid, pending int64
+}
+
+// loadAllocated sums what confirmed and pending settlements other than
+// exclude were allocated to each debt of the expenses. Rejected, cancelled
+// and deleted settlements don't pay anything off.
+func loadAllocated(q Querier, exclude uuid.UUID, ids database.UUIDs) (map[allocationKey]allocatedAmounts, error) {
+	rows, err := q.Query(`SELECT a.expense_id, s.paid_by, s.paid_to,
+			COALESCE(sum(a.amount) FILTER (WHERE s.status = 'confirmed'), 0),
+			COALESCE(sum(a.amount) FILTER (WHERE s.status = 'pending'), 0)
+		FROM settlement_allocations a
+		JOIN settlements s ON s.id = a.settlement_id
+		WHERE a.expense_id = ANY($1::uuid[]) AND s.id <> $2 AND s.deleted_at IS NULL
+			AND s.status IN ('confirmed', 'pending')
+		GROUP BY a.expense_id, s.paid_by, s.paid_to`, ids, exclude)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[allocationKey]allocatedAmounts)
+	for rows.Next() {
+		var key allocationKey
+		var paid, pending models.Money
+		if err := rows.Scan(&key.expenseID, &key.debtor, &key.creditor, &paid, &pending); err != nil {
+			return nil, err
+		}
+		result[key] = allocatedAmounts{paid: paid.Minor, pending: pending.Minor}
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,132 @@
+package allocations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	userA    = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	userB    = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	userC    = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	expense1 = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	expense2 = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	expense3 = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+)
+
+func TestFIFO(t *testing.T) {
+	debts := []openDebt{
+		{expenseID: expense1, open: models.NewMoney(1000, "EUR")},
+		{expenseID: expense2, open: models.NewMoney(0, "EUR")},
+		{expenseID: expense3, open: models.NewMoney(3000, "EUR")},
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		want   []models.Allocation
+	}{
+		{"within the oldest", 600, []models.Allocation{{ExpenseID: expense1, Amount: models.NewMoney(600, "EUR")}}},
+		{"skips paid off debts", 2500, []models.Allocation{
+			{ExpenseID: expense1, Amount: models.NewMoney(1000, "EUR")},
+			{ExpenseID: expense3, Amount: models.NewMoney(1500, "EUR")},
+		}},
+		{"more than is owed", 5000, []models.Allocation{
+			{ExpenseID: expense1, Amount: models.NewMoney(1000, "EUR")},
+			{ExpenseID: expense3, Amount: models.NewMoney(3000, "EUR")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fifo(models.NewMoney(tt.amount, "EUR"), debts)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("allocation %d: expected %+v, got %+v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckExplicit(t *testing.T) {
+	debts := []openDebt{
+		{expenseID: expense1, open: models.NewMoney(1000, "EUR")},
+		{expenseID: expense2, open: models.NewMoney(3000, "EUR")},
+	}
+
+	// allocations come back oldest expense first
+	got, err := checkExplicit(models.NewMoney(2500, "EUR"), debts, []models.Allocation{
+		{ExpenseID: expense2, Amount: models.NewMoney(2000, "")},
+		{ExpenseID: expense1, Amount: models.NewMoney(500, "")},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	want := []models.Allocation{
+		{ExpenseID: expense1, Amount: models.NewMoney(500, "EUR")},
+		{ExpenseID: expense2, Amount: models.NewMoney(2000, "EUR")},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	invalid := []struct {
+		name     string
+		explicit []models.Allocation
+	}{
+		{"more than is open", []models.Allocation{{ExpenseID: expense1, Amount: models.NewMoney(1500, "")}}},
+		{"more than the settlement", []models.Allocation{
+			{ExpenseID: expense1, Amount: models.NewMoney(1000, "")},
+			{ExpenseID: expense2, Amount: models.NewMoney(2000, "")},
+		}},
+		{"nothing owed", []models.Allocation{{ExpenseID: expense3, Amount: models.NewMoney(100, "")}}},
+		{"zero amount", []models.Allocation{{ExpenseID: expense1}}},
+		{"duplicate expense", []models.Allocation{
+			{ExpenseID: expense1, Amount: models.NewMoney(100, "")},
+			{ExpenseID: expense1, Amount: models.NewMoney(100, "")},
+		}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkExplicit(models.NewMoney(2500, "EUR"), debts, tt.explicit)
+			if !errors.Is(err, ErrInvalidAllocation) {
+				t.Errorf("expected ErrInvalidAllocation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSplitDebts(t *testing.T) {
+	// A paid 30.00 and B 10.00 of a 40.00 expense split between all three
+	payers := []share{{userID: userA, amount: models.NewMoney(3000, "")}, {userID: userB, amount: models.NewMoney(1000, "")}}
+	splits := []share{
+		{userID: userA, amount: models.NewMoney(1334, "")},
+		{userID: userB, amount: models.NewMoney(1333, "")},
+		{userID: userC, amount: models.NewMoney(1333, "")},
+	}
+
+	debts, err := splitDebts(payers, splits, "EUR")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	want := []models.SplitDebt{
+		{DebtorID: userA, CreditorID: userB, Owed: models.NewMoney(333, "EUR")},
+		{DebtorID: userB, CreditorID: userA, Owed: models.NewMoney(1000, "EUR")},
+		{DebtorID: userC, CreditorID: userA, Owed: models.NewMoney(1000, "EUR")},
+		{DebtorID: userC, CreditorID: userB, Owed: models.NewMoney(333, "EUR")},
+	}
+	if len(debts) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, debts)
+	}
+	for i := range want {
+		if debts[i] != want[i] {
+			t.Errorf("debt %d: expected %+v, got %+v", i, want[i], debts[i])
+		}
+	}
+}
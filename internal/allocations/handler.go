@@ -0,0 +1,113 @@
+package allocations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetExpenseSettlement godoc
+// @Summary      Get how far an expense has been settled
+// @Description  Returns what each split of the expense owes each payer, what confirmed and pending settlements were allocated to it and what is still outstanding. settled_for_me is set when nothing is outstanding on the debts the caller owes or is owed.
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        expenseId  path      string  true  "Expense ID"
+// @Success      200        {object}  models.ExpenseSettlement
+// @Failure      400        {string}  string  "invalid ID"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/settlement [get]
+func (h *Handler) GetExpenseSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	expense, err := h.service.GetExpenseSettlement(groupID, expenseID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(expense)
+}
+
+// GetExpenseSettlements godoc
+// @Summary      List how far the caller's expenses have been settled
+// @Description  Returns the group's expenses the caller owes or is owed for, oldest first, with what each split owes each payer and what is still outstanding. Pass settled to keep only the expenses that are, or aren't, settled for the caller.
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  true   "Group ID"
+// @Param        settled  query     bool    false  "Only expenses settled, or not settled, for the caller"
+// @Success      200      {array}   models.ExpenseSettlement
+// @Failure      400      {string}  string  "invalid ID or query parameter"
+// @Failure      401      {string}  string  "unauthorized"
+// @Failure      403      {string}  string  "forbidden"
+// @Failure      500      {string}  string  "internal error"
+// @Router       /api/groups/{id}/expense-settlements [get]
+func (h *Handler) GetExpenseSettlements(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	var settled *bool
+	if value := r.URL.Query().Get("settled"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "invalid settled", http.StatusBadRequest)
+			return
+		}
+		settled = &parsed
+	}
+
+	expenses, err := h.service.GetExpenseSettlements(groupID, userID, settled)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(expenses)
+}
@@ -0,0 +1,82 @@
+package allocations
+
+import (
+	"database/sql"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Service reports how far the debts of each expense have been paid off by the
+// settlements allocated to them. Allocating settlements is up to settlements,
+// through Allocate.
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// GetExpenseSettlement returns how far the debts of an expense in the group
+// have been paid off, with SettledForMe from userID's point of view. It
+// returns sql.ErrNoRows when the expense is not in the group.
+func (s *Service) GetExpenseSettlement(groupID, expenseID, userID uuid.UUID) (models.ExpenseSettlement, error) {
+	expenses, err := loadDebts(s.db, uuid.Nil, groupID, `e.id = $2`, expenseID)
+	if err != nil {
+		return models.ExpenseSettlement{}, err
+	}
+	if len(expenses) == 0 {
+		return models.ExpenseSettlement{}, sql.ErrNoRows
+	}
+	expense := expenses[0]
+	expense.SettledForMe = settledFor(expense.Debts, userID)
+	return expense, nil
+}
+
+// GetExpenseSettlements returns how far the debts of the group's expenses that
+// userID owes or is owed for have been paid off, oldest first. When settled
+// is not nil only the expenses that are, or aren't, settled for userID are
+// returned.
+func (s *Service) GetExpenseSettlements(groupID, userID uuid.UUID, settled *bool) ([]models.ExpenseSettlement, error) {
+	expenses, err := loadDebts(s.db, uuid.Nil, groupID,
+		`(EXISTS (SELECT 1 FROM expense_splits s WHERE s.expense_id = e.id AND s.user_id = $2)
+		OR EXISTS (SELECT 1 FROM expense_payers p WHERE p.expense_id = e.id AND p.user_id = $2))`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.ExpenseSettlement{}
+	for _, expense := range expenses {
+		if !involves(expense.Debts, userID) {
+			continue
+		}
+		expense.SettledForMe = settledFor(expense.Debts, userID)
+		if settled != nil && expense.SettledForMe != *settled {
+			continue
+		}
+		result = append(result, expense)
+	}
+	return result, nil
+}
+
+// involves reports whether userID owes or is owed any of the debts.
+func involves(debts []models.SplitDebt, userID uuid.UUID) bool {
+	for _, debt := range debts {
+		if debt.DebtorID == userID || debt.CreditorID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// settledFor reports whether nothing is outstanding on the debts userID owes
+// or is owed.
+func settledFor(debts []models.SplitDebt, userID uuid.UUID) bool {
+	for _, debt := range debts {
+		if (debt.DebtorID == userID || debt.CreditorID == userID) && !debt.Settled {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,271 @@
+package allocations_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/allocations"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestAllocations(t *testing.T) {
+	var ana, bruno, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"ana@test.com", &ana}, {"bruno@test.com", &bruno}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, bruno); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	expenseService := expenses.NewService(testDB)
+	settlementService := settlements.NewService(testDB)
+	service := allocations.NewService(testDB)
+
+	// ana pays for both; bruno owes 15.00 for the dinner and 10.00 for the taxi
+	var dinner, taxi models.Expense
+	for _, e := range []struct {
+		description string
+		amount      int64
+		day         int
+		expense     *models.Expense
+	}{{"Dinner", 3000, 1, &dinner}, {"Taxi", 2000, 2, &taxi}} {
+		*e.expense, err = expenseService.CreateExpense(groupID, ana, expenses.ExpenseInput{
+			Description: e.description,
+			Amount:      models.NewMoney(e.amount, ""),
+			SplitType:   models.SplitEqual,
+			Splits:      []expenses.SplitInput{{UserID: ana}, {UserID: bruno}},
+			IncurredOn:  time.Date(2024, 3, e.day, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatalf("failed to create expense: %s", err)
+		}
+	}
+
+	// a new settlement pays off the oldest expense first
+	settlement, err := settlementService.CreateSettlement(groupID, bruno, ana, models.NewMoney(2000, ""), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+	if len(settlement.Allocations) != 2 ||
+		settlement.Allocations[0] != (models.Allocation{ExpenseID: dinner.ID, Amount: models.NewMoney(1500, "EUR")}) ||
+		settlement.Allocations[1] != (models.Allocation{ExpenseID: taxi.ID, Amount: models.NewMoney(500, "EUR")}) {
+		t.Fatalf("expected 15.00 to the dinner and 5.00 to the taxi, got %+v", settlement.Allocations)
+	}
+
+	// pending settlements don't settle anything yet
+	got, err := service.GetExpenseSettlement(groupID, dinner.ID, bruno)
+	if err != nil {
+		t.Fatalf("failed to get expense settlement: %s", err)
+	}
+	if got.SettledForMe || len(got.Debts) != 1 || got.Debts[0].Pending != models.NewMoney(1500, "EUR") ||
+		got.Debts[0].Outstanding != models.NewMoney(1500, "EUR") {
+		t.Errorf("expected 15.00 pending and outstanding, got %+v", got)
+	}
+
+	if _, err := settlementService.ConfirmSettlement(groupID, settlement.ID, ana); err != nil {
+		t.Fatalf("failed to confirm settlement: %s", err)
+	}
+	got, err = service.GetExpenseSettlement(groupID, dinner.ID, ana)
+	if err != nil {
+		t.Fatalf("failed to get expense settlement: %s", err)
+	}
+	if !got.SettledForMe || !got.Debts[0].Settled || got.Debts[0].Paid != models.NewMoney(1500, "EUR") {
+		t.Errorf("expected the dinner settled, got %+v", got)
+	}
+
+	settled := false
+	open, err := service.GetExpenseSettlements(groupID, bruno, &settled)
+	if err != nil {
+		t.Fatalf("failed to list expense settlements: %s", err)
+	}
+	if len(open) != 1 || open[0].ExpenseID != taxi.ID || open[0].Debts[0].Outstanding != models.NewMoney(500, "EUR") {
+		t.Errorf("expected only the taxi with 5.00 outstanding, got %+v", open)
+	}
+
+	// explicit allocations have to fit what is still open
+	second, err := settlementService.CreateSettlement(groupID, bruno, ana, models.NewMoney(500, ""), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+	for name, explicit := range map[string][]models.Allocation{
+		"already paid":             {{ExpenseID: dinner.ID, Amount: models.NewMoney(100, "")}},
+		"more than the settlement": {{ExpenseID: taxi.ID, Amount: models.NewMoney(600, "")}},
+	} {
+		_, err := settlementService.AllocateSettlement(groupID, second.ID, bruno, false, explicit)
+		if !errors.Is(err, allocations.ErrInvalidAllocation) {
+			t.Errorf("%s: expected ErrInvalidAllocation, got %v", name, err)
+		}
+	}
+	second, err = settlementService.AllocateSettlement(groupID, second.ID, bruno, false, []models.Allocation{})
+	if err != nil {
+		t.Fatalf("failed to clear allocations: %s", err)
+	}
+	if len(second.Allocations) != 0 {
+		t.Errorf("expected no allocations, got %+v", second.Allocations)
+	}
+	second, err = settlementService.AllocateSettlement(groupID, second.ID, bruno, false,
+		[]models.Allocation{{ExpenseID: taxi.ID, Amount: models.NewMoney(500, "")}})
+	if err != nil {
+		t.Fatalf("failed to allocate settlement: %s", err)
+	}
+	if len(second.Allocations) != 1 || second.Allocations[0].ExpenseID != taxi.ID {
+		t.Errorf("expected 5.00 to the taxi, got %+v", second.Allocations)
+	}
+
+	// a rejected settlement gives its allocations back
+	if _, err := settlementService.RejectSettlement(groupID, second.ID, ana, "not received"); err != nil {
+		t.Fatalf("failed to reject settlement: %s", err)
+	}
+	got, err = service.GetExpenseSettlement(groupID, taxi.ID, bruno)
+	if err != nil {
+		t.Fatalf("failed to get expense settlement: %s", err)
+	}
+	if !got.Debts[0].Pending.IsZero() || got.Debts[0].Outstanding != models.NewMoney(500, "EUR") {
+		t.Errorf("expected 5.00 outstanding and nothing pending, got %+v", got)
+	}
+
+	if _, err := service.GetExpenseSettlement(groupID, uuid.New(), ana); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an unknown expense, got %v", err)
+	}
+}
+
+func TestAllocate_Concurrent(t *testing.T) {
+	var carla, dan, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"carla@test.com", &carla}, {"dan@test.com", &dan}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", carla).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, dan); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	// dan owes carla 10.00 for the groceries
+	groceries, err := expenses.NewService(testDB).CreateExpense(groupID, carla, expenses.ExpenseInput{
+		Description: "Groceries",
+		Amount:      models.NewMoney(2000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: carla}, {UserID: dan}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	// two settlements at once can't both pay off the same 10.00
+	settlementService := settlements.NewService(testDB)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := settlementService.CreateSettlement(groupID, dan, carla, models.NewMoney(1000, ""), nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("failed to create settlement: %s", err)
+		}
+	}
+
+	got, err := allocations.NewService(testDB).GetExpenseSettlement(groupID, groceries.ID, dan)
+	if err != nil {
+		t.Fatalf("failed to get expense settlement: %s", err)
+	}
+	if len(got.Debts) != 1 || got.Debts[0].Pending != models.NewMoney(1000, "EUR") {
+		t.Errorf("expected 10.00 pending on the groceries, got %+v", got.Debts)
+	}
+}
@@ -0,0 +1,50 @@
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get for keys that hold nothing.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the bytes of attachments. Keys are slash-separated paths
+// chosen by the service, such as "{groupID}/{expenseID}/{attachmentID}".
+type BlobStore interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrBlobNotFound when nothing is stored under key.
+	Get(key string) (io.ReadCloser, error)
+	// Delete succeeds when nothing is stored under key.
+	Delete(key string) error
+}
+
+// NewStoreFromEnv returns the store BLOB_STORE names: "local" (the default)
+// keeps blobs under BLOB_DIR, "s3" in the S3_BUCKET bucket of an
+// S3-compatible service at S3_ENDPOINT.
+func NewStoreFromEnv() (BlobStore, error) {
+	switch kind := strings.ToLower(os.Getenv("BLOB_STORE")); kind {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "data/attachments"
+		}
+		return NewLocalStore(dir), nil
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    region,
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", kind)
+	}
+}
@@ -0,0 +1,266 @@
+package attachments
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+	urls    *URLSigner
+}
+
+func NewHandler(service *Service, urls *URLSigner) *Handler {
+	return &Handler{service: service, urls: urls}
+}
+
+// UploadAttachment godoc
+// @Summary      Attach a file to an expense
+// @Description  Uploads a receipt or other file as the "file" field of a multipart form. JPEG, PNG, GIF, WebP and PDF files of up to 10 MiB are accepted; the type is detected from the content. JPEG, PNG and GIF images get a thumbnail.
+// @Tags         attachments
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        expenseId  path      string  true  "Expense ID"
+// @Param        file       formData  file    true  "File to attach"
+// @Success      201        {object}  models.Attachment
+// @Failure      400        {string}  string  "invalid request"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      413        {string}  string  "file too large"
+// @Failure      415        {string}  string  "unsupported file type"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/attachments [post]
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionAddExpense) {
+		http.Error(w, "you do not have permission to attach files", http.StatusForbidden)
+		return
+	}
+
+	tooLarge := fmt.Sprintf("file must be at most %d MiB", MaxSize>>20)
+	// room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, MaxSize+1<<20)
+	file, header, err := r.FormFile("file")
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "expected a multipart form with a file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxSize+1))
+	if err != nil {
+		http.Error(w, "could not read file", http.StatusBadRequest)
+		return
+	}
+	if len(data) > MaxSize {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "file is empty", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.service.Upload(groupID, expenseID, userID, header.Filename, data)
+	if errors.Is(err, ErrUnsupportedType) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// GetAttachments godoc
+// @Summary      List an expense's attachments
+// @Description  The download URLs in the response are signed and expire after 15 minutes; list again for fresh ones.
+// @Tags         attachments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        expenseId  path      string  true  "Expense ID"
+// @Success      200        {array}   models.Attachment
+// @Failure      400        {string}  string  "invalid ID"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/attachments [get]
+func (h *Handler) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := h.service.GetAttachments(groupID, expenseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// DeleteAttachment godoc
+// @Summary      Delete an attachment
+// @Description  Members can delete the files they uploaded; owners and admins can delete any.
+// @Tags         attachments
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        expenseId     path      string  true  "Expense ID"
+// @Param        attachmentId  path      string  true  "Attachment ID"
+// @Success      204
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "attachment not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/attachments/{attachmentId} [delete]
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+	attachmentID, err := uuid.Parse(r.PathValue("attachmentId"))
+	if err != nil {
+		http.Error(w, "invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionAddExpense) {
+		http.Error(w, "you do not have permission to delete attachments", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.DeleteAttachment(groupID, expenseID, attachmentID, userID, role.Can(models.PermissionEditAnyExpense))
+	if errors.Is(err, ErrNotUploader) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetContent godoc
+// @Summary      Download an attachment
+// @Description  Serves the file or its thumbnail. The request is authorized by the signature in the URL, so use the url and thumbnail_url of an attachment as they are; no bearer token is needed.
+// @Tags         attachments
+// @Produce      octet-stream
+// @Param        attachmentId  path      string  true   "Attachment ID"
+// @Param        variant       query     string  true   "original or thumbnail"
+// @Param        expires       query     int     true   "Expiry, as a Unix time"
+// @Param        sig           query     string  true   "Signature"
+// @Success      200           {file}    file
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      403           {string}  string  "invalid or expired link"
+// @Failure      404           {string}  string  "attachment not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/attachments/{attachmentId}/content [get]
+func (h *Handler) GetContent(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := uuid.Parse(r.PathValue("attachmentId"))
+	if err != nil {
+		http.Error(w, "invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	variant := query.Get("variant")
+	if !h.urls.Verify(attachmentID, variant, query.Get("expires"), query.Get("sig")) {
+		http.Error(w, "invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	body, contentType, fileName, err := h.service.Content(attachmentID, variant)
+	if err == sql.ErrNoRows {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	// only images and PDFs are accepted, so browsers can show them in place
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, body)
+}
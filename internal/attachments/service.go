@@ -0,0 +1,261 @@
+package attachments
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// MaxSize is the largest file that can be attached, in bytes.
+const MaxSize = 10 << 20
+
+var (
+	// ErrUnsupportedType is returned for files that aren't images or PDFs.
+	ErrUnsupportedType = errors.New("only JPEG, PNG, GIF, WebP and PDF files can be attached")
+	// ErrNotUploader is returned when a member who can't edit every expense
+	// deletes someone else's attachment.
+	ErrNotUploader = errors.New("only the uploader can delete this attachment")
+)
+
+// allowedTypes are the content types that can be attached, as sniffed from
+// the file itself. thumbnail marks the ones Thumbnail can decode.
+var allowedTypes = map[string]struct{ thumbnail bool }{
+	"image/jpeg":      {thumbnail: true},
+	"image/png":       {thumbnail: true},
+	"image/gif":       {thumbnail: true},
+	"image/webp":      {},
+	"application/pdf": {},
+}
+
+type Service struct {
+	db    *sql.DB
+	store BlobStore
+	urls  *URLSigner
+}
+
+func NewService(db *sql.DB, store BlobStore, urls *URLSigner) *Service {
+	return &Service{db: db, store: store, urls: urls}
+}
+
+const attachmentColumns = `id, group_id, expense_id, file_name, content_type, size, thumbnail_key IS NOT NULL, uploaded_by, created_at`
+
+// Upload stores data as an attachment of the expense. The content type is
+// sniffed from data rather than trusted from the client, and images get a
+// thumbnail when they can be decoded. It returns sql.ErrNoRows when the
+// expense is not in the group and ErrUnsupportedType for other files.
+func (s *Service) Upload(groupID, expenseID, userID uuid.UUID, fileName string, data []byte) (models.Attachment, error) {
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	kind, ok := allowedTypes[contentType]
+	if !ok {
+		return models.Attachment{}, ErrUnsupportedType
+	}
+
+	var found bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM expenses WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL)`, expenseID, groupID).Scan(&found)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if !found {
+		return models.Attachment{}, sql.ErrNoRows
+	}
+
+	id := uuid.New()
+	key := groupID.String() + "/" + expenseID.String() + "/" + id.String()
+	if err := s.store.Put(key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return models.Attachment{}, err
+	}
+	keys := []string{key}
+
+	var thumbnailKey sql.NullString
+	if kind.thumbnail {
+		// an image we can't decode is still attached, just without a thumbnail
+		if thumb, err := Thumbnail(data); err == nil {
+			thumbnailKey = sql.NullString{String: key + "-thumbnail", Valid: true}
+			if err := s.store.Put(thumbnailKey.String, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+				s.deleteBlobs(keys...)
+				return models.Attachment{}, err
+			}
+			keys = append(keys, thumbnailKey.String)
+		}
+	}
+
+	attachment, err := s.scanAttachment(s.db.QueryRow(`INSERT INTO attachments (id, group_id, expense_id, file_name, content_type, size, blob_key, thumbnail_key, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING `+attachmentColumns,
+		id, groupID, expenseID, cleanFileName(fileName), contentType, len(data), key, thumbnailKey, userID))
+	if err != nil {
+		s.deleteBlobs(keys...)
+		return models.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// GetAttachments returns the expense's attachments, oldest first. It returns
+// sql.ErrNoRows when the expense is not in the group.
+func (s *Service) GetAttachments(groupID, expenseID uuid.UUID) ([]models.Attachment, error) {
+	var found bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM expenses WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL)`, expenseID, groupID).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := s.db.Query(`SELECT `+attachmentColumns+` FROM attachments WHERE expense_id = $1 ORDER BY created_at, id`, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		attachment, err := s.scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment deletes the attachment and its blobs. Unless deleteAny is
+// set, only the uploader can delete it and anyone else gets ErrNotUploader.
+func (s *Service) DeleteAttachment(groupID, expenseID, attachmentID, userID uuid.UUID, deleteAny bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var uploadedBy uuid.UUID
+	var key string
+	var thumbnailKey sql.NullString
+	err = tx.QueryRow(`SELECT uploaded_by, blob_key, thumbnail_key FROM attachments WHERE id = $1 AND expense_id = $2 AND group_id = $3 FOR UPDATE`,
+		attachmentID, expenseID, groupID).Scan(&uploadedBy, &key, &thumbnailKey)
+	if err != nil {
+		return err
+	}
+	if !deleteAny && uploadedBy != userID {
+		return ErrNotUploader
+	}
+	if _, err := tx.Exec(`DELETE FROM attachments WHERE id = $1`, attachmentID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	keys := []string{key}
+	if thumbnailKey.Valid {
+		keys = append(keys, thumbnailKey.String)
+	}
+	s.deleteBlobs(keys...)
+	return nil
+}
+
+// PurgeDeleted deletes the attachments of expenses that were moved to the
+// trash before cutoff, with their blobs, ahead of the expenses themselves
+// being purged. It returns how many there were.
+func (s *Service) PurgeDeleted(cutoff time.Time) (int64, error) {
+	rows, err := s.db.Query(`DELETE FROM attachments a USING expenses e
+		WHERE e.id = a.expense_id AND e.deleted_at < $1
+		RETURNING a.blob_key, a.thumbnail_key`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int64
+	var keys []string
+	for rows.Next() {
+		var key string
+		var thumbnailKey sql.NullString
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			return 0, err
+		}
+		n++
+		keys = append(keys, key)
+		if thumbnailKey.Valid {
+			keys = append(keys, thumbnailKey.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	s.deleteBlobs(keys...)
+	return n, nil
+}
+
+// Content opens a variant of the attachment for download, returning its
+// content type and file name. It returns sql.ErrNoRows for unknown
+// attachments and for thumbnails of files that have none.
+func (s *Service) Content(attachmentID uuid.UUID, variant string) (io.ReadCloser, string, string, error) {
+	var key, contentType, fileName string
+	var thumbnailKey sql.NullString
+	err := s.db.QueryRow(`SELECT blob_key, thumbnail_key, content_type, file_name FROM attachments WHERE id = $1`, attachmentID).
+		Scan(&key, &thumbnailKey, &contentType, &fileName)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if variant == VariantThumbnail {
+		if !thumbnailKey.Valid {
+			return nil, "", "", sql.ErrNoRows
+		}
+		key, contentType = thumbnailKey.String, "image/jpeg"
+		fileName = strings.TrimSuffix(fileName, path.Ext(fileName)) + "-thumbnail.jpg"
+	}
+
+	body, err := s.store.Get(key)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, "", "", sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+	return body, contentType, fileName, nil
+}
+
+// deleteBlobs removes blobs whose rows are gone or were never written. A
+// failure only leaves an orphaned blob behind, so it is logged rather than
+// returned.
+func (s *Service) deleteBlobs(keys ...string) {
+	for _, key := range keys {
+		if err := s.store.Delete(key); err != nil {
+			log.Printf("attachments: deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+// cleanFileName keeps the base name of an uploaded file, without any
+// directories the client sent along.
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+func (s *Service) scanAttachment(row interface{ Scan(...any) error }) (models.Attachment, error) {
+	var attachment models.Attachment
+	var hasThumbnail bool
+	err := row.Scan(&attachment.ID, &attachment.GroupID, &attachment.ExpenseID, &attachment.FileName, &attachment.ContentType,
+		&attachment.Size, &hasThumbnail, &attachment.UploadedBy, &attachment.CreatedAt)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment.URL = s.urls.URL(attachment.ID, VariantOriginal)
+	if hasThumbnail {
+		attachment.ThumbnailURL = s.urls.URL(attachment.ID, VariantThumbnail)
+	}
+	return attachment, nil
+}
@@ -0,0 +1,178 @@
+package attachments_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/attachments"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestAttachments(t *testing.T) {
+	var ana, bruno, groupID uuid.UUID
+	for _, u := range []struct {
+		name, email string
+		id          *uuid.UUID
+	}{{"Ana", "ana@test.com", &ana}, {"Bruno", "bruno@test.com", &bruno}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.name, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, bruno); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+	var expenseID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, 'Dinner', '60.00', 'EUR') RETURNING id`,
+		groupID, ana).Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+
+	var receipt bytes.Buffer
+	if err := png.Encode(&receipt, image.NewGray(image.Rect(0, 0, 600, 800))); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	store := attachments.NewLocalStore(dir)
+	service := attachments.NewService(testDB, store, attachments.NewURLSigner("secret", attachments.URLTTL))
+
+	photo, err := service.Upload(groupID, expenseID, bruno, `C:\Users\bruno\receipt.png`, receipt.Bytes())
+	if err != nil {
+		t.Fatalf("failed to upload: %s", err)
+	}
+	if photo.ContentType != "image/png" || photo.FileName != "receipt.png" || photo.Size != int64(receipt.Len()) {
+		t.Errorf("expected receipt.png as image/png of %d bytes, got %+v", receipt.Len(), photo)
+	}
+	if photo.URL == "" || photo.ThumbnailURL == "" {
+		t.Errorf("expected a URL and a thumbnail URL, got %+v", photo)
+	}
+
+	pdf, err := service.Upload(groupID, expenseID, ana, "invoice.png", []byte("%PDF-1.4\n%âãÏÓ\n1 0 obj\n"))
+	if err != nil {
+		t.Fatalf("failed to upload: %s", err)
+	}
+	if pdf.ContentType != "application/pdf" || pdf.ThumbnailURL != "" {
+		t.Errorf("expected a PDF without a thumbnail, got %+v", pdf)
+	}
+
+	if _, err := service.Upload(groupID, expenseID, ana, "page.html", []byte("<html><script>alert(1)</script>")); !errors.Is(err, attachments.ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+	if _, err := service.Upload(groupID, uuid.New(), ana, "receipt.png", receipt.Bytes()); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	list, err := service.GetAttachments(groupID, expenseID)
+	if err != nil {
+		t.Fatalf("failed to get attachments: %s", err)
+	}
+	if len(list) != 2 || list[0].ID != photo.ID || list[1].ID != pdf.ID {
+		t.Errorf("expected the image then the PDF, got %+v", list)
+	}
+
+	body, contentType, _, err := service.Content(photo.ID, attachments.VariantThumbnail)
+	if err != nil {
+		t.Fatalf("failed to get thumbnail: %s", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if contentType != "image/jpeg" || len(data) == 0 {
+		t.Errorf("expected a JPEG thumbnail, got %s of %d bytes", contentType, len(data))
+	}
+	if _, _, _, err := service.Content(pdf.ID, attachments.VariantThumbnail); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a PDF thumbnail, got %v", err)
+	}
+
+	// members delete their own uploads; admins anyone's
+	if err := service.DeleteAttachment(groupID, expenseID, pdf.ID, bruno, false); !errors.Is(err, attachments.ErrNotUploader) {
+		t.Errorf("expected ErrNotUploader, got %v", err)
+	}
+	if err := service.DeleteAttachment(groupID, expenseID, photo.ID, bruno, false); err != nil {
+		t.Fatalf("failed to delete attachment: %s", err)
+	}
+	if _, _, _, err := service.Content(photo.ID, attachments.VariantOriginal); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows after delete, got %v", err)
+	}
+	if err := service.DeleteAttachment(groupID, expenseID, pdf.ID, bruno, true); err != nil {
+		t.Fatalf("failed to delete attachment: %s", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, groupID.String(), expenseID.String()))
+	if err != nil || len(entries) > 0 {
+		t.Errorf("expected the blobs to be deleted, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,61 @@
+package attachments
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1024, 512))
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 1024; x++ {
+			src.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, err := Thumbnail(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Errorf("expected 256x128, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, g, _, _ := img.At(128, 64).RGBA(); r>>8 < 180 || g>>8 > 30 {
+		t.Errorf("expected the thumbnail to stay red, got r=%d g=%d", r>>8, g>>8)
+	}
+
+	if _, err := Thumbnail([]byte("%PDF-1.4")); err == nil {
+		t.Error("expected an error for a file that isn't an image")
+	}
+}
+
+func TestThumbnail_SmallImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, err := Thumbnail(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if config.Width != 40 || config.Height != 30 {
+		t.Errorf("expected 40x30, got %dx%d", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,50 @@
+package attachments
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestURLSigner(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewURLSigner("secret", URLTTL)
+	signer.now = func() time.Time { return now }
+	id := uuid.New()
+
+	link, err := url.Parse(signer.URL(id, VariantThumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/api/attachments/" + id.String() + "/content"; link.Path != expected {
+		t.Errorf("expected path %s, got %s", expected, link.Path)
+	}
+	q := link.Query()
+	if !signer.Verify(id, q.Get("variant"), q.Get("expires"), q.Get("sig")) {
+		t.Error("expected the URL to verify")
+	}
+
+	if signer.Verify(uuid.New(), q.Get("variant"), q.Get("expires"), q.Get("sig")) {
+		t.Error("expected the signature not to verify for another attachment")
+	}
+	if signer.Verify(id, VariantOriginal, q.Get("expires"), q.Get("sig")) {
+		t.Error("expected the signature not to verify for another variant")
+	}
+	if signer.Verify(id, q.Get("variant"), q.Get("expires")+"0", q.Get("sig")) {
+		t.Error("expected the signature not to verify with a later expiry")
+	}
+	if NewURLSigner("other", URLTTL).Verify(id, q.Get("variant"), q.Get("expires"), q.Get("sig")) {
+		t.Error("expected the signature not to verify with another secret")
+	}
+
+	now = now.Add(URLTTL + time.Second)
+	if signer.Verify(id, q.Get("variant"), q.Get("expires"), q.Get("sig")) {
+		t.Error("expected the URL to expire")
+	}
+	if !strings.Contains(signer.URL(id, VariantOriginal), "variant=original") {
+		t.Error("expected a fresh URL for the original")
+	}
+}
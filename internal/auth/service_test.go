@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/IvanLouren/GoSplit/internal/auth"
@@ -55,12 +56,20 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
-	}
-	_, err = db.Exec(string(migration))
-	return err
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
 }
 
 func TestRegister(t *testing.T) {
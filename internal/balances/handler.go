@@ -2,8 +2,11 @@ package balances
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/internal/tags"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
@@ -18,13 +21,17 @@ func NewHandler(service *Service) *Handler {
 
 // GetBalances godoc
 // @Summary      Get net balances for all users in a group
+// @Description  Balances are converted into the group's currency using the exchange rate at each expense's and settlement's date, and also reported per original currency.
 // @Tags         balances
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      string  true  "Group ID"
+// @Param        id   path      string  true   "Group ID"
+// @Param        tag  query     string  false  "Only count expenses and settlements with this tag"
 // @Success      200  {array}   models.Balance
-// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      400  {string}  string  "invalid group ID or tag"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      409  {string}  string  "missing exchange rate"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/balances [get]
 func (h *Handler) GetBalances(w http.ResponseWriter, r *http.Request) {
@@ -35,7 +42,20 @@ func (h *Handler) GetBalances(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	balances, err := h.service.GetBalances(groupID)
+	tagID, ok := tagParam(w, r)
+	if !ok {
+		return
+	}
+
+	balances, err := h.service.GetBalances(groupID, tagID)
+	if errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rates.ErrNoRate) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -49,3 +69,166 @@ func (h *Handler) GetBalances(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(balances)
 }
+
+// GetDebts godoc
+// @Summary      Get the transfers that settle a group
+// @Description  In simplified mode the net balances are settled with as few transfers as possible; in pairwise mode each pair of people settles what they owe each other. Amounts are in the group's currency.
+// @Tags         balances
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true   "Group ID"
+// @Param        tag  query     string  false  "Only count expenses and settlements with this tag"
+// @Success      200  {object}  models.DebtPlan
+// @Failure      400  {string}  string  "invalid group ID or tag"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      409  {string}  string  "missing exchange rate"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/balances/simplified [get]
+func (h *Handler) GetDebts(w http.ResponseWriter, r *http.Request) {
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	tagID, ok := tagParam(w, r)
+	if !ok {
+		return
+	}
+
+	plan, err := h.service.GetDebts(groupID, tagID)
+	if errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rates.ErrNoRate) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// GetPairBalances godoc
+// @Summary      Get what each pair of users owe each other
+// @Description  Every pair of users who shared expenses or settled up, listed once from the side of the user who is owed, with the expenses and settlements behind the balance. Amounts are in the group's currency.
+// @Tags         balances
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true   "Group ID"
+// @Param        tag  query     string  false  "Only count expenses and settlements with this tag"
+// @Success      200  {array}   models.PairBalance
+// @Failure      400  {string}  string  "invalid group ID or tag"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      409  {string}  string  "missing exchange rate"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/balances/pairs [get]
+func (h *Handler) GetPairBalances(w http.ResponseWriter, r *http.Request) {
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	tagID, ok := tagParam(w, r)
+	if !ok {
+		return
+	}
+
+	pairs, err := h.service.GetPairBalances(groupID, tagID)
+	if errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rates.ErrNoRate) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pairs)
+}
+
+// GetUserPairBalances godoc
+// @Summary      Get what one user and each other user owe each other
+// @Description  Seen from the given user: a positive balance means the other user owes them, a negative one that they owe the other user.
+// @Tags         balances
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  true   "Group ID"
+// @Param        user_id  path      string  true   "User ID"
+// @Param        tag      query     string  false  "Only count expenses and settlements with this tag"
+// @Success      200  {array}   models.PairBalance
+// @Failure      400  {string}  string  "invalid ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      409  {string}  string  "missing exchange rate"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/balances/pairs/{user_id} [get]
+func (h *Handler) GetUserPairBalances(w http.ResponseWriter, r *http.Request) {
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	tagID, ok := tagParam(w, r)
+	if !ok {
+		return
+	}
+
+	pairs, err := h.service.GetUserPairBalances(groupID, userID, tagID)
+	if errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rates.ErrNoRate) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pairs)
+}
+
+// tagParam reads the optional tag query parameter, uuid.Nil when absent. It
+// writes the error response itself and returns false when it is invalid.
+func tagParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	value := r.URL.Query().Get("tag")
+	if value == "" {
+		return uuid.Nil, true
+	}
+	tagID, err := uuid.Parse(value)
+	if err != nil {
+		http.Error(w, "invalid tag", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return tagID, true
+}
@@ -0,0 +1,158 @@
+package balances
+
+import (
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ledger accumulates per-user balances in the base currency and per original
+// currency, and the converted debt between every pair of users.
+type ledger struct {
+	base       string
+	converted  map[uuid.UUID]models.Money
+	byCurrency map[uuid.UUID]map[string]models.Money
+	pairs      map[userPair]*pairEntry
+}
+
+// pairEntry is the debt between a pair and where it comes from.
+type pairEntry struct {
+	debt          models.Money
+	expenseIDs    []uuid.UUID
+	settlementIDs []uuid.UUID
+}
+
+// source is the expense or settlement behind a debt.
+type source struct {
+	id         uuid.UUID
+	settlement bool
+}
+
+// userPair is ordered so that first < second; a positive debt means first
+// owes second.
+type userPair struct {
+	first, second uuid.UUID
+}
+
+func newLedger(base string) *ledger {
+	return &ledger{
+		base:       base,
+		converted:  make(map[uuid.UUID]models.Money),
+		byCurrency: make(map[uuid.UUID]map[string]models.Money),
+		pairs:      make(map[userPair]*pairEntry),
+	}
+}
+
+func (l *ledger) add(userID uuid.UUID, original, converted models.Money) {
+	if _, ok := l.byCurrency[userID]; !ok {
+		l.byCurrency[userID] = make(map[string]models.Money)
+		l.converted[userID] = models.NewMoney(0, l.base)
+	}
+	l.converted[userID] = l.converted[userID].Add(converted)
+	l.byCurrency[userID][original.Currency] = l.byCurrency[userID][original.Currency].Add(original)
+}
+
+// balances lists users and their currencies in a stable order.
+func (l *ledger) balances() []models.Balance {
+	var result []models.Balance
+	for userID, currencies := range l.byCurrency {
+		balance := models.Balance{UserID: userID, Balance: l.converted[userID], Currency: l.base}
+		for currency, amount := range currencies {
+			balance.ByCurrency = append(balance.ByCurrency, models.CurrencyBalance{Currency: currency, Balance: amount})
+		}
+		sort.Slice(balance.ByCurrency, func(i, j int) bool { return balance.ByCurrency[i].Currency < balance.ByCurrency[j].Currency })
+		result = append(result, balance)
+	}
+	sort.Slice(result, func(i, j int) bool { return lessID(result[i].UserID, result[j].UserID) })
+	return result
+}
+
+// owe records that debtor owes creditor amount, in the base currency,
+// because of the given expense or settlement.
+func (l *ledger) owe(debtor, creditor uuid.UUID, amount models.Money, from source) {
+	if debtor == creditor || amount.IsZero() {
+		return
+	}
+
+	p := userPair{debtor, creditor}
+	if !lessID(debtor, creditor) {
+		p = userPair{creditor, debtor}
+		amount = amount.Neg()
+	}
+	entry, ok := l.pairs[p]
+	if !ok {
+		entry = &pairEntry{debt: models.NewMoney(0, l.base)}
+		l.pairs[p] = entry
+	}
+	entry.debt = entry.debt.Add(amount)
+
+	ids := &entry.expenseIDs
+	if from.settlement {
+		ids = &entry.settlementIDs
+	}
+	if n := len(*ids); n == 0 || (*ids)[n-1] != from.id {
+		*ids = append(*ids, from.id)
+	}
+}
+
+// pairwiseTransfers nets the debts between each pair of users.
+func (l *ledger) pairwiseTransfers() []models.Transfer {
+	var transfers []models.Transfer
+	for p, entry := range l.pairs {
+		debt := entry.debt
+		switch {
+		case debt.IsPositive():
+			transfers = append(transfers, models.Transfer{From: p.first, To: p.second, Amount: debt})
+		case debt.IsNegative():
+			transfers = append(transfers, models.Transfer{From: p.second, To: p.first, Amount: debt.Neg()})
+		}
+	}
+	sortTransfers(transfers)
+	return transfers
+}
+
+// pairBalances lists the pairs userID is part of from their side, or every
+// pair from the side of whoever is owed when userID is uuid.Nil.
+func (l *ledger) pairBalances(userID uuid.UUID) []models.PairBalance {
+	result := []models.PairBalance{}
+	for p, entry := range l.pairs {
+		// entry.debt > 0 means first owes second
+		balance := models.PairBalance{
+			UserID:        p.second,
+			OtherUserID:   p.first,
+			Balance:       entry.debt,
+			Currency:      l.base,
+			ExpenseIDs:    entry.expenseIDs,
+			SettlementIDs: entry.settlementIDs,
+		}
+
+		flip := entry.debt.IsNegative()
+		if userID != uuid.Nil {
+			if userID != p.first && userID != p.second {
+				continue
+			}
+			flip = userID == p.first
+		}
+		if flip {
+			balance.UserID, balance.OtherUserID = balance.OtherUserID, balance.UserID
+			balance.Balance = balance.Balance.Neg()
+		}
+
+		if balance.ExpenseIDs == nil {
+			balance.ExpenseIDs = []uuid.UUID{}
+		}
+		if balance.SettlementIDs == nil {
+			balance.SettlementIDs = []uuid.UUID{}
+		}
+		result = append(result, balance)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return lessID(result[i].UserID, result[j].UserID)
+		}
+		return lessID(result[i].OtherUserID, result[j].OtherUserID)
+	})
+	return result
+}
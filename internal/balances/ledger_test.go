@@ -0,0 +1,52 @@
+package balances
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+func TestLedgerPairwiseTransfers_SettleEveryBalance(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		users := make([]uuid.UUID, 2+r.Intn(5))
+		for i := range users {
+			r.Read(users[i][:])
+		}
+
+		// expenses and settlements both move money from one user to another
+		l := newLedger("EUR")
+		for i := 0; i < r.Intn(20); i++ {
+			payer := users[r.Intn(len(users))]
+			debtor := users[r.Intn(len(users))]
+			amount := models.NewMoney(1+r.Int63n(100000), "EUR")
+			l.add(payer, amount, amount)
+			l.add(debtor, amount.Neg(), amount.Neg())
+			l.owe(debtor, payer, amount, source{id: uuid.New()})
+		}
+
+		remaining := make(map[uuid.UUID]int64)
+		for _, b := range l.balances() {
+			remaining[b.UserID] = b.Balance.Minor
+		}
+		for _, transfer := range l.pairwiseTransfers() {
+			if !transfer.Amount.IsPositive() || transfer.From == transfer.To {
+				return false
+			}
+			remaining[transfer.From] += transfer.Amount.Minor
+			remaining[transfer.To] -= transfer.Amount.Minor
+		}
+		for _, left := range remaining {
+			if left != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,55 +2,292 @@ package balances
 
 import (
 	"database/sql"
+	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/internal/tags"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
 
 type Service struct {
-	db *sql.DB
+	db    *sql.DB
+	rates *rates.Service
 }
 
 func NewService(db *sql.DB) *Service {
-	return &Service{db: db}
-}
-
-func (s *Service) GetBalances(groupID uuid.UUID) ([]models.Balance, error) {
-	// single SQL query using UNION ALL
-	balances, err := s.db.Query(`SELECT user_id, SUM(amount) as balance 
-		FROM ( SELECT paid_by AS user_id, amount
-		   	   FROM expenses
-		       WHERE group_id = $1
-				UNION ALL
-			   SELECT expense_splits.user_id, -expense_splits.amount
-			   FROM expense_splits
-			   JOIN expenses ON expenses.id = expense_splits.expense_id
-			   WHERE expenses.group_id = $1
-			    UNION ALL
-			   SELECT paid_by as user_id, -amount
-			   FROM settlements
-			   WHERE group_id =$1
-				UNION ALL
-			   SELECT paid_to AS user_id, amount
-			   FROM settlements
-			   WHERE group_id = $1
-		) as entries
-		GROUP BY user_id
-	 `, groupID)
-	if err != nil {
-		return nil, err
-	}
-	defer balances.Close()
-
-	var result []models.Balance
-	for balances.Next() {
-		var balance models.Balance
-		err = balances.Scan(&balance.UserID, &balance.Balance)
+	return &Service{db: db, rates: rates.NewService(db)}
+}
+
+type expenseEntry struct {
+	id     uuid.UUID
+	amount models.Money
+	date   time.Time
+	payers []splitEntry
+	splits []splitEntry
+}
+
+// splitEntry is a user's part of an expense, either paid or owed.
+type splitEntry struct {
+	userID uuid.UUID
+	amount models.Money
+}
+
+// GetBalances returns every user's balance in the group's currency, plus the
+// unconverted balance per original currency. Each expense is converted with
+// the rate on the day it was incurred and its converted total is then
+// allocated over the payers and the splits, so converted balances still add
+// up to exactly zero. It returns an error wrapping rates.ErrNoRate when a
+// conversion has no rate.
+//
+// Every method takes a tag: when it is not uuid.Nil only the expenses and
+// settlements with that tag count, so a sub-event can be settled on its own.
+// They return tags.ErrUnknownTag when the tag is not the group's.
+func (s *Service) GetBalances(groupID, tagID uuid.UUID) ([]models.Balance, error) {
+	l, err := s.buildLedger(groupID, tagID, false)
+	if err != nil {
+		return nil, err
+	}
+	return l.balances(), nil
+}
+
+// GetDebts returns the transfers that settle the group, following its debt
+// mode: the fewest transfers between net balances when simplified, or the
+// netted debts between each pair of people when pairwise.
+func (s *Service) GetDebts(groupID, tagID uuid.UUID) (models.DebtPlan, error) {
+	return s.debts(groupID, tagID, false)
+}
+
+// GetOutstandingDebts is GetDebts counting pending settlements as if they
+// were confirmed, so it leaves out what is already on its way.
+func (s *Service) GetOutstandingDebts(groupID, tagID uuid.UUID) (models.DebtPlan, error) {
+	return s.debts(groupID, tagID, true)
+}
+
+func (s *Service) debts(groupID, tagID uuid.UUID, pending bool) (models.DebtPlan, error) {
+	var mode models.DebtMode
+	err := s.db.QueryRow(`SELECT debt_mode FROM groups WHERE id = $1`, groupID).Scan(&mode)
+	if err != nil {
+		return models.DebtPlan{}, err
+	}
+
+	l, err := s.buildLedger(groupID, tagID, pending)
+	if err != nil {
+		return models.DebtPlan{}, err
+	}
+
+	plan := models.DebtPlan{Mode: mode, Currency: l.base}
+	if mode == models.DebtModePairwise {
+		plan.Transfers = l.pairwiseTransfers()
+	} else {
+		plan.Transfers = SimplifyDebts(l.balances())
+	}
+	if plan.Transfers == nil {
+		plan.Transfers = []models.Transfer{}
+	}
+	return plan, nil
+}
+
+// GetPairBalances returns what each pair of users in the group owe each
+// other, with the expenses and settlements it comes from. Each pair is listed
+// once, from the side of the user who is owed.
+func (s *Service) GetPairBalances(groupID, tagID uuid.UUID) ([]models.PairBalance, error) {
+	l, err := s.buildLedger(groupID, tagID, false)
+	if err != nil {
+		return nil, err
+	}
+	return l.pairBalances(uuid.Nil), nil
+}
+
+// GetUserPairBalances returns what userID and each other user owe each other,
+// from userID's side: a positive balance means the other user owes userID.
+func (s *Service) GetUserPairBalances(groupID, userID, tagID uuid.UUID) ([]models.PairBalance, error) {
+	l, err := s.buildLedger(groupID, tagID, false)
+	if err != nil {
+		return nil, err
+	}
+	return l.pairBalances(userID), nil
+}
+
+// buildLedger adds up the group's expenses and confirmed settlements, and its
+// pending settlements too when pending is set.
+func (s *Service) buildLedger(groupID, tagID uuid.UUID, pending bool) (*ledger, error) {
+	var base string
+	err := s.db.QueryRow(`SELECT currency FROM groups WHERE id = $1`, groupID).Scan(&base)
+	if err != nil {
+		return nil, err
+	}
+
+	// a nil tag is sent as NULL, which every tag condition below lets through
+	tag := uuid.NullUUID{UUID: tagID, Valid: tagID != uuid.Nil}
+	if tag.Valid {
+		var found bool
+		err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tags WHERE id = $1 AND group_id = $2)`, tagID, groupID).Scan(&found)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, tags.ErrUnknownTag
+		}
+	}
+
+	table, err := s.rates.Table(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	expenses, err := s.loadExpenses(groupID, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	l := newLedger(base)
+	for _, e := range expenses {
+		converted, err := table.Convert(e.amount, base, e.date)
+		if err != nil {
+			return nil, err
+		}
+		if len(e.payers) == 0 {
+			continue
+		}
+		payerWeights := weightsOf(e.payers)
+		paid, err := converted.Allocate(payerWeights)
+		if err != nil {
+			return nil, err
+		}
+		for i, payer := range e.payers {
+			l.add(payer.userID, payer.amount, paid[i])
+		}
+
+		if len(e.splits) == 0 {
+			continue
+		}
+		parts, err := converted.Allocate(weightsOf(e.splits))
+		if err != nil {
+			return nil, err
+		}
+		for i, split := range e.splits {
+			l.add(split.userID, split.amount.Neg(), parts[i].Neg())
+			// each payer is owed their share of the split
+			owed, err := parts[i].Allocate(payerWeights)
+			if err != nil {
+				return nil, err
+			}
+			for j, payer := range e.payers {
+				l.owe(split.userID, payer.userID, owed[j], source{id: e.id})
+			}
+		}
+	}
+
+	settlements, err := s.db.Query(`SELECT id, paid_by, paid_to, amount, currency, created_at FROM settlements
+		WHERE group_id = $1 AND (status = 'confirmed' OR ($3 AND status = 'pending')) AND deleted_at IS NULL
+			AND ($2::uuid IS NULL OR EXISTS (SELECT 1 FROM settlement_tags t WHERE t.settlement_id = settlements.id AND t.tag_id = $2))
+		ORDER BY created_at, id`, groupID, tag, pending)
+	if err != nil {
+		return nil, err
+	}
+	defer settlements.Close()
+
+	for settlements.Next() {
+		var id, paidBy, paidTo uuid.UUID
+		var amount models.Money
+		var date time.Time
+		if err := settlements.Scan(&id, &paidBy, &paidTo, &amount, &amount.Currency, &date); err != nil {
+			return nil, err
+		}
+		converted, err := table.Convert(amount, base, date)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, balance)
+		// paying someone back raises the payer's balance and lowers the receiver's
+		l.add(paidBy, amount, converted)
+		l.add(paidTo, amount.Neg(), converted.Neg())
+		l.owe(paidTo, paidBy, converted, source{id: id, settlement: true})
+	}
+	if err := settlements.Err(); err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return l, nil
+}
+
+// expenseTagged is the condition on expenses that $2, a tag or NULL, puts.
+const expenseTagged = `($2::uuid IS NULL OR EXISTS (SELECT 1 FROM expense_tags t WHERE t.expense_id = expenses.id AND t.tag_id = $2))`
+
+func (s *Service) loadExpenses(groupID uuid.UUID, tag uuid.NullUUID) ([]*expenseEntry, error) {
+	rows, err := s.db.Query(`SELECT id, amount, currency, incurred_on FROM expenses
+		WHERE group_id = $1 AND deleted_at IS NULL AND `+expenseTagged+`
+		ORDER BY incurred_on, created_at, id`, groupID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []*expenseEntry
+	byID := make(map[uuid.UUID]*expenseEntry)
+	for rows.Next() {
+		e := &expenseEntry{}
+		if err := rows.Scan(&e.id, &e.amount, &e.amount.Currency, &e.date); err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+		byID[e.id] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	payers, err := s.db.Query(`SELECT expense_payers.expense_id, expense_payers.user_id, expense_payers.amount
+		FROM expense_payers
+		JOIN expenses ON expenses.id = expense_payers.expense_id
+		WHERE expenses.group_id = $1 AND expenses.deleted_at IS NULL AND `+expenseTagged+`
+		ORDER BY expense_payers.expense_id, expense_payers.user_id`, groupID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer payers.Close()
+
+	for payers.Next() {
+		var expenseID uuid.UUID
+		var payer splitEntry
+		if err := payers.Scan(&expenseID, &payer.userID, &payer.amount); err != nil {
+			return nil, err
+		}
+		e := byID[expenseID]
+		payer.amount.Currency = e.amount.Currency
+		e.payers = append(e.payers, payer)
+	}
+	if err := payers.Err(); err != nil {
+		return nil, err
+	}
+
+	splits, err := s.db.Query(`SELECT expense_splits.expense_id, expense_splits.user_id, expense_splits.amount
+		FROM expense_splits
+		JOIN expenses ON expenses.id = expense_splits.expense_id
+		WHERE expenses.group_id = $1 AND expenses.deleted_at IS NULL AND `+expenseTagged+`
+		ORDER BY expense_splits.expense_id, expense_splits.user_id`, groupID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer splits.Close()
+
+	for splits.Next() {
+		var expenseID uuid.UUID
+		var split splitEntry
+		if err := splits.Scan(&expenseID, &split.userID, &split.amount); err != nil {
+			return nil, err
+		}
+		e := byID[expenseID]
+		split.amount.Currency = e.amount.Currency
+		e.splits = append(e.splits, split)
+	}
+	return expenses, splits.Err()
+}
+
+func weightsOf(entries []splitEntry) []int64 {
+	weights := make([]int64, len(entries))
+	for i, entry := range entries {
+		weights[i] = entry.amount.Minor
+	}
+	return weights
 }
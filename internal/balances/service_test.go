@@ -3,12 +3,17 @@ package balances_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/IvanLouren/GoSplit/internal/balances"
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
@@ -51,12 +56,20 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
+		return fmt.Errorf("failed to list migrations: %w", err)
 	}
-	_, err = db.Exec(string(migration))
-	return err
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
 }
 
 func TestGetBalances(t *testing.T) {
@@ -75,7 +88,8 @@ func TestGetBalances(t *testing.T) {
 	}
 
 	var expenseID string
-	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, description, amount) VALUES ($1, $2, $3, $4) RETURNING id`,
+	err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+		INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
 		groupID, userID, "Dinner", 90.00).Scan(&expenseID)
 	if err != nil {
 		t.Fatalf("failed to insert expense: %s", err)
@@ -102,7 +116,7 @@ func TestGetBalances(t *testing.T) {
 
 	// call GetBalances
 	service := balances.NewService(testDB)
-	result, err := service.GetBalances(parsedGroupID)
+	result, err := service.GetBalances(parsedGroupID, uuid.Nil)
 	if err != nil {
 		t.Fatalf("failed to get balances: %s", err)
 	}
@@ -116,15 +130,654 @@ func TestGetBalances(t *testing.T) {
 	for _, b := range result {
 		switch b.UserID.String() {
 		case userID:
-			if b.Balance != 90.00 {
-				t.Errorf("expected test user balance 90, got %f", b.Balance)
+			if b.Balance.Minor != 9000 {
+				t.Errorf("expected test user balance 90, got %s", b.Balance)
 			}
 		case user2ID:
-			if b.Balance != -45.00 {
-				t.Errorf("expected user2 balance -45, got %f", b.Balance)
+			if b.Balance.Minor != -4500 {
+				t.Errorf("expected user2 balance -45, got %s", b.Balance)
 			}
 		default:
 			t.Errorf("unexpected user in balances: %s", b.UserID)
 		}
 	}
 }
+
+func TestGetBalances_Exact(t *testing.T) {
+	var userIDs []string
+	for _, email := range []string{"user3@test.com", "user4@test.com", "user5@test.com"} {
+		var userID string
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(&userID)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	var groupID string
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+		"Flat", userIDs[0]).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	// ten expenses of 0.10 split three ways would drift with float64
+	for i := 0; i < 10; i++ {
+		var expenseID string
+		err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, userIDs[0], "Gum", "0.10").Scan(&expenseID)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		for j, amount := range []string{"0.04", "0.03", "0.03"} {
+			_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`,
+				expenseID, userIDs[j], amount)
+			if err != nil {
+				t.Fatalf("failed to insert split: %s", err)
+			}
+		}
+	}
+
+	parsedGroupID, err := uuid.Parse(groupID)
+	if err != nil {
+		t.Fatalf("failed to parse groupID: %s", err)
+	}
+
+	service := balances.NewService(testDB)
+	result, err := service.GetBalances(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+
+	var total int64
+	for _, b := range result {
+		total += b.Balance.Minor
+		if b.UserID.String() == userIDs[0] && b.Balance.Minor != 60 {
+			t.Errorf("expected payer balance 0.60, got %s", b.Balance)
+		}
+	}
+	if total != 0 {
+		t.Errorf("expected balances to sum to exactly zero, got %d minor units", total)
+	}
+}
+
+func TestGetBalances_MultiCurrency(t *testing.T) {
+	var payerID, friendID string
+	for email, id := range map[string]*string{"user6@test.com": &payerID, "user7@test.com": &friendID} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID string
+	err := testDB.QueryRow(`INSERT INTO groups (name, currency, created_by) VALUES ($1, 'EUR', $2) RETURNING id`,
+		"London", payerID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	parsedGroupID := uuid.MustParse(groupID)
+
+	_, err = rates.NewService(testDB).SetRate(parsedGroupID, models.ExchangeRate{Base: "EUR", Quote: "GBP", Date: "2024-01-02", Rate: "0.8664", Source: rates.SourceManual})
+	if err != nil {
+		t.Fatalf("failed to set rate: %s", err)
+	}
+
+	var expenseID string
+	err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'GBP') RETURNING id, paid_by, amount)
+		INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+		groupID, payerID, "Theatre", "100.00").Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	for _, userID := range []string{payerID, friendID} {
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, "50.00")
+		if err != nil {
+			t.Fatalf("failed to insert split: %s", err)
+		}
+	}
+
+	service := balances.NewService(testDB)
+	result, err := service.GetBalances(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 balances, got %d", len(result))
+	}
+
+	// 100 GBP is 115.42 EUR, half of it each
+	var total int64
+	for _, b := range result {
+		total += b.Balance.Minor
+		if b.Currency != "EUR" {
+			t.Errorf("expected balance in EUR, got %s", b.Currency)
+		}
+		if len(b.ByCurrency) != 1 || b.ByCurrency[0].Currency != "GBP" {
+			t.Fatalf("expected a single GBP balance, got %v", b.ByCurrency)
+		}
+		want, wantGBP := int64(-5771), int64(-5000)
+		if b.UserID.String() == payerID {
+			want, wantGBP = 5771, 5000
+		}
+		if b.Balance.Minor != want {
+			t.Errorf("expected converted balance %d, got %d", want, b.Balance.Minor)
+		}
+		if b.ByCurrency[0].Balance.Minor != wantGBP {
+			t.Errorf("expected GBP balance %d, got %d", wantGBP, b.ByCurrency[0].Balance.Minor)
+		}
+	}
+	if total != 0 {
+		t.Errorf("expected converted balances to sum to zero, got %d minor units", total)
+	}
+
+	// an expense in a currency without a rate can't be converted
+	_, err = testDB.Exec(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'CHF') RETURNING id, paid_by, amount)
+		INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e`,
+		groupID, payerID, "Chocolate", "10.00")
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	if _, err := service.GetBalances(parsedGroupID, uuid.Nil); !errors.Is(err, rates.ErrNoRate) {
+		t.Errorf("expected ErrNoRate, got %v", err)
+	}
+}
+
+func TestGetDebts(t *testing.T) {
+	var ids []string
+	for _, email := range []string{"user8@test.com", "user9@test.com", "user10@test.com"} {
+		var id string
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+		ids = append(ids, id)
+	}
+
+	var groupID string
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Chain", ids[0]).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	parsedGroupID := uuid.MustParse(groupID)
+
+	// user 0 paid 30 for user 1, user 1 paid 30 for user 2: pairwise that's two
+	// debts, simplified user 2 pays user 0 directly
+	for _, e := range [][2]string{{ids[0], ids[1]}, {ids[1], ids[2]}} {
+		var expenseID string
+		err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, e[0], "Lunch", "30.00").Scan(&expenseID)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, e[1], "30.00")
+		if err != nil {
+			t.Fatalf("failed to insert split: %s", err)
+		}
+	}
+
+	service := balances.NewService(testDB)
+	plan, err := service.GetDebts(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get debts: %s", err)
+	}
+	if plan.Mode != models.DebtModeSimplified || plan.Currency != "EUR" {
+		t.Errorf("expected simplified debts in EUR, got %s in %s", plan.Mode, plan.Currency)
+	}
+	if len(plan.Transfers) != 1 {
+		t.Fatalf("expected 1 transfer, got %d", len(plan.Transfers))
+	}
+	if transfer := plan.Transfers[0]; transfer.From.String() != ids[2] || transfer.To.String() != ids[0] || transfer.Amount.Minor != 3000 {
+		t.Errorf("expected user 2 to pay user 0 30.00, got %s paying %s %s", transfer.From, transfer.To, transfer.Amount)
+	}
+
+	_, err = testDB.Exec(`UPDATE groups SET debt_mode = 'pairwise' WHERE id = $1`, groupID)
+	if err != nil {
+		t.Fatalf("failed to update debt mode: %s", err)
+	}
+	plan, err = service.GetDebts(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get debts: %s", err)
+	}
+	if plan.Mode != models.DebtModePairwise || len(plan.Transfers) != 2 {
+		t.Fatalf("expected 2 pairwise transfers, got %d in %s mode", len(plan.Transfers), plan.Mode)
+	}
+
+	// recording the transfers as settlements settles the group
+	for _, transfer := range plan.Transfers {
+		_, err = testDB.Exec(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency) VALUES ($1, $2, $3, $4, 'EUR')`,
+			groupID, transfer.From, transfer.To, transfer.Amount)
+		if err != nil {
+			t.Fatalf("failed to insert settlement: %s", err)
+		}
+	}
+	result, err := service.GetBalances(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		if !b.Balance.IsZero() {
+			t.Errorf("expected every balance to be settled, %s has %s", b.UserID, b.Balance)
+		}
+	}
+	plan, err = service.GetDebts(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get debts: %s", err)
+	}
+	if len(plan.Transfers) != 0 {
+		t.Errorf("expected no transfers left, got %d", len(plan.Transfers))
+	}
+}
+
+func TestGetPairBalances(t *testing.T) {
+	var ana, ben, cat uuid.UUID
+	for email, id := range map[string]*uuid.UUID{"user11@test.com": &ana, "user12@test.com": &ben, "user13@test.com": &cat} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Weekend", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	insertExpense := func(paidBy uuid.UUID, amount string, splits map[uuid.UUID]string) uuid.UUID {
+		var expenseID uuid.UUID
+		err := testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, paidBy, "Expense", amount).Scan(&expenseID)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		for userID, split := range splits {
+			_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, split)
+			if err != nil {
+				t.Fatalf("failed to insert split: %s", err)
+			}
+		}
+		return expenseID
+	}
+
+	dinner := insertExpense(ana, "60.00", map[uuid.UUID]string{ana: "20.00", ben: "20.00", cat: "20.00"})
+	taxi := insertExpense(ben, "30.00", map[uuid.UUID]string{ana: "15.00", ben: "15.00"})
+
+	var settlementID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency) VALUES ($1, $2, $3, $4, 'EUR') RETURNING id`,
+		groupID, ben, ana, "3.00").Scan(&settlementID)
+	if err != nil {
+		t.Fatalf("failed to insert settlement: %s", err)
+	}
+
+	service := balances.NewService(testDB)
+	pairs, err := service.GetPairBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get pair balances: %s", err)
+	}
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 pairs, got %d", len(pairs))
+	}
+	for _, p := range pairs {
+		if p.UserID != ana {
+			t.Errorf("expected Ana to be owed in every pair, got %s", p.UserID)
+		}
+		switch p.OtherUserID {
+		case ben:
+			// 20.00 for dinner - 15.00 for the taxi - 3.00 paid back
+			if p.Balance.Minor != 200 {
+				t.Errorf("expected Ben to owe Ana 2.00, got %s", p.Balance)
+			}
+			if len(p.ExpenseIDs) != 2 || p.ExpenseIDs[0] != dinner || p.ExpenseIDs[1] != taxi {
+				t.Errorf("expected dinner then taxi as expenses, got %v", p.ExpenseIDs)
+			}
+			if len(p.SettlementIDs) != 1 || p.SettlementIDs[0] != settlementID {
+				t.Errorf("expected settlement %s, got %v", settlementID, p.SettlementIDs)
+			}
+		case cat:
+			if p.Balance.Minor != 2000 {
+				t.Errorf("expected Cat to owe Ana 20.00, got %s", p.Balance)
+			}
+			if len(p.ExpenseIDs) != 1 || p.ExpenseIDs[0] != dinner || len(p.SettlementIDs) != 0 {
+				t.Errorf("expected only the dinner, got %v and %v", p.ExpenseIDs, p.SettlementIDs)
+			}
+		default:
+			t.Errorf("unexpected pair with %s", p.OtherUserID)
+		}
+	}
+
+	pairs, err = service.GetUserPairBalances(groupID, ben, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get pair balances: %s", err)
+	}
+	if len(pairs) != 1 {
+		t.Fatalf("expected 1 pair for Ben, got %d", len(pairs))
+	}
+	if pairs[0].UserID != ben || pairs[0].OtherUserID != ana || pairs[0].Balance.Minor != -200 {
+		t.Errorf("expected Ben to see -2.00 with Ana, got %s with %s", pairs[0].Balance, pairs[0].OtherUserID)
+	}
+}
+
+func TestGetBalances_MultiplePayers(t *testing.T) {
+	var ana, ben, cat uuid.UUID
+	for email, id := range map[string]*uuid.UUID{"user14@test.com": &ana, "user15@test.com": &ben, "user16@test.com": &cat} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Cabin", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	// Ana paid 60.00 and Ben 30.00 of a 90.00 cabin shared by all three
+	var expenseID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id`,
+		groupID, ana, "Cabin", "90.00").Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	for userID, amount := range map[uuid.UUID]string{ana: "60.00", ben: "30.00"} {
+		_, err = testDB.Exec(`INSERT INTO expense_payers (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, amount)
+		if err != nil {
+			t.Fatalf("failed to insert payer: %s", err)
+		}
+	}
+	for _, userID := range []uuid.UUID{ana, ben, cat} {
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, "30.00")
+		if err != nil {
+			t.Fatalf("failed to insert split: %s", err)
+		}
+	}
+
+	service := balances.NewService(testDB)
+	result, err := service.GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	want := map[uuid.UUID]int64{ana: 3000, ben: 0, cat: -3000}
+	if len(result) != len(want) {
+		t.Fatalf("expected %d balances, got %d", len(want), len(result))
+	}
+	for _, b := range result {
+		if b.Balance.Minor != want[b.UserID] {
+			t.Errorf("expected balance %d for %s, got %s", want[b.UserID], b.UserID, b.Balance)
+		}
+	}
+
+	// every split is owed to the payers in proportion to what they paid
+	pairs, err := service.GetUserPairBalances(groupID, cat, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get pair balances: %s", err)
+	}
+	owed := map[uuid.UUID]int64{}
+	for _, p := range pairs {
+		owed[p.OtherUserID] = p.Balance.Minor
+	}
+	if owed[ana] != -2000 || owed[ben] != -1000 {
+		t.Errorf("expected Cat to owe Ana 20.00 and Ben 10.00, got %v", owed)
+	}
+}
+
+func TestGetBalances_IncurredOn(t *testing.T) {
+	var payerID, friendID string
+	for email, id := range map[string]*string{"user17@test.com": &payerID, "user18@test.com": &friendID} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID string
+	err := testDB.QueryRow(`INSERT INTO groups (name, currency, created_by) VALUES ($1, 'EUR', $2) RETURNING id`,
+		"Edinburgh", payerID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	parsedGroupID := uuid.MustParse(groupID)
+
+	rateService := rates.NewService(testDB)
+	for date, rate := range map[string]string{"2024-01-02": "0.8000", "2024-06-03": "0.9000"} {
+		_, err = rateService.SetRate(parsedGroupID, models.ExchangeRate{Base: "EUR", Quote: "GBP", Date: date, Rate: rate, Source: rates.SourceManual})
+		if err != nil {
+			t.Fatalf("failed to set rate: %s", err)
+		}
+	}
+
+	// recorded today, but incurred in March when the rate was 0.80
+	var expenseID string
+	err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency, incurred_on) VALUES ($1, $2, $2, $3, $4, 'GBP', '2024-03-01') RETURNING id, paid_by, amount)
+		INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+		groupID, payerID, "Castle tickets", "80.00").Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	for _, userID := range []string{payerID, friendID} {
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, "40.00")
+		if err != nil {
+			t.Fatalf("failed to insert split: %s", err)
+		}
+	}
+
+	result, err := balances.NewService(testDB).GetBalances(parsedGroupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		want := int64(-5000)
+		if b.UserID.String() == payerID {
+			want = 5000
+		}
+		if b.Balance.Minor != want {
+			t.Errorf("expected %d converted at the March rate, got %d", want, b.Balance.Minor)
+		}
+	}
+}
+
+func TestGetBalances_Tag(t *testing.T) {
+	var ana, ben uuid.UUID
+	for email, id := range map[string]*uuid.UUID{"user19@test.com": &ana, "user20@test.com": &ben} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID, tagID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Friends", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO tags (group_id, name) VALUES ($1, 'lisbon-trip') RETURNING id`, groupID).Scan(&tagID)
+	if err != nil {
+		t.Fatalf("failed to insert tag: %s", err)
+	}
+
+	// Ana paid 40.00 for the trip and 100.00 for rent, both split evenly
+	for description, amount := range map[string]string{"Lisbon hotel": "40.00", "Rent": "100.00"} {
+		var expenseID uuid.UUID
+		err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, ana, description, amount).Scan(&expenseID)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) SELECT $1, unnest($2::uuid[]), $3::decimal / 2`,
+			expenseID, "{"+ana.String()+","+ben.String()+"}", amount)
+		if err != nil {
+			t.Fatalf("failed to insert splits: %s", err)
+		}
+		if description == "Lisbon hotel" {
+			if _, err = testDB.Exec(`INSERT INTO expense_tags (expense_id, tag_id) VALUES ($1, $2)`, expenseID, tagID); err != nil {
+				t.Fatalf("failed to tag expense: %s", err)
+			}
+		}
+	}
+
+	// Ben paid back 5.00 towards the trip
+	var settlementID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency) VALUES ($1, $2, $3, '5.00', 'EUR') RETURNING id`,
+		groupID, ben, ana).Scan(&settlementID)
+	if err != nil {
+		t.Fatalf("failed to insert settlement: %s", err)
+	}
+	if _, err = testDB.Exec(`INSERT INTO settlement_tags (settlement_id, tag_id) VALUES ($1, $2)`, settlementID, tagID); err != nil {
+		t.Fatalf("failed to tag settlement: %s", err)
+	}
+
+	service := balances.NewService(testDB)
+	plan, err := service.GetDebts(groupID, tagID)
+	if err != nil {
+		t.Fatalf("failed to get debts: %s", err)
+	}
+	if len(plan.Transfers) != 1 || plan.Transfers[0].From != ben || plan.Transfers[0].Amount.Minor != 1500 {
+		t.Errorf("expected Ben to owe Ana 15.00 for the trip, got %+v", plan.Transfers)
+	}
+
+	result, err := service.GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		if b.UserID == ben && b.Balance.Minor != -6500 {
+			t.Errorf("expected Ben to owe 65.00 overall, got %s", b.Balance)
+		}
+	}
+
+	if _, err := service.GetBalances(groupID, uuid.New()); !errors.Is(err, tags.ErrUnknownTag) {
+		t.Errorf("expected ErrUnknownTag, got %v", err)
+	}
+}
+
+func TestGetBalances_Deleted(t *testing.T) {
+	var ana, ben uuid.UUID
+	for email, id := range map[string]*uuid.UUID{"user21@test.com": &ana, "user22@test.com": &ben} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Friends", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	// Ana paid 30.00 for dinner and 10.00 for a taxi, both split evenly;
+	// the taxi was deleted
+	for description, amount := range map[string]string{"Dinner": "30.00", "Taxi": "10.00"} {
+		var expenseID uuid.UUID
+		err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, 'EUR') RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, ana, description, amount).Scan(&expenseID)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) SELECT $1, unnest($2::uuid[]), $3::decimal / 2`,
+			expenseID, "{"+ana.String()+","+ben.String()+"}", amount)
+		if err != nil {
+			t.Fatalf("failed to insert splits: %s", err)
+		}
+		if description == "Taxi" {
+			if _, err = testDB.Exec(`UPDATE expenses SET deleted_at = now(), deleted_by = $1 WHERE id = $2`, ana, expenseID); err != nil {
+				t.Fatalf("failed to delete expense: %s", err)
+			}
+		}
+	}
+
+	// Ben's 15.00 settlement was deleted too
+	_, err = testDB.Exec(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency, deleted_at, deleted_by) VALUES ($1, $2, $3, '15.00', 'EUR', now(), $2)`,
+		groupID, ben, ana)
+	if err != nil {
+		t.Fatalf("failed to insert settlement: %s", err)
+	}
+
+	result, err := balances.NewService(testDB).GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		if b.UserID == ben && b.Balance.Minor != -1500 {
+			t.Errorf("expected Ben to owe 15.00 for dinner only, got %s", b.Balance)
+		}
+	}
+}
+
+func TestGetBalances_Pending(t *testing.T) {
+	var ana, ben uuid.UUID
+	for email, id := range map[string]*uuid.UUID{"user23@test.com": &ana, "user24@test.com": &ben} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			"User", email, "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+
+	var groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Friends", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	// Ana paid 30.00 for dinner, split evenly
+	var expenseID uuid.UUID
+	err = testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, 'Dinner', '30.00', 'EUR') RETURNING id, paid_by, amount)
+		INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+		groupID, ana).Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	_, err = testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) SELECT $1, unnest($2::uuid[]), 15.00`,
+		expenseID, "{"+ana.String()+","+ben.String()+"}")
+	if err != nil {
+		t.Fatalf("failed to insert splits: %s", err)
+	}
+
+	// Ben says he paid Ana back three times, but she only confirmed once
+	for _, status := range []models.SettlementStatus{models.SettlementPending, models.SettlementRejected, models.SettlementCancelled} {
+		_, err = testDB.Exec(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency, status) VALUES ($1, $2, $3, '15.00', 'EUR', $4)`,
+			groupID, ben, ana, status)
+		if err != nil {
+			t.Fatalf("failed to insert settlement: %s", err)
+		}
+	}
+
+	service := balances.NewService(testDB)
+	result, err := service.GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		if b.UserID == ben && b.Balance.Minor != -1500 {
+			t.Errorf("expected Ben to still owe 15.00, got %s", b.Balance)
+		}
+	}
+
+	if _, err := testDB.Exec(`UPDATE settlements SET status = 'confirmed' WHERE group_id = $1 AND status = 'pending'`, groupID); err != nil {
+		t.Fatalf("failed to confirm settlement: %s", err)
+	}
+	result, err = service.GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	for _, b := range result {
+		if b.Balance.Minor != 0 {
+			t.Errorf("expected everyone to be settled, got %s for %s", b.Balance, b.UserID)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package balances
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// SimplifyDebts turns net balances into transfers from debtors to creditors
+// using the greedy min-cash-flow method: the largest debtor repeatedly pays
+// the largest creditor as much as settles one of them. Every step settles at
+// least one person, so n balances need at most n-1 transfers. Equal amounts
+// are broken by the lower user ID, so the result doesn't depend on the order
+// of balances. Balances must add up to zero; any leftover stays unsettled.
+func SimplifyDebts(balances []models.Balance) []models.Transfer {
+	type party struct {
+		userID uuid.UUID
+		amount models.Money
+	}
+
+	var creditors, debtors []*party
+	for _, b := range balances {
+		switch {
+		case b.Balance.IsPositive():
+			creditors = append(creditors, &party{b.UserID, b.Balance})
+		case b.Balance.IsNegative():
+			debtors = append(debtors, &party{b.UserID, b.Balance.Neg()})
+		}
+	}
+
+	// largest amount first, then lowest user ID
+	largest := func(parties []*party) int {
+		best := 0
+		for i, p := range parties {
+			if p.amount.Minor > parties[best].amount.Minor ||
+				(p.amount.Minor == parties[best].amount.Minor && lessID(p.userID, parties[best].userID)) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	var transfers []models.Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		ci, di := largest(creditors), largest(debtors)
+		creditor, debtor := creditors[ci], debtors[di]
+
+		amount := debtor.amount
+		if creditor.amount.Minor < amount.Minor {
+			amount = creditor.amount
+		}
+		transfers = append(transfers, models.Transfer{From: debtor.userID, To: creditor.userID, Amount: amount})
+
+		creditor.amount = creditor.amount.Sub(amount)
+		debtor.amount = debtor.amount.Sub(amount)
+		if creditor.amount.IsZero() {
+			creditors = append(creditors[:ci], creditors[ci+1:]...)
+		}
+		if debtor.amount.IsZero() {
+			debtors = append(debtors[:di], debtors[di+1:]...)
+		}
+	}
+	return transfers
+}
+
+func sortTransfers(transfers []models.Transfer) {
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].From != transfers[j].From {
+			return lessID(transfers[i].From, transfers[j].From)
+		}
+		return lessID(transfers[i].To, transfers[j].To)
+	})
+}
+
+func lessID(a, b uuid.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
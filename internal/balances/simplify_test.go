@@ -0,0 +1,139 @@
+package balances_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/IvanLouren/GoSplit/internal/balances"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// zeroSum is a random set of balances adding up to zero, as in any group.
+type zeroSum []models.Balance
+
+func (zeroSum) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 1 + r.Intn(size+1)
+	result := make(zeroSum, n)
+	var sum int64
+	for i := range result {
+		var id uuid.UUID
+		r.Read(id[:])
+		result[i].UserID = id
+
+		if i == n-1 {
+			result[i].Balance = models.NewMoney(-sum, "EUR")
+			break
+		}
+		// draw from a small range half of the time to get ties and zeros
+		var minor int64
+		if r.Intn(2) == 0 {
+			minor = int64(r.Intn(5)-2) * 1000
+		} else {
+			minor = r.Int63n(2000001) - 1000000
+		}
+		result[i].Balance = models.NewMoney(minor, "EUR")
+		sum += minor
+	}
+	return reflect.ValueOf(result)
+}
+
+func settle(b zeroSum, transfers []models.Transfer) map[uuid.UUID]int64 {
+	remaining := make(map[uuid.UUID]int64)
+	for _, balance := range b {
+		remaining[balance.UserID] = balance.Balance.Minor
+	}
+	for _, t := range transfers {
+		remaining[t.From] += t.Amount.Minor
+		remaining[t.To] -= t.Amount.Minor
+	}
+	return remaining
+}
+
+func TestSimplifyDebts_SettlesEveryBalance(t *testing.T) {
+	property := func(b zeroSum) bool {
+		for _, left := range settle(b, balances.SimplifyDebts(b)) {
+			if left != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimplifyDebts_AtMostOneTransferPerDebtorOrCreditor(t *testing.T) {
+	property := func(b zeroSum) bool {
+		nonZero := 0
+		for _, balance := range b {
+			if !balance.Balance.IsZero() {
+				nonZero++
+			}
+		}
+		transfers := balances.SimplifyDebts(b)
+		if nonZero == 0 {
+			return len(transfers) == 0
+		}
+		return len(transfers) <= nonZero-1
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimplifyDebts_OnlyDebtorsPay(t *testing.T) {
+	property := func(b zeroSum) bool {
+		start := make(map[uuid.UUID]int64)
+		for _, balance := range b {
+			start[balance.UserID] = balance.Balance.Minor
+		}
+		for _, transfer := range balances.SimplifyDebts(b) {
+			if !transfer.Amount.IsPositive() || start[transfer.From] >= 0 || start[transfer.To] <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimplifyDebts_IgnoresInputOrder(t *testing.T) {
+	property := func(b zeroSum, seed int64) bool {
+		shuffled := make(zeroSum, len(b))
+		copy(shuffled, b)
+		rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return reflect.DeepEqual(balances.SimplifyDebts(b), balances.SimplifyDebts(shuffled))
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimplifyDebts_Example(t *testing.T) {
+	ana := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	ben := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	cat := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+
+	// Ben and Cat each owe 30.00, Ana is owed 60.00: two transfers, ties to the lower ID first
+	transfers := balances.SimplifyDebts([]models.Balance{
+		{UserID: cat, Balance: models.NewMoney(-3000, "EUR")},
+		{UserID: ana, Balance: models.NewMoney(6000, "EUR")},
+		{UserID: ben, Balance: models.NewMoney(-3000, "EUR")},
+	})
+
+	want := []models.Transfer{
+		{From: ben, To: ana, Amount: models.NewMoney(3000, "EUR")},
+		{From: cat, To: ana, Amount: models.NewMoney(3000, "EUR")},
+	}
+	if !reflect.DeepEqual(transfers, want) {
+		t.Errorf("expected %v, got %v", want, transfers)
+	}
+}
@@ -0,0 +1,463 @@
+package categories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type CategoryRequest struct {
+	Name string `json:"name" example:"Coffee"`
+}
+
+// RuleRequest describes a rule. An expense matches when it meets every
+// condition given: its description contains Pattern (ignoring case),
+// PaidBy paid at least part of it and its amount is within MinAmount and
+// MaxAmount, both inclusive.
+type RuleRequest struct {
+	CategoryID string `json:"category_id"`
+	// Position orders the rules; the first matching rule wins.
+	Position  int           `json:"position"`
+	Pattern   string        `json:"pattern" example:"uber"`
+	PaidBy    string        `json:"paid_by"`
+	MinAmount *models.Money `json:"min_amount" swaggertype:"number"`
+	MaxAmount *models.Money `json:"max_amount" swaggertype:"number"`
+}
+
+// GetCategories godoc
+// @Summary      List the categories of a group
+// @Description  Returns the system categories followed by the group's own.
+// @Tags         categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {array}   models.Category
+// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/categories [get]
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	categories, err := h.service.GetCategories(groupID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if categories == nil {
+		categories = []models.Category{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(categories)
+}
+
+// CreateCategory godoc
+// @Summary      Add a category to a group
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string           true  "Group ID"
+// @Param        body  body      CategoryRequest  true  "Category"
+// @Success      201   {object}  models.Category
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      409   {string}  string  "a category with this name already exists"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/categories [post]
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	name, ok := categoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.service.CreateCategory(groupID, name)
+	if errors.Is(err, ErrDuplicateCategory) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// RenameCategory godoc
+// @Summary      Rename one of a group's categories
+// @Description  System categories can't be renamed.
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id          path      string           true  "Group ID"
+// @Param        categoryId  path      string           true  "Category ID"
+// @Param        body        body      CategoryRequest  true  "Category"
+// @Success      200         {object}  models.Category
+// @Failure      400         {string}  string  "invalid request"
+// @Failure      401         {string}  string  "unauthorized"
+// @Failure      403         {string}  string  "forbidden"
+// @Failure      404         {string}  string  "category not found"
+// @Failure      409         {string}  string  "a category with this name already exists"
+// @Failure      500         {string}  string  "internal error"
+// @Router       /api/groups/{id}/categories/{categoryId} [put]
+func (h *Handler) RenameCategory(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	categoryID, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		http.Error(w, "invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	name, ok := categoryName(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.service.RenameCategory(groupID, categoryID, name)
+	if errors.Is(err, ErrDuplicateCategory) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+// categoryName reads and trims the name of a CategoryRequest. It writes the
+// error response itself and returns false when the request is invalid.
+func categoryName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name must not be empty", http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+// DeleteCategory godoc
+// @Summary      Delete one of a group's categories
+// @Description  Deletes the category and its rules. Its expenses become uncategorized. System categories can't be deleted.
+// @Tags         categories
+// @Security     BearerAuth
+// @Param        id          path      string  true  "Group ID"
+// @Param        categoryId  path      string  true  "Category ID"
+// @Success      204
+// @Failure      400         {string}  string  "invalid ID"
+// @Failure      401         {string}  string  "unauthorized"
+// @Failure      403         {string}  string  "forbidden"
+// @Failure      404         {string}  string  "category not found"
+// @Failure      500         {string}  string  "internal error"
+// @Router       /api/groups/{id}/categories/{categoryId} [delete]
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	categoryID, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		http.Error(w, "invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.DeleteCategory(groupID, categoryID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRules godoc
+// @Summary      List the category rules of a group
+// @Description  Returns the rules in the order they are tried.
+// @Tags         categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {array}   models.CategoryRule
+// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/category-rules [get]
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.service.GetRules(groupID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []models.CategoryRule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules)
+}
+
+// CreateRule godoc
+// @Summary      Add a category rule to a group
+// @Description  New and edited expenses without a category get the category of the first rule they match.
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string       true  "Group ID"
+// @Param        body  body      RuleRequest  true  "Rule"
+// @Success      201   {object}  models.CategoryRule
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/category-rules [post]
+func (h *Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	rule, ok := ruleInput(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err = h.service.CreateRule(groupID, rule)
+	if errors.Is(err, ErrEmptyRule) || errors.Is(err, ErrUnknownPayer) || errors.Is(err, ErrUnknownCategory) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateRule godoc
+// @Summary      Replace a category rule
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string       true  "Group ID"
+// @Param        ruleId  path      string       true  "Rule ID"
+// @Param        body    body      RuleRequest  true  "Rule"
+// @Success      200     {object}  models.CategoryRule
+// @Failure      400     {string}  string  "invalid request"
+// @Failure      401     {string}  string  "unauthorized"
+// @Failure      403     {string}  string  "forbidden"
+// @Failure      404     {string}  string  "rule not found"
+// @Failure      500     {string}  string  "internal error"
+// @Router       /api/groups/{id}/category-rules/{ruleId} [put]
+func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	ruleID, err := uuid.Parse(r.PathValue("ruleId"))
+	if err != nil {
+		http.Error(w, "invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	rule, ok := ruleInput(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err = h.service.UpdateRule(groupID, ruleID, rule)
+	if errors.Is(err, ErrEmptyRule) || errors.Is(err, ErrUnknownPayer) || errors.Is(err, ErrUnknownCategory) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// ruleInput validates a RuleRequest and turns it into a rule. It writes the
+// error response itself and returns false when the request is invalid.
+func ruleInput(w http.ResponseWriter, r *http.Request) (models.CategoryRule, bool) {
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return models.CategoryRule{}, false
+	}
+
+	categoryID, err := uuid.Parse(req.CategoryID)
+	if err != nil {
+		http.Error(w, "invalid category_id", http.StatusBadRequest)
+		return models.CategoryRule{}, false
+	}
+	rule := models.CategoryRule{
+		CategoryID: categoryID,
+		Position:   req.Position,
+		Pattern:    strings.TrimSpace(req.Pattern),
+		MinAmount:  req.MinAmount,
+		MaxAmount:  req.MaxAmount,
+	}
+	if req.PaidBy != "" {
+		paidBy, err := uuid.Parse(req.PaidBy)
+		if err != nil {
+			http.Error(w, "invalid paid_by", http.StatusBadRequest)
+			return models.CategoryRule{}, false
+		}
+		rule.PaidBy = &paidBy
+	}
+	if (rule.MinAmount != nil && rule.MinAmount.IsNegative()) || (rule.MaxAmount != nil && rule.MaxAmount.IsNegative()) {
+		http.Error(w, "amounts must not be negative", http.StatusBadRequest)
+		return models.CategoryRule{}, false
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && rule.MinAmount.Minor > rule.MaxAmount.Minor {
+		http.Error(w, "min_amount must not be greater than max_amount", http.StatusBadRequest)
+		return models.CategoryRule{}, false
+	}
+	return rule, true
+}
+
+// DeleteRule godoc
+// @Summary      Delete a category rule
+// @Description  Expenses keep the categories the rule gave them.
+// @Tags         categories
+// @Security     BearerAuth
+// @Param        id      path      string  true  "Group ID"
+// @Param        ruleId  path      string  true  "Rule ID"
+// @Success      204
+// @Failure      400     {string}  string  "invalid ID"
+// @Failure      401     {string}  string  "unauthorized"
+// @Failure      403     {string}  string  "forbidden"
+// @Failure      404     {string}  string  "rule not found"
+// @Failure      500     {string}  string  "internal error"
+// @Router       /api/groups/{id}/category-rules/{ruleId} [delete]
+func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	ruleID, err := uuid.Parse(r.PathValue("ruleId"))
+	if err != nil {
+		http.Error(w, "invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.DeleteRule(groupID, ruleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package categories
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Candidate is the part of an expense category rules look at.
+type Candidate struct {
+	Description string
+	Amount      models.Money
+	Payers      []uuid.UUID
+}
+
+// Match returns the category of the first rule matching c. Rules must be
+// ordered by position.
+func Match(rules []models.CategoryRule, c Candidate) (uuid.UUID, bool) {
+	for _, rule := range rules {
+		if matches(rule, c) {
+			return rule.CategoryID, true
+		}
+	}
+	return uuid.Nil, false
+}
+
+func matches(rule models.CategoryRule, c Candidate) bool {
+	if rule.Pattern != "" && !strings.Contains(strings.ToLower(c.Description), strings.ToLower(rule.Pattern)) {
+		return false
+	}
+	if rule.MinAmount != nil && c.Amount.Minor < rule.MinAmount.Minor {
+		return false
+	}
+	if rule.MaxAmount != nil && c.Amount.Minor > rule.MaxAmount.Minor {
+		return false
+	}
+	if rule.PaidBy != nil {
+		paid := false
+		for _, payer := range c.Payers {
+			paid = paid || payer == *rule.PaidBy
+		}
+		if !paid {
+			return false
+		}
+	}
+	return true
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+const ruleColumns = `id, group_id, category_id, position, pattern, paid_by, min_amount, max_amount, created_at`
+
+// GroupRules returns the group's rules in the order they are tried.
+func GroupRules(q Querier, groupID uuid.UUID) ([]models.CategoryRule, error) {
+	rows, err := q.Query(`SELECT `+ruleColumns+` FROM category_rules WHERE group_id = $1 ORDER BY position, created_at, id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.CategoryRule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// CheckCategory returns ErrUnknownCategory unless categoryID is a system
+// category or one of the group's.
+func CheckCategory(q Querier, groupID, categoryID uuid.UUID) error {
+	var id uuid.UUID
+	err := q.QueryRow(`SELECT id FROM categories WHERE id = $1 AND (group_id IS NULL OR group_id = $2)`, categoryID, groupID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrUnknownCategory
+	}
+	return err
+}
+
+func scanRule(row interface{ Scan(...any) error }) (models.CategoryRule, error) {
+	var rule models.CategoryRule
+	var pattern sql.NullString
+	var paidBy uuid.NullUUID
+	err := row.Scan(&rule.ID, &rule.GroupID, &rule.CategoryID, &rule.Position, &pattern, &paidBy, &rule.MinAmount, &rule.MaxAmount, &rule.CreatedAt)
+	if err != nil {
+		return models.CategoryRule{}, err
+	}
+	rule.Pattern = pattern.String
+	if paidBy.Valid {
+		rule.PaidBy = &paidBy.UUID
+	}
+	return rule, nil
+}
@@ -0,0 +1,41 @@
+package categories
+
+import (
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+func TestMatch(t *testing.T) {
+	travel, groceries, big := uuid.New(), uuid.New(), uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+	minAmount := models.NewMoney(50000, "")
+	rules := []models.CategoryRule{
+		{CategoryID: travel, Pattern: "Uber"},
+		{CategoryID: groceries, Pattern: "market", PaidBy: &alice},
+		{CategoryID: big, MinAmount: &minAmount},
+	}
+
+	tests := []struct {
+		name      string
+		candidate Candidate
+		want      uuid.UUID
+		matched   bool
+	}{
+		{"pattern ignores case", Candidate{Description: "uber to the airport", Amount: models.NewMoney(2500, "EUR")}, travel, true},
+		{"first rule wins", Candidate{Description: "Uber for the week", Amount: models.NewMoney(60000, "EUR")}, travel, true},
+		{"every condition must hold", Candidate{Description: "Supermarket", Amount: models.NewMoney(4000, "EUR"), Payers: []uuid.UUID{bob}}, uuid.Nil, false},
+		{"any payer matches", Candidate{Description: "Supermarket", Amount: models.NewMoney(4000, "EUR"), Payers: []uuid.UUID{bob, alice}}, groceries, true},
+		{"min amount is inclusive", Candidate{Description: "Hotel", Amount: models.NewMoney(50000, "EUR")}, big, true},
+		{"no rule matches", Candidate{Description: "Hotel", Amount: models.NewMoney(49999, "EUR")}, uuid.Nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Match(rules, tt.candidate)
+			if ok != tt.matched || got != tt.want {
+				t.Errorf("expected %s (%t), got %s (%t)", tt.want, tt.matched, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package categories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownCategory is returned for categories that are neither system
+	// categories nor the group's own.
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrDuplicateCategory is returned when the name is taken, ignoring case,
+	// by a system category or another of the group's categories.
+	ErrDuplicateCategory = errors.New("a category with this name already exists")
+	// ErrEmptyRule is returned for rules without any condition.
+	ErrEmptyRule = errors.New("a rule needs a pattern, a payer or an amount range")
+	// ErrUnknownPayer is returned for rules whose payer isn't a group member.
+	ErrUnknownPayer = errors.New("paid_by is not a member of the group")
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+const categoryColumns = `id, group_id, name, created_at`
+
+// GetCategories returns the system categories followed by the group's own,
+// each sorted by name.
+func (s *Service) GetCategories(groupID uuid.UUID) ([]models.Category, error) {
+	rows, err := s.db.Query(`SELECT `+categoryColumns+` FROM categories
+		WHERE group_id IS NULL OR group_id = $1
+		ORDER BY group_id NULLS FIRST, lower(name)`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (s *Service) CreateCategory(groupID uuid.UUID, name string) (models.Category, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Category{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkName(tx, groupID, uuid.Nil, name); err != nil {
+		return models.Category{}, err
+	}
+	category, err := scanCategory(tx.QueryRow(`INSERT INTO categories (group_id, name) VALUES ($1, $2) RETURNING `+categoryColumns, groupID, name))
+	if err != nil {
+		return models.Category{}, err
+	}
+	return category, tx.Commit()
+}
+
+// RenameCategory renames one of the group's categories; system categories
+// can't be renamed and return sql.ErrNoRows.
+func (s *Service) RenameCategory(groupID, categoryID uuid.UUID, name string) (models.Category, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Category{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkName(tx, groupID, categoryID, name); err != nil {
+		return models.Category{}, err
+	}
+	category, err := scanCategory(tx.QueryRow(`UPDATE categories SET name = $1 WHERE id = $2 AND group_id = $3 RETURNING `+categoryColumns,
+		name, categoryID, groupID))
+	if err != nil {
+		return models.Category{}, err
+	}
+	return category, tx.Commit()
+}
+
+// DeleteCategory deletes one of the group's categories together with its
+// rules. Expenses in it become uncategorized.
+func (s *Service) DeleteCategory(groupID, categoryID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM categories WHERE id = $1 AND group_id = $2`, categoryID, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkName returns ErrDuplicateCategory when another category visible to
+// the group, other than except, already has name.
+func checkName(tx *sql.Tx, groupID, except uuid.UUID, name string) error {
+	var taken bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories
+		WHERE (group_id IS NULL OR group_id = $1) AND lower(name) = lower($2) AND id <> $3)`,
+		groupID, name, except).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateCategory
+	}
+	return nil
+}
+
+func (s *Service) GetRules(groupID uuid.UUID) ([]models.CategoryRule, error) {
+	return GroupRules(s.db, groupID)
+}
+
+// CreateRule adds a rule to the group. It returns ErrEmptyRule when the rule
+// has no condition, ErrUnknownPayer when it names a payer outside the group
+// and ErrUnknownCategory when its category isn't available to the group.
+func (s *Service) CreateRule(groupID uuid.UUID, rule models.CategoryRule) (models.CategoryRule, error) {
+	if err := checkRule(s.db, groupID, rule); err != nil {
+		return models.CategoryRule{}, err
+	}
+	return scanRule(s.db.QueryRow(`INSERT INTO category_rules (group_id, category_id, position, pattern, paid_by, min_amount, max_amount)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7) RETURNING `+ruleColumns,
+		groupID, rule.CategoryID, rule.Position, rule.Pattern, nullUUID(rule.PaidBy), rule.MinAmount, rule.MaxAmount))
+}
+
+// UpdateRule replaces the rule's category, position and conditions.
+func (s *Service) UpdateRule(groupID, ruleID uuid.UUID, rule models.CategoryRule) (models.CategoryRule, error) {
+	if err := checkRule(s.db, groupID, rule); err != nil {
+		return models.CategoryRule{}, err
+	}
+	return scanRule(s.db.QueryRow(`UPDATE category_rules
+		SET category_id = $1, position = $2, pattern = NULLIF($3, ''), paid_by = $4, min_amount = $5, max_amount = $6
+		WHERE id = $7 AND group_id = $8 RETURNING `+ruleColumns,
+		rule.CategoryID, rule.Position, rule.Pattern, nullUUID(rule.PaidBy), rule.MinAmount, rule.MaxAmount, ruleID, groupID))
+}
+
+func (s *Service) DeleteRule(groupID, ruleID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM category_rules WHERE id = $1 AND group_id = $2`, ruleID, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func checkRule(q Querier, groupID uuid.UUID, rule models.CategoryRule) error {
+	if rule.Pattern == "" && rule.PaidBy == nil && rule.MinAmount == nil && rule.MaxAmount == nil {
+		return ErrEmptyRule
+	}
+	if rule.PaidBy != nil {
+		var member bool
+		err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2)`, groupID, *rule.PaidBy).Scan(&member)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return ErrUnknownPayer
+		}
+	}
+	return CheckCategory(q, groupID, rule.CategoryID)
+}
+
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+func scanCategory(row interface{ Scan(...any) error }) (models.Category, error) {
+	var category models.Category
+	var groupID uuid.NullUUID
+	err := row.Scan(&category.ID, &groupID, &category.Name, &category.CreatedAt)
+	if err != nil {
+		return models.Category{}, err
+	}
+	if groupID.Valid {
+		category.GroupID = &groupID.UUID
+	}
+	return category, nil
+}
@@ -0,0 +1,207 @@
+package categories_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/categories"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func createGroup(t *testing.T, email string) (uuid.UUID, uuid.UUID) {
+	t.Helper()
+	var userID, groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User", email, "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	return userID, groupID
+}
+
+func TestCategories(t *testing.T) {
+	_, groupID := createGroup(t, "user1@test.com")
+	service := categories.NewService(testDB)
+
+	coffee, err := service.CreateCategory(groupID, "Coffee")
+	if err != nil {
+		t.Fatalf("failed to create category: %s", err)
+	}
+	if coffee.GroupID == nil || *coffee.GroupID != groupID {
+		t.Errorf("expected category of group %s, got %v", groupID, coffee.GroupID)
+	}
+
+	// names are unique among the system categories and the group's own
+	if _, err := service.CreateCategory(groupID, "groceries"); !errors.Is(err, categories.ErrDuplicateCategory) {
+		t.Errorf("expected ErrDuplicateCategory, got %v", err)
+	}
+	if _, err := service.RenameCategory(groupID, coffee.ID, "Coffee & Tea"); err != nil {
+		t.Fatalf("failed to rename category: %s", err)
+	}
+
+	list, err := service.GetCategories(groupID)
+	if err != nil {
+		t.Fatalf("failed to get categories: %s", err)
+	}
+	if len(list) != 11 {
+		t.Fatalf("expected 10 system categories and 1 of the group, got %d", len(list))
+	}
+	if list[0].GroupID != nil || list[10].Name != "Coffee & Tea" {
+		t.Errorf("expected system categories first and then Coffee & Tea, got %q first and %q last", list[0].Name, list[10].Name)
+	}
+
+	// system categories belong to no group and can't be renamed
+	if _, err := service.RenameCategory(groupID, list[0].ID, "Mine"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// another group can't see the category
+	_, otherGroupID := createGroup(t, "user2@test.com")
+	err = categories.CheckCategory(testDB, otherGroupID, coffee.ID)
+	if !errors.Is(err, categories.ErrUnknownCategory) {
+		t.Errorf("expected ErrUnknownCategory, got %v", err)
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	userID, groupID := createGroup(t, "user3@test.com")
+	service := categories.NewService(testDB)
+	expenseService := expenses.NewService(testDB)
+
+	var transport uuid.UUID
+	if err := testDB.QueryRow(`SELECT id FROM categories WHERE group_id IS NULL AND name = 'Transport'`).Scan(&transport); err != nil {
+		t.Fatalf("failed to find system category: %s", err)
+	}
+	create := func(description string) models.Expense {
+		expense, err := expenseService.CreateExpense(groupID, userID, expenses.ExpenseInput{
+			Description: description,
+			Amount:      models.NewMoney(1500, ""),
+			SplitType:   models.SplitEqual,
+			Splits:      []expenses.SplitInput{{UserID: userID}},
+		})
+		if err != nil {
+			t.Fatalf("failed to create expense: %s", err)
+		}
+		return expense
+	}
+	taxi := create("Taxi home")
+	create("Pizza")
+
+	if _, err := service.CreateRule(groupID, models.CategoryRule{CategoryID: transport}); !errors.Is(err, categories.ErrEmptyRule) {
+		t.Errorf("expected ErrEmptyRule, got %v", err)
+	}
+	if _, err := service.CreateRule(groupID, models.CategoryRule{CategoryID: transport, Pattern: "taxi"}); err != nil {
+		t.Fatalf("failed to create rule: %s", err)
+	}
+
+	updated, err := expenseService.ApplyCategoryRules(groupID, userID, false)
+	if err != nil {
+		t.Fatalf("failed to apply rules: %s", err)
+	}
+	if updated != 1 {
+		t.Errorf("expected 1 expense updated, got %d", updated)
+	}
+	detail, err := expenseService.GetExpense(groupID, taxi.ID)
+	if err != nil {
+		t.Fatalf("failed to get expense: %s", err)
+	}
+	if detail.CategoryID == nil || *detail.CategoryID != transport {
+		t.Errorf("expected category %s, got %v", transport, detail.CategoryID)
+	}
+
+	// the change is recorded like any other edit
+	history, err := expenseService.GetHistory(groupID, taxi.ID)
+	if err != nil {
+		t.Fatalf("failed to get history: %s", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected 2 revisions, got %d", len(history))
+	}
+	var actorID uuid.UUID
+	err = testDB.QueryRow(`SELECT actor_id FROM activity WHERE object_id = $1 AND verb = $2`, taxi.ID, models.VerbUpdated).Scan(&actorID)
+	if err != nil {
+		t.Fatalf("failed to find activity: %s", err)
+	}
+	if actorID != userID {
+		t.Errorf("expected actor %s, got %s", userID, actorID)
+	}
+
+	// rules apply to new expenses as they are created
+	if later := create("TAXI to the station"); later.CategoryID == nil || *later.CategoryID != transport {
+		t.Errorf("expected category %s, got %v", transport, later.CategoryID)
+	}
+
+	// running again changes nothing
+	if updated, err := expenseService.ApplyCategoryRules(groupID, userID, true); err != nil || updated != 0 {
+		t.Errorf("expected 0 expenses updated, got %d (%v)", updated, err)
+	}
+}
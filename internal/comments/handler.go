@@ -0,0 +1,321 @@
+package comments
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type CommentRequest struct {
+	// Body can @mention members by e-mail address, by the part of it before
+	// the @ or by their name without spaces.
+	Body string `json:"body" example:"@ana I think the taxi was split twice"`
+}
+
+// GetExpenseComments godoc
+// @Summary      List the comments on an expense
+// @Description  Returns a page of the thread, oldest first. Pass next_cursor back as cursor for the next page.
+// @Tags         comments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true   "Group ID"
+// @Param        expenseId  path      string  true   "Expense ID"
+// @Param        cursor     query     string  false  "next_cursor of the previous page"
+// @Param        limit      query     int     false  "Page size, 50 by default and at most 200"
+// @Success      200        {object}  models.CommentPage
+// @Failure      400        {string}  string  "invalid ID or query parameter"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/comments [get]
+func (h *Handler) GetExpenseComments(w http.ResponseWriter, r *http.Request) {
+	h.getComments(w, r, "expenseId", ExpenseTarget, "expense")
+}
+
+// GetSettlementComments godoc
+// @Summary      List the comments on a settlement
+// @Description  Returns a page of the thread, oldest first. Pass next_cursor back as cursor for the next page.
+// @Tags         comments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true   "Group ID"
+// @Param        settlementId  path      string  true   "Settlement ID"
+// @Param        cursor        query     string  false  "next_cursor of the previous page"
+// @Param        limit         query     int     false  "Page size, 50 by default and at most 200"
+// @Success      200           {object}  models.CommentPage
+// @Failure      400           {string}  string  "invalid ID or query parameter"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/comments [get]
+func (h *Handler) GetSettlementComments(w http.ResponseWriter, r *http.Request) {
+	h.getComments(w, r, "settlementId", SettlementTarget, "settlement")
+}
+
+// getComments serves a thread whose target ID is in the param path segment.
+func (h *Handler) getComments(w http.ResponseWriter, r *http.Request, param string, target func(uuid.UUID) Target, kind string) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue(param))
+	if err != nil {
+		http.Error(w, "invalid "+kind+" ID", http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.service.GetComments(groupID, target(targetID), r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, kind+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// CreateExpenseComment godoc
+// @Summary      Comment on an expense
+// @Description  @mentions of group members are resolved to their user IDs.
+// @Tags         comments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string          true  "Group ID"
+// @Param        expenseId  path      string          true  "Expense ID"
+// @Param        body       body      CommentRequest  true  "Comment"
+// @Success      201        {object}  models.Comment
+// @Failure      400        {string}  string  "invalid request"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/comments [post]
+func (h *Handler) CreateExpenseComment(w http.ResponseWriter, r *http.Request) {
+	h.createComment(w, r, "expenseId", ExpenseTarget, "expense")
+}
+
+// CreateSettlementComment godoc
+// @Summary      Comment on a settlement
+// @Description  @mentions of group members are resolved to their user IDs.
+// @Tags         comments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string          true  "Group ID"
+// @Param        settlementId  path      string          true  "Settlement ID"
+// @Param        body          body      CommentRequest  true  "Comment"
+// @Success      201           {object}  models.Comment
+// @Failure      400           {string}  string  "invalid request"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/comments [post]
+func (h *Handler) CreateSettlementComment(w http.ResponseWriter, r *http.Request) {
+	h.createComment(w, r, "settlementId", SettlementTarget, "settlement")
+}
+
+func (h *Handler) createComment(w http.ResponseWriter, r *http.Request, param string, target func(uuid.UUID) Target, kind string) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue(param))
+	if err != nil {
+		http.Error(w, "invalid "+kind+" ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionComment) {
+		http.Error(w, "you do not have permission to comment", http.StatusForbidden)
+		return
+	}
+
+	body, ok := commentBody(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := h.service.CreateComment(groupID, userID, target(targetID), body)
+	if err == sql.ErrNoRows {
+		http.Error(w, kind+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// UpdateComment godoc
+// @Summary      Edit your comment
+// @Description  Replaces the body and resolves its @mentions again. Only the author can edit a comment.
+// @Tags         comments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string          true  "Group ID"
+// @Param        commentId  path      string          true  "Comment ID"
+// @Param        body       body      CommentRequest  true  "Comment"
+// @Success      200        {object}  models.Comment
+// @Failure      400        {string}  string  "invalid request"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "only the author can change a comment"
+// @Failure      404        {string}  string  "comment not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/comments/{commentId} [put]
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	commentID, err := uuid.Parse(r.PathValue("commentId"))
+	if err != nil {
+		http.Error(w, "invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := commentBody(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := h.service.UpdateComment(groupID, commentID, userID, body)
+	if errors.Is(err, ErrNotAuthor) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DeleteComment godoc
+// @Summary      Delete your comment
+// @Description  Only the author can delete a comment.
+// @Tags         comments
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        commentId  path      string  true  "Comment ID"
+// @Success      204
+// @Failure      400        {string}  string  "invalid ID"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "only the author can change a comment"
+// @Failure      404        {string}  string  "comment not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/comments/{commentId} [delete]
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	commentID, err := uuid.Parse(r.PathValue("commentId"))
+	if err != nil {
+		http.Error(w, "invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.DeleteComment(groupID, commentID, userID)
+	if errors.Is(err, ErrNotAuthor) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// commentBody reads and trims the body of a CommentRequest. It writes the
+// error response itself and returns false when the request is invalid.
+func commentBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		http.Error(w, "body must not be empty", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLength {
+		http.Error(w, fmt.Sprintf("body must be at most %d characters", MaxBodyLength), http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
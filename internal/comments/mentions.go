@@ -0,0 +1,74 @@
+package comments
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// mentionPattern finds @handles that start a word, so e-mail addresses in the
+// text are not read as mentions. A handle may itself be an e-mail address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// Handles returns the distinct @handles in body, lower-cased, in the order
+// they first appear.
+func Handles(body string) []string {
+	var handles []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// member is who a handle can refer to.
+type member struct {
+	id    uuid.UUID
+	name  string
+	email string
+}
+
+// handles returns the handles that refer to m: the e-mail address, its local
+// part and the name without spaces, all lower-cased.
+func (m member) handles() []string {
+	email := strings.ToLower(m.email)
+	local, _, _ := strings.Cut(email, "@")
+	name := strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, m.name))
+	return []string{email, local, name}
+}
+
+// resolve maps handles to the members they refer to. Handles that refer to
+// no member or to several are ignored.
+func resolve(handles []string, members []member) []uuid.UUID {
+	owners := make(map[string][]uuid.UUID)
+	for _, m := range members {
+		seen := make(map[string]bool)
+		for _, h := range m.handles() {
+			if h != "" && !seen[h] {
+				seen[h] = true
+				owners[h] = append(owners[h], m.id)
+			}
+		}
+	}
+
+	mentions := []uuid.UUID{}
+	mentioned := make(map[uuid.UUID]bool)
+	for _, h := range handles {
+		if ids := owners[h]; len(ids) == 1 && !mentioned[ids[0]] {
+			mentioned[ids[0]] = true
+			mentions = append(mentions, ids[0])
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,41 @@
+package comments
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHandles(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"@Ana the split is wrong", []string{"ana"}},
+		{"cc @ana, @bruno.silva and @ana again.", []string{"ana", "bruno.silva"}},
+		{"ask @cat@test.com", []string{"cat@test.com"}},
+		{"mail ana@test.com instead", nil},
+		{"just an @ sign", nil},
+	}
+	for _, tt := range tests {
+		if got := Handles(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Handles(%q): expected %v, got %v", tt.body, tt.want, got)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ana, bruno, anaToo := uuid.New(), uuid.New(), uuid.New()
+	members := []member{
+		{id: ana, name: "Ana", email: "ana@test.com"},
+		{id: bruno, name: "Bruno Silva", email: "bruno@test.com"},
+		{id: anaToo, name: "Ana", email: "ana.m@test.com"},
+	}
+
+	got := resolve([]string{"brunosilva", "ana.m", "bruno@test.com", "ana", "nobody"}, members)
+	want := []uuid.UUID{bruno, anaToo}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
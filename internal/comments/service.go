@@ -0,0 +1,309 @@
+package comments
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/database"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultLimit is the page size when none is given.
+	DefaultLimit = 50
+	// MaxLimit is the largest page GetComments returns.
+	MaxLimit = 200
+	// MaxBodyLength is the longest comment, in characters.
+	MaxBodyLength = 4000
+)
+
+var (
+	// ErrNotAuthor is returned when someone other than the author edits or
+	// deletes a comment.
+	ErrNotAuthor     = errors.New("only the author can change a comment")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Target is what a thread is about: an expense or a settlement.
+type Target struct {
+	table  string
+	column string
+	ID     uuid.UUID
+}
+
+func ExpenseTarget(expenseID uuid.UUID) Target {
+	return Target{table: "expenses", column: "expense_id", ID: expenseID}
+}
+
+func SettlementTarget(settlementID uuid.UUID) Target {
+	return Target{table: "settlements", column: "settlement_id", ID: settlementID}
+}
+
+const commentColumns = `c.id, c.group_id, c.expense_id, c.settlement_id, c.author_id, u.name, c.body, c.created_at, c.edited_at`
+
+// CreateComment adds a comment to the target's thread and records the
+// members it mentions. It returns sql.ErrNoRows when the target is not in the
+// group.
+func (s *Service) CreateComment(groupID, authorID uuid.UUID, target Target, body string) (models.Comment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+target.table+` WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL)`, target.ID, groupID).Scan(&found)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if !found {
+		return models.Comment{}, sql.ErrNoRows
+	}
+
+	var id uuid.UUID
+	err = tx.QueryRow(`INSERT INTO comments (group_id, `+target.column+`, author_id, body) VALUES ($1, $2, $3, $4) RETURNING id`,
+		groupID, target.ID, authorID, body).Scan(&id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	mentions, err := saveMentions(tx, groupID, id, body)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := scanComment(tx.QueryRow(`SELECT `+commentColumns+` FROM comments c JOIN users u ON u.id = c.author_id WHERE c.id = $1`, id))
+	if err != nil {
+		return models.Comment{}, err
+	}
+	comment.Mentions = mentions
+	return comment, tx.Commit()
+}
+
+// cursor is the position after the last comment of a page.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// GetComments returns a page of the target's thread, oldest first, starting
+// after cursor. It returns sql.ErrNoRows when the target is not in the group
+// and ErrInvalidCursor for cursors it didn't issue.
+func (s *Service) GetComments(groupID uuid.UUID, target Target, after string, limit int) (models.CommentPage, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+
+	var found bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+target.table+` WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL)`, target.ID, groupID).Scan(&found)
+	if err != nil {
+		return models.CommentPage{}, err
+	}
+	if !found {
+		return models.CommentPage{}, sql.ErrNoRows
+	}
+
+	args := []any{target.ID}
+	query := `SELECT ` + commentColumns + ` FROM comments c JOIN users u ON u.id = c.author_id WHERE c.` + target.column + ` = $1`
+	if after != "" {
+		var c cursor
+		data, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || json.Unmarshal(data, &c) != nil || c.ID == uuid.Nil {
+			return models.CommentPage{}, ErrInvalidCursor
+		}
+		args = append(args, c.CreatedAt, c.ID)
+		query += ` AND (c.created_at, c.id) > ($2::timestamptz, $3::uuid)`
+	}
+	query += fmt.Sprintf(` ORDER BY c.created_at, c.id LIMIT %d`, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return models.CommentPage{}, err
+	}
+	defer rows.Close()
+
+	page := models.CommentPage{Comments: []models.Comment{}}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return models.CommentPage{}, err
+		}
+		page.Comments = append(page.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return models.CommentPage{}, err
+	}
+
+	if len(page.Comments) > limit {
+		page.Comments = page.Comments[:limit]
+		last := page.Comments[limit-1]
+		data, _ := json.Marshal(cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, s.attachMentions(page.Comments)
+}
+
+// UpdateComment replaces the body of the author's comment and its mentions.
+// It returns sql.ErrNoRows when the comment is not in the group and
+// ErrNotAuthor when userID didn't write it.
+func (s *Service) UpdateComment(groupID, commentID, userID uuid.UUID, body string) (models.Comment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkAuthor(tx, groupID, commentID, userID); err != nil {
+		return models.Comment{}, err
+	}
+	_, err = tx.Exec(`UPDATE comments SET body = $1, edited_at = now() WHERE id = $2`, body, commentID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if _, err := tx.Exec(`DELETE FROM comment_mentions WHERE comment_id = $1`, commentID); err != nil {
+		return models.Comment{}, err
+	}
+	mentions, err := saveMentions(tx, groupID, commentID, body)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := scanComment(tx.QueryRow(`SELECT `+commentColumns+` FROM comments c JOIN users u ON u.id = c.author_id WHERE c.id = $1`, commentID))
+	if err != nil {
+		return models.Comment{}, err
+	}
+	comment.Mentions = mentions
+	return comment, tx.Commit()
+}
+
+// DeleteComment deletes the author's comment, with the same errors as
+// UpdateComment.
+func (s *Service) DeleteComment(groupID, commentID, userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkAuthor(tx, groupID, commentID, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM comments WHERE id = $1`, commentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func checkAuthor(tx *sql.Tx, groupID, commentID, userID uuid.UUID) error {
+	var authorID uuid.UUID
+	err := tx.QueryRow(`SELECT author_id FROM comments WHERE id = $1 AND group_id = $2 FOR UPDATE`, commentID, groupID).Scan(&authorID)
+	if err != nil {
+		return err
+	}
+	if authorID != userID {
+		return ErrNotAuthor
+	}
+	return nil
+}
+
+// saveMentions resolves the @handles in body against the group's current
+// members and records who was mentioned.
+func saveMentions(tx *sql.Tx, groupID, commentID uuid.UUID, body string) ([]uuid.UUID, error) {
+	handles := Handles(body)
+	if len(handles) == 0 {
+		return []uuid.UUID{}, nil
+	}
+
+	rows, err := tx.Query(`SELECT u.id, u.name, u.email FROM group_members m JOIN users u ON u.id = m.user_id WHERE m.group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.id, &m.name, &m.email); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	mentions := resolve(handles, members)
+	// the same order as attachMentions
+	sort.Slice(mentions, func(i, j int) bool { return mentions[i].String() < mentions[j].String() })
+	for _, userID := range mentions {
+		if _, err := tx.Exec(`INSERT INTO comment_mentions (comment_id, user_id) VALUES ($1, $2)`, commentID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return mentions, nil
+}
+
+// attachMentions loads the mentions of comments with a single query.
+func (s *Service) attachMentions(comments []models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	index := make(map[uuid.UUID]int, len(comments))
+	ids := make(database.UUIDs, len(comments))
+	for i, c := range comments {
+		index[c.ID] = i
+		ids[i] = c.ID
+		comments[i].Mentions = []uuid.UUID{}
+	}
+
+	rows, err := s.db.Query(`SELECT comment_id, user_id FROM comment_mentions WHERE comment_id = ANY($1::uuid[]) ORDER BY user_id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID, userID uuid.UUID
+		if err := rows.Scan(&commentID, &userID); err != nil {
+			return err
+		}
+		i := index[commentID]
+		comments[i].Mentions = append(comments[i].Mentions, userID)
+	}
+	return rows.Err()
+}
+
+func scanComment(row interface{ Scan(...any) error }) (models.Comment, error) {
+	var comment models.Comment
+	var expenseID, settlementID uuid.NullUUID
+	var editedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.GroupID, &expenseID, &settlementID, &comment.AuthorID, &comment.AuthorName,
+		&comment.Body, &comment.CreatedAt, &editedAt)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if expenseID.Valid {
+		comment.ExpenseID = &expenseID.UUID
+	}
+	if settlementID.Valid {
+		comment.SettlementID = &settlementID.UUID
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	return comment, nil
+}
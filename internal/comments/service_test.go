@@ -0,0 +1,165 @@
+package comments_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/comments"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestComments(t *testing.T) {
+	var ana, bruno, groupID uuid.UUID
+	for _, u := range []struct {
+		name, email string
+		id          *uuid.UUID
+	}{{"Ana", "ana@test.com", &ana}, {"Bruno Silva", "bruno@test.com", &bruno}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.name, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, bruno); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	var expenseID, settlementID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, 'Taxi', '20.00', 'EUR') RETURNING id`,
+		groupID, ana).Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency) VALUES ($1, $2, $3, '10.00', 'EUR') RETURNING id`,
+		groupID, bruno, ana).Scan(&settlementID)
+	if err != nil {
+		t.Fatalf("failed to insert settlement: %s", err)
+	}
+
+	service := comments.NewService(testDB)
+	first, err := service.CreateComment(groupID, ana, comments.ExpenseTarget(expenseID), "@brunosilva was this split twice? cc @nobody")
+	if err != nil {
+		t.Fatalf("failed to create comment: %s", err)
+	}
+	if len(first.Mentions) != 1 || first.Mentions[0] != bruno {
+		t.Errorf("expected Bruno to be mentioned, got %v", first.Mentions)
+	}
+	if first.AuthorName != "Ana" || first.ExpenseID == nil || *first.ExpenseID != expenseID {
+		t.Errorf("expected Ana's comment on the expense, got %+v", first)
+	}
+	if _, err := service.CreateComment(groupID, bruno, comments.ExpenseTarget(expenseID), "Yes, fixing it"); err != nil {
+		t.Fatalf("failed to create comment: %s", err)
+	}
+	if _, err := service.CreateComment(groupID, bruno, comments.SettlementTarget(settlementID), "Sent by bank transfer"); err != nil {
+		t.Fatalf("failed to create comment: %s", err)
+	}
+	if _, err := service.CreateComment(groupID, ana, comments.ExpenseTarget(settlementID), "Wrong thread"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	page, err := service.GetComments(groupID, comments.ExpenseTarget(expenseID), "", 1)
+	if err != nil {
+		t.Fatalf("failed to get comments: %s", err)
+	}
+	if len(page.Comments) != 1 || page.Comments[0].ID != first.ID || page.NextCursor == "" {
+		t.Fatalf("expected the first comment and a cursor, got %+v", page)
+	}
+	if len(page.Comments[0].Mentions) != 1 {
+		t.Errorf("expected the mention to be listed, got %v", page.Comments[0].Mentions)
+	}
+	page, err = service.GetComments(groupID, comments.ExpenseTarget(expenseID), page.NextCursor, 1)
+	if err != nil {
+		t.Fatalf("failed to get comments: %s", err)
+	}
+	if len(page.Comments) != 1 || page.Comments[0].AuthorID != bruno || page.NextCursor != "" {
+		t.Errorf("expected Bruno's reply on the last page, got %+v", page)
+	}
+
+	// only the author can edit or delete
+	if _, err := service.UpdateComment(groupID, first.ID, bruno, "edited"); !errors.Is(err, comments.ErrNotAuthor) {
+		t.Errorf("expected ErrNotAuthor, got %v", err)
+	}
+	edited, err := service.UpdateComment(groupID, first.ID, ana, "Never mind, @ana@test.com got it wrong")
+	if err != nil {
+		t.Fatalf("failed to update comment: %s", err)
+	}
+	if edited.EditedAt == nil || len(edited.Mentions) != 1 || edited.Mentions[0] != ana {
+		t.Errorf("expected an edited comment mentioning Ana, got %+v", edited)
+	}
+	if err := service.DeleteComment(groupID, first.ID, bruno); !errors.Is(err, comments.ErrNotAuthor) {
+		t.Errorf("expected ErrNotAuthor, got %v", err)
+	}
+	if err := service.DeleteComment(groupID, first.ID, ana); err != nil {
+		t.Fatalf("failed to delete comment: %s", err)
+	}
+	if err := service.DeleteComment(groupID, first.ID, ana); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
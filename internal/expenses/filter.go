@@ -0,0 +1,196 @@
+package expenses
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultExpenseLimit is the page size when the filter has none.
+	DefaultExpenseLimit = 50
+	// MaxExpenseLimit is the largest page GetExpenses returns.
+	MaxExpenseLimit = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ExpenseSort is the order GetExpenses returns expenses in. Expenses
+// incurred on the same day are ordered by when they were recorded, and ties
+// are broken by expense ID in the same direction.
+type ExpenseSort string
+
+const (
+	SortNewest   ExpenseSort = "date_desc"
+	SortOldest   ExpenseSort = "date_asc"
+	SortLargest  ExpenseSort = "amount_desc"
+	SortSmallest ExpenseSort = "amount_asc"
+)
+
+func (s ExpenseSort) Valid() bool {
+	switch s {
+	case SortNewest, SortOldest, SortLargest, SortSmallest:
+		return true
+	}
+	return false
+}
+
+func (s ExpenseSort) byAmount() bool   { return s == SortLargest || s == SortSmallest }
+func (s ExpenseSort) descending() bool { return s == SortNewest || s == SortLargest }
+
+// ExpenseFilter narrows down and orders GetExpenses. Zero fields don't
+// filter. Amounts are compared in each expense's own currency.
+type ExpenseFilter struct {
+	// From and To bound the day an expense was incurred, both inclusive.
+	From time.Time
+	To   time.Time
+	// PaidBy matches expenses the user paid at least part of.
+	PaidBy uuid.UUID
+	// Participant matches expenses the user has a split in.
+	Participant uuid.UUID
+	CategoryID  uuid.UUID
+	// TagIDs match expenses that have every one of the tags.
+	TagIDs    []uuid.UUID
+	MinAmount models.Money
+	MaxAmount models.Money
+	// Search matches descriptions containing it, ignoring case.
+	Search string
+	// Sort defaults to SortNewest.
+	Sort ExpenseSort
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	// Limit defaults to DefaultExpenseLimit and is capped at MaxExpenseLimit.
+	Limit int
+}
+
+// cursor is the position after the last expense of a page. It is sent to
+// clients base64-encoded so they treat it as opaque.
+type cursor struct {
+	Sort       ExpenseSort `json:"s"`
+	IncurredOn string      `json:"d,omitempty"`
+	CreatedAt  time.Time   `json:"t,omitempty"`
+	Amount     int64       `json:"a,omitempty"`
+	ID         uuid.UUID   `json:"id"`
+}
+
+func encodeCursor(sort ExpenseSort, last models.Expense) string {
+	c := cursor{Sort: sort, ID: last.ID}
+	if sort.byAmount() {
+		c.Amount = last.Amount.Minor
+	} else {
+		c.IncurredOn, c.CreatedAt = last.IncurredOn, last.CreatedAt
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort ExpenseSort) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.ID == uuid.Nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	if _, err := time.Parse(models.DateLayout, c.IncurredOn); err != nil && !sort.byAmount() {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// query builds the SELECT for one page of the group's expenses, fetching one
+// more row than the limit to tell whether another page follows.
+func (f ExpenseFilter) query(groupID uuid.UUID) (string, []any, error) {
+	args := []any{groupID}
+	conds := []string{"e.group_id = $1", "e.deleted_at IS NULL"}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if !f.From.IsZero() {
+		add("e.incurred_on >= $%d::date", f.From.Format(models.DateLayout))
+	}
+	if !f.To.IsZero() {
+		add("e.incurred_on <= $%d::date", f.To.Format(models.DateLayout))
+	}
+	if f.PaidBy != uuid.Nil {
+		add("EXISTS (SELECT 1 FROM expense_payers p WHERE p.expense_id = e.id AND p.user_id = $%d)", f.PaidBy)
+	}
+	if f.Participant != uuid.Nil {
+		add("EXISTS (SELECT 1 FROM expense_splits s WHERE s.expense_id = e.id AND s.user_id = $%d)", f.Participant)
+	}
+	if f.CategoryID != uuid.Nil {
+		add("e.category_id = $%d", f.CategoryID)
+	}
+	for _, tagID := range f.TagIDs {
+		add("EXISTS (SELECT 1 FROM expense_tags t WHERE t.expense_id = e.id AND t.tag_id = $%d)", tagID)
+	}
+	if !f.MinAmount.IsZero() {
+		add("e.amount >= $%d", f.MinAmount)
+	}
+	if !f.MaxAmount.IsZero() {
+		add("e.amount <= $%d", f.MaxAmount)
+	}
+	if f.Search != "" {
+		add(`e.description ILIKE $%d ESCAPE '\'`, "%"+escapeLike(f.Search)+"%")
+	}
+
+	sort := f.Sort
+	if sort == "" {
+		sort = SortNewest
+	}
+	columns := []string{"e.incurred_on", "e.created_at", "e.id"}
+	direction, compare := "ASC", ">"
+	if sort.byAmount() {
+		columns = []string{"e.amount", "e.id"}
+	}
+	if sort.descending() {
+		direction, compare = "DESC", "<"
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor, sort)
+		if err != nil {
+			return "", nil, err
+		}
+		n := len(args)
+		if sort.byAmount() {
+			args = append(args, models.NewMoney(c.Amount, ""), c.ID)
+			conds = append(conds, fmt.Sprintf("(e.amount, e.id) %s ($%d::decimal, $%d::uuid)", compare, n+1, n+2))
+		} else {
+			args = append(args, c.IncurredOn, c.CreatedAt, c.ID)
+			conds = append(conds, fmt.Sprintf("(e.incurred_on, e.created_at, e.id) %s ($%d::date, $%d::timestamptz, $%d::uuid)", compare, n+1, n+2, n+3))
+		}
+	}
+
+	order := make([]string, len(columns))
+	for i, column := range columns {
+		order[i] = column + " " + direction
+	}
+	query := `SELECT ` + expenseColumns + ` FROM expenses e WHERE ` + strings.Join(conds, " AND ") +
+		fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(order, ", "), f.limit()+1)
+	return query, args, nil
+}
+
+func (f ExpenseFilter) limit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultExpenseLimit
+	case f.Limit > MaxExpenseLimit:
+		return MaxExpenseLimit
+	}
+	return f.Limit
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
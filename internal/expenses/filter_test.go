@@ -0,0 +1,69 @@
+package expenses
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+func TestExpenseFilter_Cursor(t *testing.T) {
+	last := models.Expense{
+		ID:         uuid.New(),
+		Amount:     models.NewMoney(1250, "EUR"),
+		IncurredOn: "2024-04-28",
+		CreatedAt:  time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+	}
+
+	c, err := decodeCursor(encodeCursor(SortNewest, last), SortNewest)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if c.ID != last.ID || c.IncurredOn != last.IncurredOn || !c.CreatedAt.Equal(last.CreatedAt) {
+		t.Errorf("expected the cursor to point after %s on %s, got %+v", last.ID, last.IncurredOn, c)
+	}
+
+	c, err = decodeCursor(encodeCursor(SortSmallest, last), SortSmallest)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if c.Amount != 1250 {
+		t.Errorf("expected the cursor to keep amount 1250, got %d", c.Amount)
+	}
+
+	for _, s := range []string{"not base64!", "bm90IGpzb24", encodeCursor(SortNewest, last)} {
+		if _, err := decodeCursor(s, SortLargest); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestExpenseFilter_Query(t *testing.T) {
+	filter := ExpenseFilter{
+		PaidBy:    userA,
+		MinAmount: models.NewMoney(1000, ""),
+		Search:    "50%_off",
+		Sort:      SortLargest,
+		Limit:     10,
+	}
+	query, args, err := filter.query(uuid.New())
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if len(args) != 4 {
+		t.Errorf("expected 4 arguments, got %d", len(args))
+	}
+	if args[3] != `%50\%\_off%` {
+		t.Errorf("expected the wildcards in the search to be escaped, got %v", args[3])
+	}
+	if !strings.HasSuffix(query, "ORDER BY e.amount DESC, e.id DESC LIMIT 11") {
+		t.Errorf("expected to order by amount and fetch one extra row, got %s", query)
+	}
+
+	if got := (ExpenseFilter{Limit: 1000}).limit(); got != MaxExpenseLimit {
+		t.Errorf("expected the limit to be capped at %d, got %d", MaxExpenseLimit, got)
+	}
+}
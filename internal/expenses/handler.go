@@ -3,8 +3,15 @@ package expenses
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/categories"
+	"github.com/IvanLouren/GoSplit/internal/tags"
 	"github.com/IvanLouren/GoSplit/pkg/middleware"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
@@ -18,15 +25,207 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
+// SplitRequest is one participant. Amount is used by exact and adjustment
+// splits, Share (a percentage or a number of shares) by percentage and
+// shares splits; equal splits only need the user ID.
 type SplitRequest struct {
-	UserID string  `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID string        `json:"user_id"`
+	Amount models.Money  `json:"amount" swaggertype:"number"`
+	Share  models.Weight `json:"share" swaggertype:"number"`
 }
 
 type CreateExpenseRequest struct {
-	Description string         `json:"description"`
-	Amount      float64        `json:"amount"`
-	Splits      []SplitRequest `json:"splits"`
+	Description string       `json:"description"`
+	Amount      models.Money `json:"amount" swaggertype:"number"`
+	// Currency defaults to the group's currency on creation and is left
+	// unchanged on update.
+	Currency string `json:"currency" example:"EUR"`
+	// SplitType defaults to exact.
+	SplitType models.SplitType `json:"split_type"`
+	Splits    []SplitRequest   `json:"splits"`
+	// IncurredOn defaults to today in TimeZone, or in UTC, on creation. Both
+	// are left unchanged on update when empty.
+	IncurredOn string `json:"incurred_on" example:"2024-01-02"`
+	TimeZone   string `json:"time_zone" example:"Europe/Lisbon"`
+	// CategoryID defaults to the category of the group's first matching
+	// category rule. On update the current category is kept when no rule
+	// matches.
+	CategoryID string `json:"category_id"`
+	// TagIDs are the group's tags to put on the expense. On update leaving
+	// them out keeps the current tags and an empty list removes them.
+	TagIDs []string `json:"tag_ids"`
+	// Items, Tax, ServiceCharge and Tip make up the receipt of an itemized
+	// expense; Splits are not used then.
+	Items         []ItemRequest `json:"items"`
+	Tax           models.Money  `json:"tax" swaggertype:"number"`
+	ServiceCharge models.Money  `json:"service_charge" swaggertype:"number"`
+	Tip           models.Money  `json:"tip" swaggertype:"number"`
+	// PaidBy is the member who paid the whole amount. It is ignored when
+	// Payers is given.
+	PaidBy string `json:"paid_by"`
+	// Payers default to PaidBy, or the caller, paying the whole amount on
+	// creation, and to the current payers on update.
+	Payers []PayerRequest `json:"payers"`
+}
+
+// PayerRequest is how much of the expense one user paid.
+type PayerRequest struct {
+	UserID string       `json:"user_id"`
+	Amount models.Money `json:"amount" swaggertype:"number"`
+}
+
+// ItemRequest is a receipt line shared equally by the users assigned to it.
+type ItemRequest struct {
+	Description string       `json:"description"`
+	Price       models.Money `json:"price" swaggertype:"number"`
+	UserIDs     []string     `json:"user_ids"`
+}
+
+// expenseInput validates the request and turns it into the service input. It
+// writes the error response itself and returns false when the request is
+// invalid.
+func expenseInput(w http.ResponseWriter, r *http.Request, req *CreateExpenseRequest, userID uuid.UUID) (ExpenseInput, bool) {
+	splits, ok := splitInputs(w, req)
+	if !ok {
+		return ExpenseInput{}, false
+	}
+	payers, ok := payerInputs(w, r, req, userID)
+	if !ok {
+		return ExpenseInput{}, false
+	}
+	in := ExpenseInput{
+		Description: req.Description,
+		Amount:      req.Amount,
+		SplitType:   req.SplitType,
+		Splits:      splits,
+		Payers:      payers,
+		TimeZone:    req.TimeZone,
+	}
+	if req.IncurredOn != "" {
+		day, err := time.Parse(models.DateLayout, req.IncurredOn)
+		if err != nil {
+			http.Error(w, "invalid incurred_on, expected YYYY-MM-DD", http.StatusBadRequest)
+			return ExpenseInput{}, false
+		}
+		in.IncurredOn = day
+	}
+	if req.CategoryID != "" {
+		categoryID, err := uuid.Parse(req.CategoryID)
+		if err != nil {
+			http.Error(w, "invalid category_id", http.StatusBadRequest)
+			return ExpenseInput{}, false
+		}
+		in.CategoryID = categoryID
+	}
+	if req.TagIDs != nil {
+		in.TagIDs = make([]uuid.UUID, 0, len(req.TagIDs))
+		for _, id := range req.TagIDs {
+			tagID, err := uuid.Parse(id)
+			if err != nil {
+				http.Error(w, "invalid tag ID in tag_ids", http.StatusBadRequest)
+				return ExpenseInput{}, false
+			}
+			in.TagIDs = append(in.TagIDs, tagID)
+		}
+	}
+
+	if req.SplitType == models.SplitItemized {
+		receipt := &ReceiptInput{Tax: req.Tax, ServiceCharge: req.ServiceCharge, Tip: req.Tip}
+		for _, item := range req.Items {
+			line := ItemInput{Description: item.Description, Price: item.Price}
+			for _, id := range item.UserIDs {
+				itemUserID, err := uuid.Parse(id)
+				if err != nil {
+					http.Error(w, "invalid user ID in items", http.StatusBadRequest)
+					return ExpenseInput{}, false
+				}
+				line.UserIDs = append(line.UserIDs, itemUserID)
+			}
+			receipt.Items = append(receipt.Items, line)
+		}
+		in.Receipt = receipt
+	}
+	return in, true
+}
+
+// splitInputs validates the currency and split type and parses the
+// participants. It writes the error response itself and returns false when
+// the request is invalid.
+func splitInputs(w http.ResponseWriter, req *CreateExpenseRequest) ([]SplitInput, bool) {
+	if req.Currency != "" {
+		currency, err := models.ParseCurrency(req.Currency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		req.Amount.Currency = currency
+	}
+
+	if req.SplitType == "" {
+		req.SplitType = models.SplitExact
+	}
+	if !req.SplitType.Valid() {
+		http.Error(w, "invalid split type", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var splits []SplitInput
+	for _, s := range req.Splits {
+		splitUserID, err := uuid.Parse(s.UserID)
+		if err != nil {
+			http.Error(w, "invalid user ID in splits", http.StatusBadRequest)
+			return nil, false
+		}
+		splits = append(splits, SplitInput{UserID: splitUserID, Amount: s.Amount, Share: s.Share})
+	}
+	return splits, true
+}
+
+// payerInputs parses the payers, or paid_by when there are none, and checks
+// that the caller may record expenses paid by others. It writes the error
+// response itself and returns false when the request is invalid.
+func payerInputs(w http.ResponseWriter, r *http.Request, req *CreateExpenseRequest, userID uuid.UUID) ([]PayerInput, bool) {
+	var payers []PayerInput
+	for _, p := range req.Payers {
+		payerID, err := uuid.Parse(p.UserID)
+		if err != nil {
+			http.Error(w, "invalid user ID in payers", http.StatusBadRequest)
+			return nil, false
+		}
+		payers = append(payers, PayerInput{UserID: payerID, Amount: p.Amount})
+	}
+	if len(payers) == 0 && req.PaidBy != "" {
+		paidBy, err := uuid.Parse(req.PaidBy)
+		if err != nil {
+			http.Error(w, "invalid paid_by", http.StatusBadRequest)
+			return nil, false
+		}
+		payers = []PayerInput{{UserID: paidBy, Amount: req.Amount}}
+	}
+
+	for _, p := range payers {
+		if p.UserID != userID && !middleware.GetGroupRole(r).Can(models.PermissionRecordForOthers) {
+			http.Error(w, "you do not have permission to record expenses paid by others", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return payers, true
+}
+
+// writeValidationError sends err as JSON. Members named through paid_by or
+// receipt items are reported under those fields.
+func writeValidationError(w http.ResponseWriter, req *CreateExpenseRequest, err *ValidationError) {
+	if req.PaidBy != "" && len(req.Payers) == 0 && err.Fields["payers"] != nil {
+		err.Fields["paid_by"] = err.Fields["payers"]
+		delete(err.Fields, "payers")
+	}
+	if req.SplitType == models.SplitItemized && err.Fields["splits"] != nil {
+		err.Fields["items"] = err.Fields["splits"]
+		delete(err.Fields, "splits")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(err)
 }
 
 // CreateExpense godoc
@@ -40,6 +239,10 @@ type CreateExpenseRequest struct {
 // @Success      201   {object}  models.Expense
 // @Failure      400   {string}  string  "invalid request"
 // @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      409   {string}  string  "the period is locked"
+// @Failure      422   {object}  ValidationError  "users are not members of the group"
 // @Failure      500   {string}  string  "internal error"
 // @Router       /api/groups/{id}/expenses [post]
 func (h *Handler) CreateExpense(w http.ResponseWriter, r *http.Request) {
@@ -57,13 +260,18 @@ func (h *Handler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.GetGroupRole(r).Can(models.PermissionAddExpense) {
+		http.Error(w, "you do not have permission to add expenses", http.StatusForbidden)
+		return
+	}
+
 	var req CreateExpenseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		http.Error(w, "Amount must not be zero", http.StatusBadRequest)
 		return
 	}
@@ -73,28 +281,26 @@ func (h *Handler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var splits []SplitInput
-	for _, s := range req.Splits {
-		splitUserID, err := uuid.Parse(s.UserID)
-		if err != nil {
-			http.Error(w, "invalid user ID in splits", http.StatusBadRequest)
-			return
-		}
-		splits = append(splits, SplitInput{UserID: splitUserID, Amount: s.Amount})
+	in, ok := expenseInput(w, r, &req, parsedID)
+	if !ok {
+		return
 	}
 
-	const epsilon = 0.01 // to validate splits add up to total
-	var total float64
-	for _, s := range splits {
-		total += s.Amount
+	expense, err := h.service.CreateExpense(groupID, parsedID, in)
+	if errors.Is(err, ErrInvalidSplit) || errors.Is(err, ErrInvalidPayers) || errors.Is(err, ErrInvalidTimeZone) ||
+		errors.Is(err, categories.ErrUnknownCategory) || errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	diff := total - req.Amount
-	if diff < -epsilon || diff > epsilon {
-		http.Error(w, "splits must add up to total amount", http.StatusBadRequest)
+	if errors.Is(err, ErrPeriodLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		writeValidationError(w, &req, invalid)
 		return
 	}
-
-	expense, err := h.service.CreateExpense(groupID, parsedID, req.Description, req.Amount, splits)
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -106,14 +312,28 @@ func (h *Handler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetExpenses godoc
-// @Summary      List all expenses in a group
+// @Summary      List the expenses in a group
+// @Description  Returns a page of expenses, newest first unless sort is given. Pass next_cursor back as cursor for the next page.
 // @Tags         expenses
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      string  true  "Group ID"
-// @Success      200  {array}   models.Expense
-// @Failure      400  {string}  string  "invalid group ID"
+// @Param        id           path      string    true   "Group ID"
+// @Param        from         query     string    false  "Incurred on or after this day (YYYY-MM-DD)"
+// @Param        to           query     string    false  "Incurred on or before this day (YYYY-MM-DD)"
+// @Param        paid_by      query     string    false  "Paid in full or in part by this user"
+// @Param        participant  query     string    false  "Split with this user"
+// @Param        category     query     string    false  "Category ID"
+// @Param        tag          query     []string  false  "Tag ID; repeat to require several tags"  collectionFormat(multi)
+// @Param        min_amount   query     number    false  "Smallest amount"
+// @Param        max_amount   query     number    false  "Largest amount"
+// @Param        q            query     string    false  "Text in the description"
+// @Param        sort         query     string    false  "date_desc, date_asc, amount_desc or amount_asc"
+// @Param        cursor       query     string    false  "next_cursor of the previous page"
+// @Param        limit        query     int       false  "Page size, 50 by default and at most 200"
+// @Success      200  {object}  models.ExpensePage
+// @Failure      400  {string}  string  "invalid group ID or query parameter"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/expenses [get]
 func (h *Handler) GetExpenses(w http.ResponseWriter, r *http.Request) {
@@ -124,18 +344,111 @@ func (h *Handler) GetExpenses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expenses, err := h.service.GetExpenses(groupID)
+	filter, err := expenseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.GetExpenses(groupID, filter)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
-	if expenses == nil {
-		expenses = []models.Expense{}
+	if page.Expenses == nil {
+		page.Expenses = []models.ExpenseDetail{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(expenses)
+	json.NewEncoder(w).Encode(page)
+}
+
+// expenseFilter reads the query parameters of GetExpenses. Its errors are
+// meant for the client.
+func expenseFilter(query url.Values) (ExpenseFilter, error) {
+	var filter ExpenseFilter
+	var err error
+
+	date := func(name string) (time.Time, error) {
+		if query.Get(name) == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(models.DateLayout, query.Get(name))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s", name)
+		}
+		return t, nil
+	}
+	if filter.From, err = date("from"); err != nil {
+		return ExpenseFilter{}, err
+	}
+	if filter.To, err = date("to"); err != nil {
+		return ExpenseFilter{}, err
+	}
+
+	id := func(name string) (uuid.UUID, error) {
+		if query.Get(name) == "" {
+			return uuid.Nil, nil
+		}
+		parsed, err := uuid.Parse(query.Get(name))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid %s", name)
+		}
+		return parsed, nil
+	}
+	if filter.PaidBy, err = id("paid_by"); err != nil {
+		return ExpenseFilter{}, err
+	}
+	if filter.Participant, err = id("participant"); err != nil {
+		return ExpenseFilter{}, err
+	}
+	if filter.CategoryID, err = id("category"); err != nil {
+		return ExpenseFilter{}, err
+	}
+	for _, tag := range query["tag"] {
+		tagID, err := uuid.Parse(tag)
+		if err != nil {
+			return ExpenseFilter{}, errors.New("invalid tag")
+		}
+		filter.TagIDs = append(filter.TagIDs, tagID)
+	}
+
+	amount := func(name string) (models.Money, error) {
+		if query.Get(name) == "" {
+			return models.Money{}, nil
+		}
+		m, err := models.ParseMoney(query.Get(name))
+		if err != nil || m.IsNegative() {
+			return models.Money{}, fmt.Errorf("invalid %s", name)
+		}
+		return m, nil
+	}
+	if filter.MinAmount, err = amount("min_amount"); err != nil {
+		return ExpenseFilter{}, err
+	}
+	if filter.MaxAmount, err = amount("max_amount"); err != nil {
+		return ExpenseFilter{}, err
+	}
+
+	filter.Search = query.Get("q")
+	filter.Sort = ExpenseSort(query.Get("sort"))
+	if filter.Sort != "" && !filter.Sort.Valid() {
+		return ExpenseFilter{}, errors.New("invalid sort")
+	}
+	filter.Cursor = query.Get("cursor")
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > MaxExpenseLimit {
+			return ExpenseFilter{}, errors.New("invalid limit")
+		}
+	}
+	return filter, nil
 }
 
 // GetExpense godoc
@@ -145,13 +458,20 @@ func (h *Handler) GetExpenses(w http.ResponseWriter, r *http.Request) {
 // @Security     BearerAuth
 // @Param        id         path      string  true  "Group ID"
 // @Param        expenseId  path      string  true  "Expense ID"
-// @Success      200  {object}  models.Expense
+// @Success      200  {object}  models.ExpenseDetail
 // @Failure      400  {string}  string  "invalid ID"
 // @Failure      401  {string}  string  "unauthorized"
 // @Failure      404  {string}  string  "expense not found"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/expenses/{expenseId} [get]
 func (h *Handler) GetExpense(w http.ResponseWriter, r *http.Request) {
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
 	expenseIDStr := r.PathValue("expenseId")
 	expenseID, err := uuid.Parse(expenseIDStr)
 	if err != nil {
@@ -159,7 +479,7 @@ func (h *Handler) GetExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expense, err := h.service.GetExpense(expenseID)
+	expense, err := h.service.GetExpense(groupID, expenseID)
 	if err == sql.ErrNoRows {
 		http.Error(w, "expense not found", http.StatusNotFound)
 		return
@@ -186,10 +506,27 @@ func (h *Handler) GetExpense(w http.ResponseWriter, r *http.Request) {
 // @Success      200  {object}  models.Expense
 // @Failure      400  {string}  string  "invalid request"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
 // @Failure      404  {string}  string  "expense not found"
+// @Failure      409  {string}  string  "the period is locked"
+// @Failure      422  {object}  ValidationError  "users are not members of the group"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/expenses/{expenseId} [put]
 func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
 	expenseIDStr := r.PathValue("expenseId")
 	expenseID, err := uuid.Parse(expenseIDStr)
 	if err != nil {
@@ -197,13 +534,17 @@ func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.canEditExpense(w, r, groupID, expenseID, parsedID) {
+		return
+	}
+
 	var req CreateExpenseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		http.Error(w, "amount must not be zero", http.StatusBadRequest)
 		return
 	}
@@ -213,28 +554,26 @@ func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var splits []SplitInput
-	for _, s := range req.Splits {
-		splitUserID, err := uuid.Parse(s.UserID)
-		if err != nil {
-			http.Error(w, "invalid user ID in splits", http.StatusBadRequest)
-			return
-		}
-		splits = append(splits, SplitInput{UserID: splitUserID, Amount: s.Amount})
+	in, ok := expenseInput(w, r, &req, parsedID)
+	if !ok {
+		return
 	}
 
-	const epsilon = 0.01
-	var total float64
-	for _, s := range splits {
-		total += s.Amount
+	expense, err := h.service.UpdateExpense(groupID, expenseID, parsedID, in)
+	if errors.Is(err, ErrInvalidSplit) || errors.Is(err, ErrInvalidPayers) || errors.Is(err, ErrInvalidTimeZone) ||
+		errors.Is(err, categories.ErrUnknownCategory) || errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	diff := total - req.Amount
-	if diff < -epsilon || diff > epsilon {
-		http.Error(w, "splits must add up to total amount", http.StatusBadRequest)
+	if errors.Is(err, ErrPeriodLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		writeValidationError(w, &req, invalid)
 		return
 	}
-
-	expense, err := h.service.UpdateExpense(expenseID, req.Description, req.Amount, splits)
 	if err == sql.ErrNoRows {
 		http.Error(w, "expense not found", http.StatusNotFound)
 		return
@@ -250,6 +589,7 @@ func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 
 // DeleteExpense godoc
 // @Summary      Delete an expense
+// @Description  Moves the expense to the group's trash, from where it can be restored until it is purged.
 // @Tags         expenses
 // @Security     BearerAuth
 // @Param        id         path      string  true  "Group ID"
@@ -257,9 +597,26 @@ func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 // @Success      204
 // @Failure      400  {string}  string  "invalid ID"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "expense not found"
+// @Failure      409  {string}  string  "the period is locked"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/expenses/{expenseId} [delete]
 func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
 	expenseIDStr := r.PathValue("expenseId")
 	expenseID, err := uuid.Parse(expenseIDStr)
 	if err != nil {
@@ -267,7 +624,19 @@ func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.DeleteExpense(expenseID)
+	if !h.canEditExpense(w, r, groupID, expenseID, parsedID) {
+		return
+	}
+
+	err = h.service.DeleteExpense(groupID, expenseID, parsedID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrPeriodLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -275,3 +644,279 @@ func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RestoreExpense godoc
+// @Summary      Restore a deleted expense
+// @Description  Takes the expense out of the trash so it counts in lists and balances again. Members can restore expenses they recorded, paid for or deleted; owners and admins any.
+// @Tags         expenses
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        expenseId  path      string  true  "Expense ID"
+// @Success      200        {object}  models.ExpenseDetail
+// @Failure      400        {string}  string  "invalid ID"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense not in the trash"
+// @Failure      409        {string}  string  "the period is locked"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/restore [post]
+func (h *Handler) RestoreExpense(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionAddExpense) {
+		http.Error(w, ErrCannotRestore.Error(), http.StatusForbidden)
+		return
+	}
+
+	expense, err := h.service.RestoreExpense(groupID, expenseID, userID, role.Can(models.PermissionEditAnyExpense))
+	if errors.Is(err, ErrCannotRestore) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not in the trash", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrPeriodLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(expense)
+}
+
+// RevertExpenseRequest names the revision to go back to.
+type RevertExpenseRequest struct {
+	Revision int `json:"revision" example:"1"`
+}
+
+// GetHistory godoc
+// @Summary      Get the edit history of an expense
+// @Description  Lists every revision of the expense, newest first, with the fields that changed from the revision before. Payer and split changes are listed per user.
+// @Tags         expenses
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string  true  "Group ID"
+// @Param        expenseId  path      string  true  "Expense ID"
+// @Success      200        {array}   models.ExpenseRevision
+// @Failure      400        {string}  string  "invalid ID"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/history [get]
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.service.GetHistory(groupID, expenseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// RevertExpense godoc
+// @Summary      Revert an expense to an earlier revision
+// @Description  Puts the expense back the way it was at the given revision, recording that as a new revision. Tags and categories deleted since are left out.
+// @Tags         expenses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string                true  "Group ID"
+// @Param        expenseId  path      string                true  "Expense ID"
+// @Param        body       body      RevertExpenseRequest  true  "Revision to revert to"
+// @Success      200        {object}  models.Expense
+// @Failure      400        {string}  string  "invalid request"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "expense or revision not found"
+// @Failure      409        {string}  string  "the period is locked"
+// @Failure      422        {object}  ValidationError  "users are no longer members of the group"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/expenses/{expenseId}/revert [post]
+func (h *Handler) RevertExpense(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	expenseID, err := uuid.Parse(r.PathValue("expenseId"))
+	if err != nil {
+		http.Error(w, "invalid expense ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.canEditExpense(w, r, groupID, expenseID, userID) {
+		return
+	}
+
+	var req RevertExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Revision < 1 {
+		http.Error(w, "revision must be positive", http.StatusBadRequest)
+		return
+	}
+
+	expense, err := h.service.RevertExpense(groupID, expenseID, userID, req.Revision)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrUnknownRevision) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrInvalidSplit) || errors.Is(err, ErrInvalidPayers) || errors.Is(err, ErrInvalidTimeZone) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrPeriodLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		writeValidationError(w, &CreateExpenseRequest{}, invalid)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(expense)
+}
+
+type ApplyRulesResponse struct {
+	Updated int `json:"updated"`
+}
+
+// ApplyCategoryRules godoc
+// @Summary      Re-run the category rules over existing expenses
+// @Description  Categorizes the group's uncategorized expenses, or all of them when overwrite is true, with the first rule each matches. Expenses no rule matches and expenses in the locked period are left unchanged. Each expense that changes category gets a new revision and an activity entry by the caller.
+// @Tags         categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      string   true   "Group ID"
+// @Param        overwrite  query     boolean  false  "Recategorize expenses that already have a category"
+// @Success      200        {object}  ApplyRulesResponse
+// @Failure      400        {string}  string  "invalid request"
+// @Failure      401        {string}  string  "unauthorized"
+// @Failure      403        {string}  string  "forbidden"
+// @Failure      404        {string}  string  "group not found"
+// @Failure      500        {string}  string  "internal error"
+// @Router       /api/groups/{id}/category-rules/apply [post]
+func (h *Handler) ApplyCategoryRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage categories", http.StatusForbidden)
+		return
+	}
+
+	var overwrite bool
+	if value := r.URL.Query().Get("overwrite"); value != "" {
+		overwrite, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "invalid overwrite", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updated, err := h.service.ApplyCategoryRules(groupID, userID, overwrite)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ApplyRulesResponse{Updated: updated})
+}
+
+// canEditExpense lets admins edit any expense and members only the ones they
+// recorded or paid for, in full or in part. It writes the error response itself and returns false when denied.
+func (h *Handler) canEditExpense(w http.ResponseWriter, r *http.Request, groupID, expenseID, userID uuid.UUID) bool {
+	role := middleware.GetGroupRole(r)
+	if role.Can(models.PermissionEditAnyExpense) {
+		return true
+	}
+
+	expense, err := h.service.GetExpense(groupID, expenseID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "expense not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	involved := expense.CreatedBy == userID
+	for _, payer := range expense.Payers {
+		involved = involved || payer.UserID == userID
+	}
+	if !role.Can(models.PermissionAddExpense) || !involved {
+		http.Error(w, "you do not have permission to edit this expense", http.StatusForbidden)
+		return false
+	}
+	return true
+}
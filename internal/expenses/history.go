@@ -0,0 +1,498 @@
+package expenses
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/database"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ErrUnknownRevision is returned when an expense is reverted to a revision it
+// doesn't have.
+var ErrUnknownRevision = errors.New("revision not found")
+
+// GetHistory returns every revision of the expense, newest first, each with
+// the changes from the one before. Expenses recorded before revisions were
+// kept and never edited since have their current state as only revision.
+// Expenses in the trash are not found.
+func (s *Service) GetHistory(groupID, expenseID uuid.UUID) ([]models.ExpenseRevision, error) {
+	rows, err := s.db.Query(`SELECT r.revision, r.snapshot, r.edited_by, u.name, r.reverted_from, r.created_at
+		FROM expense_revisions r
+		JOIN expenses e ON e.id = r.expense_id
+		JOIN users u ON u.id = r.edited_by
+		WHERE r.expense_id = $1 AND e.group_id = $2 AND e.deleted_at IS NULL
+		ORDER BY r.revision`, expenseID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.ExpenseRevision
+	for rows.Next() {
+		revision := models.ExpenseRevision{ExpenseID: expenseID}
+		var snapshot []byte
+		var revertedFrom sql.NullInt32
+		if err := rows.Scan(&revision.Revision, &snapshot, &revision.EditedBy, &revision.EditorName, &revertedFrom, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		if revision.Snapshot, err = parseSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		if revertedFrom.Valid {
+			from := int(revertedFrom.Int32)
+			revision.RevertedFrom = &from
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		revision := models.ExpenseRevision{ExpenseID: expenseID, Revision: 1}
+		err := s.db.QueryRow(`SELECT e.created_by, u.name, e.created_at FROM expenses e
+			JOIN users u ON u.id = e.created_by
+			WHERE e.id = $1 AND e.group_id = $2 AND e.deleted_at IS NULL`, expenseID, groupID).
+			Scan(&revision.EditedBy, &revision.EditorName, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if revision.Snapshot, err = loadSnapshot(s.db, expenseID); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	for i := range revisions {
+		revisions[i].Changes = []models.FieldChange{}
+		if i > 0 {
+			revisions[i].Changes = diffSnapshots(revisions[i-1].Snapshot, revisions[i].Snapshot)
+		}
+	}
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+	return revisions, nil
+}
+
+// RevertExpense puts the expense back the way it was at revision and records
+// that as a new revision edited by editedBy. It fails like UpdateExpense when
+// the old state no longer fits, such as when a participant has left the
+// group; tags and categories deleted since are left out. It returns
+// ErrUnknownRevision when the expense has no such revision.
+func (s *Service) RevertExpense(groupID, expenseID, editedBy uuid.UUID, revision int) (models.Expense, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Expense{}, err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRow(`SELECT r.snapshot FROM expense_revisions r
+		JOIN expenses e ON e.id = r.expense_id
+		WHERE r.expense_id = $1 AND e.group_id = $2 AND r.revision = $3`, expenseID, groupID, revision).Scan(&data)
+	if err == sql.ErrNoRows {
+		return models.Expense{}, ErrUnknownRevision
+	}
+	if err != nil {
+		return models.Expense{}, err
+	}
+	snapshot, err := parseSnapshot(data)
+	if err != nil {
+		return models.Expense{}, err
+	}
+
+	in, err := revisionInput(snapshot)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	in.TagIDs, err = remainingTags(tx, groupID, snapshot.TagIDs)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	before, expense, err := update(tx, groupID, expenseID, in)
+	if err != nil {
+		return models.Expense{}, err
+	}
+
+	// the category rules may have picked another one
+	var category uuid.NullUUID
+	if snapshot.CategoryID != nil {
+		category = uuid.NullUUID{UUID: *snapshot.CategoryID, Valid: true}
+	}
+	err = tx.QueryRow(`UPDATE expenses SET category_id = (SELECT id FROM categories WHERE id = $1) WHERE id = $2 RETURNING category_id`,
+		category, expenseID).Scan(&category)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	expense.CategoryID = nil
+	if category.Valid {
+		expense.CategoryID = &category.UUID
+	}
+
+	if err := recordRevision(tx, expenseID, editedBy, &revision); err != nil {
+		return models.Expense{}, err
+	}
+	if err := recordActivity(tx, editedBy, models.VerbReverted, &before, &expense); err != nil {
+		return models.Expense{}, err
+	}
+	return expense, tx.Commit()
+}
+
+// recordRevision stores the current state of the expense as its next
+// revision.
+func recordRevision(tx *sql.Tx, expenseID, editedBy uuid.UUID, revertedFrom *int) error {
+	snapshot, err := loadSnapshot(tx, expenseID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO expense_revisions (expense_id, revision, snapshot, edited_by, reverted_from)
+		VALUES ($1, (SELECT COALESCE(MAX(revision), 0) + 1 FROM expense_revisions WHERE expense_id = $1), $2, $3, $4)`,
+		expenseID, data, editedBy, revertedFrom)
+	return err
+}
+
+// recordFirstRevision stores the current state of an expense recorded before
+// revisions were kept as its first revision, by whoever created it.
+func recordFirstRevision(tx *sql.Tx, expenseID uuid.UUID) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM expense_revisions WHERE expense_id = $1)`, expenseID).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	snapshot, err := loadSnapshot(tx, expenseID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO expense_revisions (expense_id, revision, snapshot, edited_by, created_at)
+		SELECT id, 1, $2, created_by, created_at FROM expenses WHERE id = $1`, expenseID, data)
+	return err
+}
+
+// loadSnapshot reads the current state of the expense.
+func loadSnapshot(q querier, expenseID uuid.UUID) (models.ExpenseSnapshot, error) {
+	expense, err := scanExpense(q.QueryRow(`SELECT `+expenseColumns+` FROM expenses WHERE id = $1`, expenseID))
+	if err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	snapshot := models.ExpenseSnapshot{
+		Description: expense.Description,
+		Amount:      expense.Amount,
+		Currency:    expense.Currency,
+		SplitType:   expense.SplitType,
+		IncurredOn:  expense.IncurredOn,
+		TimeZone:    expense.TimeZone,
+		CategoryID:  expense.CategoryID,
+		TagIDs:      []uuid.UUID{},
+	}
+
+	rows, err := q.Query(`SELECT user_id, amount FROM expense_payers WHERE expense_id = $1`, expenseID)
+	if err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payer models.ExpensePayer
+		if err := rows.Scan(&payer.UserID, &payer.Amount); err != nil {
+			return models.ExpenseSnapshot{}, err
+		}
+		snapshot.Payers = append(snapshot.Payers, payer)
+	}
+	if err := rows.Err(); err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	rows.Close()
+	sortPayers(snapshot.Payers)
+
+	rows, err = q.Query(`SELECT user_id, amount, share FROM expense_splits WHERE expense_id = $1 ORDER BY user_id`, expenseID)
+	if err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var split models.SplitSnapshot
+		if err := rows.Scan(&split.UserID, &split.Amount, &split.Share); err != nil {
+			return models.ExpenseSnapshot{}, err
+		}
+		snapshot.Splits = append(snapshot.Splits, split)
+	}
+	if err := rows.Err(); err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+
+	if expense.SplitType == models.SplitItemized {
+		if snapshot.Receipt, err = loadReceipt(q, expense); err != nil {
+			return models.ExpenseSnapshot{}, err
+		}
+	}
+	byExpense, err := tags.ExpenseTags(q, database.UUIDs{expenseID})
+	if err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	for _, tag := range byExpense[expenseID] {
+		snapshot.TagIDs = append(snapshot.TagIDs, tag.ID)
+	}
+	return snapshotWithCurrency(snapshot), nil
+}
+
+// parseSnapshot decodes a stored snapshot. Amounts are stored without their
+// currency, which is set back from the snapshot's.
+func parseSnapshot(data []byte) (models.ExpenseSnapshot, error) {
+	var snapshot models.ExpenseSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return models.ExpenseSnapshot{}, err
+	}
+	return snapshotWithCurrency(snapshot), nil
+}
+
+func snapshotWithCurrency(snapshot models.ExpenseSnapshot) models.ExpenseSnapshot {
+	snapshot.Amount.Currency = snapshot.Currency
+	snapshot.Payers = withCurrency(snapshot.Payers, snapshot.Currency)
+	for i := range snapshot.Splits {
+		snapshot.Splits[i].Amount.Currency = snapshot.Currency
+	}
+	snapshot.Receipt = receiptWithCurrency(snapshot.Receipt, snapshot.Currency)
+	return snapshot
+}
+
+// revisionInput turns a snapshot back into the input that produces it. Tags
+// are left to the caller.
+func revisionInput(snapshot models.ExpenseSnapshot) (ExpenseInput, error) {
+	day, err := time.Parse(models.DateLayout, snapshot.IncurredOn)
+	if err != nil {
+		return ExpenseInput{}, err
+	}
+	in := ExpenseInput{
+		Description: snapshot.Description,
+		Amount:      snapshot.Amount,
+		SplitType:   snapshot.SplitType,
+		IncurredOn:  day,
+		TimeZone:    snapshot.TimeZone,
+	}
+	for _, payer := range snapshot.Payers {
+		in.Payers = append(in.Payers, PayerInput{UserID: payer.UserID, Amount: payer.Amount})
+	}
+
+	if snapshot.SplitType == models.SplitItemized {
+		if receipt := snapshot.Receipt; receipt != nil {
+			in.Receipt = &ReceiptInput{Tax: receipt.Tax, ServiceCharge: receipt.ServiceCharge, Tip: receipt.Tip}
+			for _, item := range receipt.Items {
+				line := ItemInput{Description: item.Description, Price: item.Price}
+				for _, share := range item.Shares {
+					line.UserIDs = append(line.UserIDs, share.UserID)
+				}
+				in.Receipt.Items = append(in.Receipt.Items, line)
+			}
+		}
+		return in, nil
+	}
+
+	for _, split := range snapshot.Splits {
+		participant := SplitInput{UserID: split.UserID}
+		switch snapshot.SplitType {
+		case models.SplitExact:
+			participant.Amount = split.Amount
+		case models.SplitAdjustment:
+			// only fixed amounts have a share; the rest shared the remainder
+			if split.Share != nil {
+				participant.Amount = models.NewMoney(int64(*split.Share), snapshot.Currency)
+			}
+		case models.SplitPercentage, models.SplitShares:
+			if split.Share != nil {
+				participant.Share = *split.Share
+			}
+		}
+		in.Splits = append(in.Splits, participant)
+	}
+	return in, nil
+}
+
+// remainingTags returns the tags of tagIDs the group still has.
+func remainingTags(tx *sql.Tx, groupID uuid.UUID, tagIDs []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(`SELECT id FROM tags WHERE group_id = $1 AND id = ANY($2::uuid[])`, groupID, database.UUIDs(tagIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	remaining := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, id)
+	}
+	return remaining, rows.Err()
+}
+
+// diffSnapshots lists the fields that differ between two revisions. Payers
+// and splits are compared per user, in user ID order.
+func diffSnapshots(from, to models.ExpenseSnapshot) []models.FieldChange {
+	changes := []models.FieldChange{}
+	change := func(field string, from, to any) {
+		changes = append(changes, models.FieldChange{Field: field, From: from, To: to})
+	}
+
+	if from.Description != to.Description {
+		change("description", from.Description, to.Description)
+	}
+	if from.Amount.Minor != to.Amount.Minor {
+		change("amount", from.Amount, to.Amount)
+	}
+	if from.Currency != to.Currency {
+		change("currency", from.Currency, to.Currency)
+	}
+	if from.SplitType != to.SplitType {
+		change("split_type", from.SplitType, to.SplitType)
+	}
+	if from.IncurredOn != to.IncurredOn {
+		change("incurred_on", from.IncurredOn, to.IncurredOn)
+	}
+	if from.TimeZone != to.TimeZone {
+		change("time_zone", from.TimeZone, to.TimeZone)
+	}
+	if !sameID(from.CategoryID, to.CategoryID) {
+		change("category_id", from.CategoryID, to.CategoryID)
+	}
+	if !sameIDs(from.TagIDs, to.TagIDs) {
+		change("tag_ids", from.TagIDs, to.TagIDs)
+	}
+
+	fromPayers := make(map[uuid.UUID]models.Money)
+	toPayers := make(map[uuid.UUID]models.Money)
+	for _, p := range from.Payers {
+		fromPayers[p.UserID] = p.Amount
+	}
+	for _, p := range to.Payers {
+		toPayers[p.UserID] = p.Amount
+	}
+	for _, userID := range userIDs(fromPayers, toPayers) {
+		before, wasPayer := fromPayers[userID]
+		after, isPayer := toPayers[userID]
+		if wasPayer == isPayer && before.Minor == after.Minor {
+			continue
+		}
+		c := models.FieldChange{Field: "payers", UserID: &userID}
+		if wasPayer {
+			c.From = before
+		}
+		if isPayer {
+			c.To = after
+		}
+		changes = append(changes, c)
+	}
+
+	fromSplits := make(map[uuid.UUID]models.SplitSnapshot)
+	toSplits := make(map[uuid.UUID]models.SplitSnapshot)
+	for _, s := range from.Splits {
+		fromSplits[s.UserID] = s
+	}
+	for _, s := range to.Splits {
+		toSplits[s.UserID] = s
+	}
+	for _, userID := range userIDs(fromSplits, toSplits) {
+		before, wasIn := fromSplits[userID]
+		after, isIn := toSplits[userID]
+		if wasIn == isIn && before.Amount.Minor == after.Amount.Minor && sameWeight(before.Share, after.Share) {
+			continue
+		}
+		c := models.FieldChange{Field: "splits", UserID: &userID}
+		if wasIn {
+			c.From = before
+		}
+		if isIn {
+			c.To = after
+		}
+		changes = append(changes, c)
+	}
+
+	if !sameReceipt(from.Receipt, to.Receipt) {
+		change("receipt", from.Receipt, to.Receipt)
+	}
+	return changes
+}
+
+// userIDs returns the users in either map, sorted.
+func userIDs[V any](a, b map[uuid.UUID]V) []uuid.UUID {
+	var ids []uuid.UUID
+	for id := range a {
+		ids = append(ids, id)
+	}
+	for id := range b {
+		if _, ok := a[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+func sameID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameWeight(a, b *models.Weight) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameIDs reports whether a and b hold the same IDs in any order.
+func sameIDs(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameReceipt compares receipts by content. Item IDs are ignored since
+// items are stored anew on every update.
+func sameReceipt(a, b *models.Receipt) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Tax.Minor != b.Tax.Minor || a.ServiceCharge.Minor != b.ServiceCharge.Minor || a.Tip.Minor != b.Tip.Minor ||
+		len(a.Items) != len(b.Items) {
+		return false
+	}
+	for i, item := range a.Items {
+		other := b.Items[i]
+		if item.Description != other.Description || item.Price.Minor != other.Price.Minor || len(item.Shares) != len(other.Shares) {
+			return false
+		}
+		for j, share := range item.Shares {
+			if share.UserID != other.Shares[j].UserID || share.Amount.Minor != other.Shares[j].Amount.Minor {
+				return false
+			}
+		}
+	}
+	return true
+}
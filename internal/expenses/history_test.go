@@ -0,0 +1,125 @@
+package expenses
+
+import (
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	share := models.Weight(2)
+	before := models.ExpenseSnapshot{
+		Description: "Dinner",
+		Amount:      models.NewMoney(6000, "EUR"),
+		Currency:    "EUR",
+		SplitType:   models.SplitEqual,
+		IncurredOn:  "2024-01-02",
+		TagIDs:      []uuid.UUID{userA, userB},
+		Payers:      []models.ExpensePayer{{UserID: userA, Amount: models.NewMoney(6000, "EUR")}},
+		Splits: []models.SplitSnapshot{
+			{UserID: userA, Amount: models.NewMoney(3000, "EUR")},
+			{UserID: userB, Amount: models.NewMoney(3000, "EUR")},
+		},
+	}
+	after := before
+	after.Description = "Dinner at Ana's"
+	after.TagIDs = []uuid.UUID{userB, userA}
+	after.SplitType = models.SplitShares
+	after.Payers = []models.ExpensePayer{{UserID: userB, Amount: models.NewMoney(6000, "EUR")}}
+	after.Splits = []models.SplitSnapshot{
+		{UserID: userA, Amount: models.NewMoney(3000, "EUR"), Share: &share},
+		{UserID: userC, Amount: models.NewMoney(3000, "EUR"), Share: &share},
+	}
+
+	changes := diffSnapshots(before, after)
+	want := []struct {
+		field  string
+		userID *uuid.UUID
+	}{
+		{"description", nil},
+		{"split_type", nil},
+		{"payers", &userA},
+		{"payers", &userB},
+		{"splits", &userA},
+		{"splits", &userB},
+		{"splits", &userC},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.Field != w.field || (w.userID == nil) != (c.UserID == nil) || (w.userID != nil && *c.UserID != *w.userID) {
+			t.Errorf("change %d: expected %s of %v, got %+v", i, w.field, w.userID, c)
+		}
+	}
+
+	// a removed payer has no To and an added one no From
+	if changes[2].To != nil || changes[3].From != nil {
+		t.Errorf("expected userA's payment to be removed and userB's added, got %+v and %+v", changes[2], changes[3])
+	}
+	if changes[5].To != nil || changes[6].From != nil {
+		t.Errorf("expected userB's split to be removed and userC's added, got %+v and %+v", changes[5], changes[6])
+	}
+
+	if changes := diffSnapshots(before, before); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDiffSnapshots_Receipt(t *testing.T) {
+	receipt := func(itemID uuid.UUID, price int64) *models.Receipt {
+		return &models.Receipt{Items: []models.ExpenseItem{{
+			ID: itemID, Description: "Pizza", Price: models.NewMoney(price, "EUR"),
+			Shares: []models.ItemShare{{UserID: userA, Amount: models.NewMoney(price, "EUR")}},
+		}}}
+	}
+	before := models.ExpenseSnapshot{SplitType: models.SplitItemized, Receipt: receipt(uuid.New(), 1200)}
+	after := models.ExpenseSnapshot{SplitType: models.SplitItemized, Receipt: receipt(uuid.New(), 1200)}
+
+	// items get new IDs on every update
+	if changes := diffSnapshots(before, after); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+	after.Receipt = receipt(uuid.New(), 1500)
+	if changes := diffSnapshots(before, after); len(changes) != 1 || changes[0].Field != "receipt" {
+		t.Errorf("expected the receipt to change, got %+v", changes)
+	}
+}
+
+func TestRevisionInput(t *testing.T) {
+	fixed := models.Weight(1000)
+	snapshot := models.ExpenseSnapshot{
+		Description: "Hotel",
+		Amount:      models.NewMoney(9000, "EUR"),
+		Currency:    "EUR",
+		SplitType:   models.SplitAdjustment,
+		IncurredOn:  "2024-01-02",
+		Payers:      []models.ExpensePayer{{UserID: userA, Amount: models.NewMoney(9000, "EUR")}},
+		Splits: []models.SplitSnapshot{
+			{UserID: userA, Amount: models.NewMoney(1000, "EUR"), Share: &fixed},
+			{UserID: userB, Amount: models.NewMoney(4000, "EUR")},
+			{UserID: userC, Amount: models.NewMoney(4000, "EUR")},
+		},
+	}
+
+	in, err := revisionInput(snapshot)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if in.IncurredOn.Format(models.DateLayout) != "2024-01-02" || len(in.Payers) != 1 {
+		t.Errorf("expected the day and payer to be kept, got %+v", in)
+	}
+
+	// the input gives the same splits back
+	splits, _, err := expenseSplits(in)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	for i, split := range splits {
+		if split.UserID != snapshot.Splits[i].UserID || split.Amount != snapshot.Splits[i].Amount {
+			t.Errorf("split %d: expected %+v, got %+v", i, snapshot.Splits[i], split)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package expenses
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidPayers is returned when the payers don't add up to the expense
+// amount.
+var ErrInvalidPayers = errors.New("invalid payers")
+
+// PayerInput is one user who paid part of an expense.
+type PayerInput struct {
+	UserID uuid.UUID
+	Amount models.Money
+}
+
+// computePayers checks the payers of an expense and returns them largest
+// amount first, ties going to the lowest user ID. The first payer is the one
+// stored as the expense's paid_by.
+func computePayers(total models.Money, inputs []PayerInput) ([]models.ExpensePayer, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: at least one payer is required", ErrInvalidPayers)
+	}
+
+	payers := make([]models.ExpensePayer, len(inputs))
+	var sum models.Money
+	seen := make(map[uuid.UUID]bool)
+	for i, in := range inputs {
+		if seen[in.UserID] {
+			return nil, fmt.Errorf("%w: user %s appears more than once", ErrInvalidPayers, in.UserID)
+		}
+		seen[in.UserID] = true
+		if !in.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: amounts must be positive", ErrInvalidPayers)
+		}
+		payers[i] = models.ExpensePayer{UserID: in.UserID, Amount: models.NewMoney(in.Amount.Minor, total.Currency)}
+		sum = sum.Add(in.Amount)
+	}
+	if sum.Minor != total.Minor {
+		return nil, fmt.Errorf("%w: payers must add up to total amount", ErrInvalidPayers)
+	}
+
+	sortPayers(payers)
+	return payers, nil
+}
+
+func sortPayers(payers []models.ExpensePayer) {
+	sort.Slice(payers, func(i, j int) bool {
+		if payers[i].Amount.Minor != payers[j].Amount.Minor {
+			return payers[i].Amount.Minor > payers[j].Amount.Minor
+		}
+		return payers[i].UserID.String() < payers[j].UserID.String()
+	})
+}
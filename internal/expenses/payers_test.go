@@ -0,0 +1,52 @@
+package expenses
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+func TestComputePayers(t *testing.T) {
+	payers, err := computePayers(models.NewMoney(10000, "EUR"), []PayerInput{
+		{UserID: userC, Amount: models.NewMoney(3000, "")},
+		{UserID: userB, Amount: models.NewMoney(4000, "")},
+		{UserID: userA, Amount: models.NewMoney(3000, "")},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	// largest amount first, ties by user ID
+	want := []models.ExpensePayer{
+		{UserID: userB, Amount: models.NewMoney(4000, "EUR")},
+		{UserID: userA, Amount: models.NewMoney(3000, "EUR")},
+		{UserID: userC, Amount: models.NewMoney(3000, "EUR")},
+	}
+	for i := range want {
+		if payers[i] != want[i] {
+			t.Errorf("payer %d: expected %+v, got %+v", i, want[i], payers[i])
+		}
+	}
+}
+
+func TestComputePayers_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []PayerInput
+	}{
+		{"no payers", nil},
+		{"short of the total", []PayerInput{{UserID: userA, Amount: models.NewMoney(9000, "")}}},
+		{"over the total", []PayerInput{{UserID: userA, Amount: models.NewMoney(6000, "")}, {UserID: userB, Amount: models.NewMoney(5000, "")}}},
+		{"zero amount", []PayerInput{{UserID: userA, Amount: models.NewMoney(10000, "")}, {UserID: userB}}},
+		{"duplicate payer", []PayerInput{{UserID: userA, Amount: models.NewMoney(5000, "")}, {UserID: userA, Amount: models.NewMoney(5000, "")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := computePayers(models.NewMoney(10000, "EUR"), tt.inputs)
+			if !errors.Is(err, ErrInvalidPayers) {
+				t.Errorf("expected ErrInvalidPayers, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package expenses
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ErrPeriodLocked is returned when an expense incurred on or before the
+// group's locked_until day would be added, changed or deleted.
+var ErrPeriodLocked = errors.New("the period is locked")
+
+// ErrInvalidTimeZone is returned for time zones that are not IANA names.
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+// incurredOn returns day as a date, or today in timeZone (UTC when empty)
+// when day is zero.
+func incurredOn(day time.Time, timeZone string) (time.Time, error) {
+	loc, err := location(timeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if day.IsZero() {
+		day = time.Now().In(loc)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func location(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil || timeZone == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, timeZone)
+	}
+	return loc, nil
+}
+
+// checkUnlocked returns an error wrapping ErrPeriodLocked when any of days
+// is on or before the group's locked_until. The group row stays locked until
+// tx ends so the lock can't move while the expense is written.
+func checkUnlocked(tx *sql.Tx, groupID uuid.UUID, days ...time.Time) error {
+	var lockedUntil sql.NullTime
+	err := tx.QueryRow(`SELECT locked_until FROM groups WHERE id = $1 FOR SHARE`, groupID).Scan(&lockedUntil)
+	if err != nil || !lockedUntil.Valid {
+		return err
+	}
+
+	until := lockedUntil.Time.Format(models.DateLayout)
+	for _, day := range days {
+		if day.Format(models.DateLayout) <= until {
+			return fmt.Errorf("%w until %s", ErrPeriodLocked, until)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package expenses
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+func TestIncurredOn(t *testing.T) {
+	day := time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("", -5*3600))
+	got, err := incurredOn(day, "America/New_York")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if got.Format(models.DateLayout) != "2024-03-09" {
+		t.Errorf("expected the given day to be kept, got %s", got.Format(models.DateLayout))
+	}
+
+	got, err = incurredOn(time.Time{}, "")
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if want := time.Now().UTC().Format(models.DateLayout); got.Format(models.DateLayout) != want {
+		t.Errorf("expected today in UTC %s, got %s", want, got.Format(models.DateLayout))
+	}
+
+	for _, zone := range []string{"Local", "Mars/Olympus_Mons", "+02:00"} {
+		if _, err := incurredOn(time.Time{}, zone); !errors.Is(err, ErrInvalidTimeZone) {
+			t.Errorf("expected ErrInvalidTimeZone for %q, got %v", zone, err)
+		}
+	}
+}
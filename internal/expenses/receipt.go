@@ -0,0 +1,212 @@
+package expenses
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ReceiptInput is the receipt an itemized expense is split from.
+type ReceiptInput struct {
+	Items         []ItemInput
+	Tax           models.Money
+	ServiceCharge models.Money
+	Tip           models.Money
+}
+
+// ItemInput is a receipt line shared equally by the users assigned to it.
+type ItemInput struct {
+	Description string
+	Price       models.Money
+	UserIDs     []uuid.UUID
+}
+
+// expenseSplits computes the splits of an expense, and its receipt when it is
+// itemized.
+func expenseSplits(in ExpenseInput) ([]models.ExpenseSplit, *models.Receipt, error) {
+	if in.SplitType != models.SplitItemized {
+		splits, err := computeSplits(in.SplitType, in.Amount, in.Splits)
+		return splits, nil, err
+	}
+	if in.Receipt == nil {
+		return nil, nil, fmt.Errorf("%w: itemized expenses need items", ErrInvalidSplit)
+	}
+	return computeReceipt(in.Amount, *in.Receipt)
+}
+
+// computeReceipt splits every item equally between its users, then shares
+// tax, service charge and tip in proportion to each user's item subtotal. The
+// items and extras must add up to total. As with the other split types,
+// leftover cents go to the lowest user IDs.
+func computeReceipt(total models.Money, in ReceiptInput) ([]models.ExpenseSplit, *models.Receipt, error) {
+	if len(in.Items) == 0 {
+		return nil, nil, fmt.Errorf("%w: itemized expenses need items", ErrInvalidSplit)
+	}
+	for _, extra := range []models.Money{in.Tax, in.ServiceCharge, in.Tip} {
+		if extra.IsNegative() {
+			return nil, nil, fmt.Errorf("%w: tax, service charge and tip must not be negative", ErrInvalidSplit)
+		}
+	}
+
+	receipt := &models.Receipt{
+		Tax:           models.NewMoney(in.Tax.Minor, total.Currency),
+		ServiceCharge: models.NewMoney(in.ServiceCharge.Minor, total.Currency),
+		Tip:           models.NewMoney(in.Tip.Minor, total.Currency),
+	}
+	subtotals := make(map[uuid.UUID]models.Money)
+	sum := receipt.Tax.Add(receipt.ServiceCharge).Add(receipt.Tip)
+
+	for _, item := range in.Items {
+		if !item.Price.IsPositive() {
+			return nil, nil, fmt.Errorf("%w: item prices must be positive", ErrInvalidSplit)
+		}
+		if len(item.UserIDs) == 0 {
+			return nil, nil, fmt.Errorf("%w: item %q has nobody assigned", ErrInvalidSplit, item.Description)
+		}
+
+		users := make([]uuid.UUID, len(item.UserIDs))
+		copy(users, item.UserIDs)
+		sort.Slice(users, func(i, j int) bool { return users[i].String() < users[j].String() })
+		for i := 1; i < len(users); i++ {
+			if users[i] == users[i-1] {
+				return nil, nil, fmt.Errorf("%w: user %s appears more than once on item %q", ErrInvalidSplit, users[i], item.Description)
+			}
+		}
+
+		price := models.NewMoney(item.Price.Minor, total.Currency)
+		parts, err := price.Split(len(users))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		line := models.ExpenseItem{Description: item.Description, Price: price}
+		for i, userID := range users {
+			line.Shares = append(line.Shares, models.ItemShare{UserID: userID, Amount: parts[i]})
+			subtotals[userID] = subtotals[userID].Add(parts[i])
+		}
+		receipt.Items = append(receipt.Items, line)
+		sum = sum.Add(price)
+	}
+	if sum.Minor != total.Minor {
+		return nil, nil, fmt.Errorf("%w: items, tax, service charge and tip must add up to total amount", ErrInvalidSplit)
+	}
+
+	splits := make([]models.ExpenseSplit, 0, len(subtotals))
+	for userID, subtotal := range subtotals {
+		splits = append(splits, models.ExpenseSplit{UserID: userID, Amount: subtotal})
+	}
+	sort.Slice(splits, func(i, j int) bool { return splits[i].UserID.String() < splits[j].UserID.String() })
+
+	weights := make([]int64, len(splits))
+	for i, split := range splits {
+		weights[i] = split.Amount.Minor
+	}
+	for _, extra := range []models.Money{receipt.Tax, receipt.ServiceCharge, receipt.Tip} {
+		if extra.IsZero() {
+			continue
+		}
+		parts, err := extra.Allocate(weights)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		for i := range splits {
+			splits[i].Amount = splits[i].Amount.Add(parts[i])
+		}
+	}
+	for i := range splits {
+		splits[i].Amount.Currency = total.Currency
+	}
+	return splits, receipt, nil
+}
+
+func insertReceipt(tx *sql.Tx, expenseID uuid.UUID, receipt *models.Receipt) error {
+	if receipt == nil {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO expense_receipts (expense_id, tax, service_charge, tip) VALUES ($1, $2, $3, $4)`,
+		expenseID, receipt.Tax, receipt.ServiceCharge, receipt.Tip)
+	if err != nil {
+		return err
+	}
+
+	for i := range receipt.Items {
+		item := &receipt.Items[i]
+		item.ID = uuid.New()
+		_, err := tx.Exec(`INSERT INTO expense_items (id, expense_id, position, description, price) VALUES ($1, $2, $3, $4, $5)`,
+			item.ID, expenseID, i, item.Description, item.Price)
+		if err != nil {
+			return err
+		}
+		for _, share := range item.Shares {
+			_, err := tx.Exec(`INSERT INTO expense_item_shares (item_id, user_id, amount) VALUES ($1, $2, $3)`,
+				item.ID, share.UserID, share.Amount)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// querier is a *sql.DB or a *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// loadReceipt reads the receipt of an itemized expense.
+func loadReceipt(q querier, expense models.Expense) (*models.Receipt, error) {
+	receipt := &models.Receipt{}
+	err := q.QueryRow(`SELECT tax, service_charge, tip FROM expense_receipts WHERE expense_id = $1`, expense.ID).
+		Scan(&receipt.Tax, &receipt.ServiceCharge, &receipt.Tip)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`SELECT i.id, i.description, i.price, s.user_id, s.amount
+		FROM expense_items i
+		JOIN expense_item_shares s ON s.item_id = i.id
+		WHERE i.expense_id = $1
+		ORDER BY i.position, s.user_id`, expense.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.ExpenseItem
+		var share models.ItemShare
+		if err := rows.Scan(&item.ID, &item.Description, &item.Price, &share.UserID, &share.Amount); err != nil {
+			return nil, err
+		}
+		if n := len(receipt.Items); n == 0 || receipt.Items[n-1].ID != item.ID {
+			receipt.Items = append(receipt.Items, item)
+		}
+		last := &receipt.Items[len(receipt.Items)-1]
+		last.Shares = append(last.Shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return receiptWithCurrency(receipt, expense.Currency), nil
+}
+
+// receiptWithCurrency sets the expense's currency on every amount of the
+// receipt, which may be nil.
+func receiptWithCurrency(receipt *models.Receipt, currency string) *models.Receipt {
+	if receipt == nil {
+		return nil
+	}
+	receipt.Tax.Currency = currency
+	receipt.ServiceCharge.Currency = currency
+	receipt.Tip.Currency = currency
+	for i := range receipt.Items {
+		receipt.Items[i].Price.Currency = currency
+		for j := range receipt.Items[i].Shares {
+			receipt.Items[i].Shares[j].Amount.Currency = currency
+		}
+	}
+	return receipt
+}
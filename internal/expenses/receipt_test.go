@@ -0,0 +1,77 @@
+package expenses
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+func TestComputeReceipt(t *testing.T) {
+	receipt := ReceiptInput{
+		Items: []ItemInput{
+			{Description: "Pizza", Price: models.NewMoney(2000, ""), UserIDs: []uuid.UUID{userB, userA}},
+			{Description: "Wine", Price: models.NewMoney(3000, ""), UserIDs: []uuid.UUID{userA}},
+			{Description: "Salad", Price: models.NewMoney(1000, ""), UserIDs: []uuid.UUID{userC}},
+		},
+		Tax: models.NewMoney(600, ""),
+		Tip: models.NewMoney(301, ""),
+	}
+
+	splits, got, err := computeReceipt(models.NewMoney(6901, "EUR"), receipt)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	// subtotals 40/10/10: tax 4.00/1.00/1.00, tip 2.01/0.50/0.50
+	want := map[uuid.UUID]int64{userA: 4601, userB: 1150, userC: 1150}
+	if len(splits) != len(want) {
+		t.Fatalf("expected %d splits, got %d", len(want), len(splits))
+	}
+	for _, split := range splits {
+		if split.Amount.Minor != want[split.UserID] {
+			t.Errorf("expected %d for %s, got %s", want[split.UserID], split.UserID, split.Amount)
+		}
+		if split.Amount.Currency != "EUR" {
+			t.Errorf("expected split in EUR, got %q", split.Amount.Currency)
+		}
+	}
+
+	if len(got.Items) != 3 || got.Items[0].Description != "Pizza" {
+		t.Fatalf("expected the 3 items in order, got %+v", got.Items)
+	}
+	pizza := got.Items[0].Shares
+	if len(pizza) != 2 || pizza[0].UserID != userA || pizza[0].Amount.Minor != 1000 || pizza[1].Amount.Minor != 1000 {
+		t.Errorf("expected the pizza shared 10.00/10.00 by A and B, got %+v", pizza)
+	}
+	if got.Tip.Minor != 301 || got.ServiceCharge.Minor != 0 {
+		t.Errorf("expected tip 3.01 and no service charge, got %s and %s", got.Tip, got.ServiceCharge)
+	}
+}
+
+func TestComputeReceipt_Invalid(t *testing.T) {
+	item := func(price int64, users ...uuid.UUID) ItemInput {
+		return ItemInput{Description: "Item", Price: models.NewMoney(price, ""), UserIDs: users}
+	}
+	tests := []struct {
+		name    string
+		receipt ReceiptInput
+	}{
+		{"no items", ReceiptInput{}},
+		{"nobody assigned", ReceiptInput{Items: []ItemInput{item(1000)}}},
+		{"zero price", ReceiptInput{Items: []ItemInput{item(0, userA), item(1000, userB)}}},
+		{"duplicate user", ReceiptInput{Items: []ItemInput{item(1000, userA, userA)}}},
+		{"short of the total", ReceiptInput{Items: []ItemInput{item(900, userA)}}},
+		{"extras over the total", ReceiptInput{Items: []ItemInput{item(1000, userA)}, Tip: models.NewMoney(100, "")}},
+		{"negative tax", ReceiptInput{Items: []ItemInput{item(1100, userA)}, Tax: models.NewMoney(-100, "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := computeReceipt(models.NewMoney(1000, "EUR"), tt.receipt)
+			if !errors.Is(err, ErrInvalidSplit) {
+				t.Errorf("expected ErrInvalidSplit, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package expenses
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/IvanLouren/GoSplit/internal/categories"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ApplyCategoryRules runs the group's category rules over its existing
+// expenses on behalf of editedBy and returns how many changed category.
+// Expenses that already have a category are only recategorized when
+// overwrite is set, and expenses in the group's locked period are left alone.
+// Every change is recorded as a revision of the expense and in the group's
+// activity, like an edit by editedBy.
+func (s *Service) ApplyCategoryRules(groupID, editedBy uuid.UUID, overwrite bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rules, err := categories.GroupRules(tx, groupID)
+	if err != nil || len(rules) == 0 {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT e.id, e.description, e.amount, e.category_id,
+			(SELECT array_agg(p.user_id::text) FROM expense_payers p WHERE p.expense_id = e.id)
+		FROM expenses e
+		JOIN groups g ON g.id = e.group_id
+		WHERE e.group_id = $1 AND e.deleted_at IS NULL AND (g.locked_until IS NULL OR e.incurred_on > g.locked_until)
+			AND ($2 OR e.category_id IS NULL)
+		ORDER BY e.id
+		FOR UPDATE OF e`, groupID, overwrite)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type change struct{ expenseID, categoryID uuid.UUID }
+	var changes []change
+	for rows.Next() {
+		var id uuid.UUID
+		var c categories.Candidate
+		var current uuid.NullUUID
+		var payers sql.NullString
+		if err := rows.Scan(&id, &c.Description, &c.Amount, &current, &payers); err != nil {
+			return 0, err
+		}
+		for _, payer := range strings.Split(strings.Trim(payers.String, "{}"), ",") {
+			if payerID, err := uuid.Parse(payer); err == nil {
+				c.Payers = append(c.Payers, payerID)
+			}
+		}
+		if categoryID, ok := categories.Match(rules, c); ok && (!current.Valid || current.UUID != categoryID) {
+			changes = append(changes, change{id, categoryID})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, c := range changes {
+		before, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM expenses WHERE id = $1`, c.expenseID))
+		if err != nil {
+			return 0, err
+		}
+		if err := recordFirstRevision(tx, c.expenseID); err != nil {
+			return 0, err
+		}
+		expense, err := scanExpense(tx.QueryRow(`UPDATE expenses SET category_id = $1 WHERE id = $2 RETURNING `+expenseColumns,
+			c.categoryID, c.expenseID))
+		if err != nil {
+			return 0, err
+		}
+		if err := recordRevision(tx, c.expenseID, editedBy, nil); err != nil {
+			return 0, err
+		}
+		if err := recordActivity(tx, editedBy, models.VerbUpdated, &before, &expense); err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), tx.Commit()
+}
@@ -2,11 +2,24 @@ package expenses
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/activity"
+	"github.com/IvanLouren/GoSplit/internal/categories"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/database"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
 
+const expenseColumns = `id, group_id, paid_by, description, amount, currency, split_type, incurred_on, time_zone, category_id, created_by, created_at, deleted_at, deleted_by`
+
+// ErrCannotRestore is returned when a member restores an expense they
+// neither recorded, paid for nor deleted.
+var ErrCannotRestore = errors.New("you do not have permission to restore this expense")
+
 type Service struct {
 	db *sql.DB
 }
@@ -15,115 +28,658 @@ func NewService(db *sql.DB) *Service {
 	return &Service{db: db}
 }
 
+// SplitInput is one participant of an expense. Which fields are used depends
+// on the split type: Amount for exact and adjustment splits (a zero amount
+// takes part in the remainder), Share for percentage and shares splits.
 type SplitInput struct {
 	UserID uuid.UUID
-	Amount float64
+	Amount models.Money
+	Share  models.Weight
+}
+
+// ExpenseInput is what an expense is created or updated from. Splits are
+// used by every split type except itemized, which derives them from Receipt.
+type ExpenseInput struct {
+	Description string
+	Amount      models.Money
+	SplitType   models.SplitType
+	Splits      []SplitInput
+	Payers      []PayerInput
+	Receipt     *ReceiptInput
+	// IncurredOn defaults to today in TimeZone on creation and is left
+	// unchanged on update when zero, as is an empty TimeZone.
+	IncurredOn time.Time
+	TimeZone   string
+	// CategoryID is chosen by the group's category rules when nil. On update
+	// the current category is kept when no rule matches either.
+	CategoryID uuid.UUID
+	// TagIDs are the group's tags to put on the expense. On update nil keeps
+	// the current tags and an empty slice removes them.
+	TagIDs []uuid.UUID
 }
 
-func (s *Service) CreateExpense(groupID uuid.UUID, paidBy uuid.UUID, description string, amount float64, splits []SplitInput) (models.Expense, error) {
+// CreateExpense computes the splits from the inputs and stores them with the
+// expense. The expense is recorded in amount's currency, or the group's
+// currency when it has none. Without payers, createdBy paid the whole amount.
+// It returns an error wrapping ErrInvalidSplit or ErrInvalidPayers when the
+// splits or payers don't fit, ErrPeriodLocked when the expense falls in the
+// group's locked period, tags.ErrUnknownTag when a tag is not the group's,
+// and a *ValidationError when any of the users is not a member of the group.
+func (s *Service) CreateExpense(groupID uuid.UUID, createdBy uuid.UUID, in ExpenseInput) (models.Expense, error) {
+	day, err := incurredOn(in.IncurredOn, in.TimeZone)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	splits, receipt, err := expenseSplits(in)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	payerInputs := in.Payers
+	if len(payerInputs) == 0 {
+		payerInputs = []PayerInput{{UserID: createdBy, Amount: in.Amount}}
+	}
+	payers, err := computePayers(in.Amount, payerInputs)
+	if err != nil {
+		return models.Expense{}, err
+	}
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return models.Expense{}, err
 	}
 	defer tx.Rollback()
 
-	var expense models.Expense
-	err = tx.QueryRow(`INSERT INTO expenses (group_id, paid_by, description, amount) VALUES ($1, $2, $3, $4) RETURNING id, group_id, paid_by, description, amount, created_at`, groupID, paidBy, description, amount).Scan(&expense.ID, &expense.GroupID, &expense.PaidBy, &expense.Description, &expense.Amount, &expense.CreatedAt)
+	if err := checkUnlocked(tx, groupID, day); err != nil {
+		return models.Expense{}, err
+	}
+	if err := checkMembers(tx, groupID, payers, splits); err != nil {
+		return models.Expense{}, err
+	}
+	category, err := expenseCategory(tx, groupID, in, payers, uuid.NullUUID{})
 	if err != nil {
 		return models.Expense{}, err
 	}
 
-	for _, split := range splits {
-		_, err = tx.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expense.ID, split.UserID, split.Amount)
-		if err != nil {
-			return models.Expense{}, err
-		}
+	expense, err := scanExpense(tx.QueryRow(`INSERT INTO expenses (group_id, paid_by, description, amount, currency, split_type, incurred_on, time_zone, category_id, created_by)
+		VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), (SELECT currency FROM groups WHERE id = $1)), $6, $7, NULLIF($8, ''), $9, $10)
+		RETURNING `+expenseColumns, groupID, payers[0].UserID, in.Description, in.Amount, in.Amount.Currency, in.SplitType,
+		day.Format(models.DateLayout), in.TimeZone, category, createdBy))
+	if err != nil {
+		return models.Expense{}, err
+	}
+
+	if err := insertPayers(tx, expense.ID, payers); err != nil {
+		return models.Expense{}, err
+	}
+	if err := insertSplits(tx, expense.ID, splits); err != nil {
+		return models.Expense{}, err
+	}
+	if err := insertReceipt(tx, expense.ID, receipt); err != nil {
+		return models.Expense{}, err
+	}
+	expense.Tags, err = tags.SetExpenseTags(tx, groupID, expense.ID, in.TagIDs)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if err := recordRevision(tx, expense.ID, createdBy, nil); err != nil {
+		return models.Expense{}, err
+	}
+	if err := recordActivity(tx, createdBy, models.VerbCreated, nil, &expense); err != nil {
+		return models.Expense{}, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		return models.Expense{}, err
 	}
+	expense.Payers = withCurrency(payers, expense.Currency)
+	expense.Receipt = receiptWithCurrency(receipt, expense.Currency)
 	return expense, nil
 }
 
-func (s *Service) GetExpenses(groupID uuid.UUID) ([]models.Expense, error) {
-	expenses, err := s.db.Query(`SELECT id, group_id, paid_by, description, amount, created_at FROM expenses WHERE group_id = $1`, groupID)
+// GetExpenses returns a page of the group's expenses matching filter, with
+// their payers and splits, and the cursor of the next page when there is
+// one. It returns ErrInvalidCursor when the cursor was not issued for the
+// same sort order. Receipts are only returned by GetExpense.
+func (s *Service) GetExpenses(groupID uuid.UUID, filter ExpenseFilter) (models.ExpensePage, error) {
+	query, args, err := filter.query(groupID)
+	if err != nil {
+		return models.ExpensePage{}, err
+	}
+	expenses, err := s.loadExpenses(query, args...)
+	if err != nil {
+		return models.ExpensePage{}, err
+	}
+
+	page := models.ExpensePage{Expenses: expenses}
+	if limit := filter.limit(); len(expenses) > limit {
+		page.Expenses = expenses[:limit]
+		sort := filter.Sort
+		if sort == "" {
+			sort = SortNewest
+		}
+		page.NextCursor = encodeCursor(sort, page.Expenses[limit-1].Expense)
+	}
+	return page, nil
+}
+
+// GetExpense returns the expense with its payers, splits and, when it is
+// itemized, its receipt. Expenses in the trash are not found.
+func (s *Service) GetExpense(groupID, expenseID uuid.UUID) (models.ExpenseDetail, error) {
+	result, err := s.loadExpenses(`SELECT `+expenseColumns+` FROM expenses e WHERE e.id = $1 AND e.group_id = $2 AND e.deleted_at IS NULL`, expenseID, groupID)
+	if err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	if len(result) == 0 {
+		return models.ExpenseDetail{}, sql.ErrNoRows
+	}
+
+	expense := result[0]
+	if expense.SplitType == models.SplitItemized {
+		expense.Receipt, err = loadReceipt(s.db, expense.Expense)
+		if err != nil {
+			return models.ExpenseDetail{}, err
+		}
+	}
+	return expense, nil
+}
+
+// loadExpenses reads the expenses query selects, then their payers, splits
+// and tags with one query each whatever the number of expenses.
+func (s *Service) loadExpenses(query string, args ...any) ([]models.ExpenseDetail, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer expenses.Close()
+	defer rows.Close()
 
-	var result []models.Expense
-	for expenses.Next() {
-		var expense models.Expense
-		err = expenses.Scan(&expense.ID, &expense.GroupID, &expense.PaidBy, &expense.Description, &expense.Amount, &expense.CreatedAt)
+	var result []models.ExpenseDetail
+	for rows.Next() {
+		expense, err := scanExpense(rows)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, expense)
+		result = append(result, models.ExpenseDetail{Expense: expense})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
 	}
 
+	index := make(map[uuid.UUID]int, len(result))
+	ids := make(database.UUIDs, len(result))
+	for i, e := range result {
+		index[e.ID] = i
+		ids[i] = e.ID
+	}
+	if err := s.attachPayers(result, index, ids); err != nil {
+		return nil, err
+	}
+	if err := s.attachSplits(result, index, ids); err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(result, ids); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-func (s *Service) GetExpense(expenseID uuid.UUID) (models.Expense, error) {
-	var expense models.Expense
-	err := s.db.QueryRow(`SELECT id, group_id, paid_by, description, amount, created_at FROM expenses WHERE id = $1`, expenseID).
-		Scan(&expense.ID, &expense.GroupID, &expense.PaidBy, &expense.Description, &expense.Amount, &expense.CreatedAt)
+// UpdateExpense replaces the expense's amount, split type, splits and
+// payers. The currency only changes when amount has one. Without payers the
+// current ones are kept when they still add up to amount, and a single payer
+// pays the new amount; several payers must be given again when the amount
+// changes. Payers that are given and every participant must be members of
+// the group. Neither the current nor the new day may be in the group's
+// locked period. The new state is recorded as a revision edited by
+// editedBy.
+func (s *Service) UpdateExpense(groupID, expenseID, editedBy uuid.UUID, in ExpenseInput) (models.Expense, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return models.Expense{}, err
 	}
-	return expense, nil
-}
+	defer tx.Rollback()
 
-func (s *Service) UpdateExpense(expenseID uuid.UUID, description string, amount float64, splits []SplitInput) (models.Expense, error) {
-	tx, err := s.db.Begin()
+	before, expense, err := update(tx, groupID, expenseID, in)
 	if err != nil {
 		return models.Expense{}, err
 	}
-	defer tx.Rollback()
+	if err := recordRevision(tx, expenseID, editedBy, nil); err != nil {
+		return models.Expense{}, err
+	}
+	if err := recordActivity(tx, editedBy, models.VerbUpdated, &before, &expense); err != nil {
+		return models.Expense{}, err
+	}
+	return expense, tx.Commit()
+}
 
-	var expense models.Expense
-	err = tx.QueryRow(
-		`UPDATE expenses SET description = $1, amount = $2 WHERE id = $3 RETURNING id, group_id, paid_by, description, amount, created_at`,
-		description, amount, expenseID,
-	).Scan(&expense.ID, &expense.GroupID, &expense.PaidBy, &expense.Description, &expense.Amount, &expense.CreatedAt)
+// update does the work of UpdateExpense within tx and returns the expense as
+// it was before and after. An expense without revisions gets its current
+// state recorded as the first one beforehand.
+func update(tx *sql.Tx, groupID, expenseID uuid.UUID, in ExpenseInput) (models.Expense, models.Expense, error) {
+	if _, err := location(in.TimeZone); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	splits, receipt, err := expenseSplits(in)
 	if err != nil {
-		return models.Expense{}, err
+		return models.Expense{}, models.Expense{}, err
 	}
 
-	_, err = tx.Exec(`DELETE FROM expense_splits WHERE expense_id = $1`, expenseID)
+	before, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM expenses WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL FOR UPDATE`, expenseID, groupID))
 	if err != nil {
-		return models.Expense{}, err
+		return models.Expense{}, models.Expense{}, err
+	}
+	current, err := time.Parse(models.DateLayout, before.IncurredOn)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	var currentCategory uuid.NullUUID
+	if before.CategoryID != nil {
+		currentCategory = uuid.NullUUID{UUID: *before.CategoryID, Valid: true}
+	}
+	if err := recordFirstRevision(tx, expenseID); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	day := current
+	if !in.IncurredOn.IsZero() {
+		day = in.IncurredOn
+	}
+	if err := checkUnlocked(tx, groupID, current, day); err != nil {
+		return models.Expense{}, models.Expense{}, err
 	}
 
-	for _, split := range splits {
-		_, err = tx.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, split.UserID, split.Amount)
+	payerInputs := in.Payers
+	keepPayers := len(payerInputs) == 0
+	if keepPayers {
+		payerInputs, err = currentPayers(tx, groupID, expenseID, in.Amount)
 		if err != nil {
-			return models.Expense{}, err
+			return models.Expense{}, models.Expense{}, err
 		}
 	}
+	payers, err := computePayers(in.Amount, payerInputs)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
 
-	return expense, tx.Commit()
+	// payers who are kept may have left the group since
+	checked := payers
+	if keepPayers {
+		checked = nil
+	}
+	if err := checkMembers(tx, groupID, checked, splits); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	category, err := expenseCategory(tx, groupID, in, payers, currentCategory)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+
+	expense, err := scanExpense(tx.QueryRow(
+		`UPDATE expenses SET description = $1, amount = $2, currency = COALESCE(NULLIF($3, ''), currency), split_type = $4, paid_by = $5,
+			incurred_on = $6, time_zone = COALESCE(NULLIF($7, ''), time_zone), category_id = $8
+		WHERE id = $9 AND group_id = $10 RETURNING `+expenseColumns,
+		in.Description, in.Amount, in.Amount.Currency, in.SplitType, payers[0].UserID,
+		day.Format(models.DateLayout), in.TimeZone, category, expenseID, groupID,
+	))
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM expense_splits WHERE expense_id = $1`, expenseID)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	_, err = tx.Exec(`DELETE FROM expense_payers WHERE expense_id = $1`, expenseID)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	_, err = tx.Exec(`DELETE FROM expense_receipts WHERE expense_id = $1`, expenseID)
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+
+	if err := insertPayers(tx, expenseID, payers); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	if err := insertSplits(tx, expenseID, splits); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	if err := insertReceipt(tx, expenseID, receipt); err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+	if in.TagIDs != nil {
+		expense.Tags, err = tags.SetExpenseTags(tx, groupID, expenseID, in.TagIDs)
+	} else {
+		expense.Tags, err = expenseTags(tx, expenseID)
+	}
+	if err != nil {
+		return models.Expense{}, models.Expense{}, err
+	}
+
+	expense.Payers = withCurrency(payers, expense.Currency)
+	expense.Receipt = receiptWithCurrency(receipt, expense.Currency)
+	return before, expense, nil
+}
+
+// currentPayers returns the payers an expense keeps when an update doesn't
+// name any. It returns sql.ErrNoRows when the expense is not in the group.
+func currentPayers(tx *sql.Tx, groupID, expenseID uuid.UUID, amount models.Money) ([]PayerInput, error) {
+	rows, err := tx.Query(`SELECT p.user_id, p.amount FROM expense_payers p
+		JOIN expenses e ON e.id = p.expense_id
+		WHERE e.id = $1 AND e.group_id = $2
+		FOR UPDATE OF e`, expenseID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payers []PayerInput
+	var sum models.Money
+	for rows.Next() {
+		var p PayerInput
+		if err := rows.Scan(&p.UserID, &p.Amount); err != nil {
+			return nil, err
+		}
+		payers = append(payers, p)
+		sum = sum.Add(p.Amount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(payers) == 0:
+		return nil, sql.ErrNoRows
+	case sum.Minor == amount.Minor:
+		return payers, nil
+	case len(payers) == 1:
+		return []PayerInput{{UserID: payers[0].UserID, Amount: amount}}, nil
+	default:
+		return nil, fmt.Errorf("%w: payers are required when the amount of an expense with several payers changes", ErrInvalidPayers)
+	}
 }
 
-func (s *Service) DeleteExpense(expenseID uuid.UUID) error {
+// expenseCategory returns the category an expense is stored with: the one
+// given, else the category of the first matching rule, else current. It
+// returns categories.ErrUnknownCategory when the given category isn't
+// available to the group.
+func expenseCategory(tx *sql.Tx, groupID uuid.UUID, in ExpenseInput, payers []models.ExpensePayer, current uuid.NullUUID) (uuid.NullUUID, error) {
+	if in.CategoryID != uuid.Nil {
+		if err := categories.CheckCategory(tx, groupID, in.CategoryID); err != nil {
+			return uuid.NullUUID{}, err
+		}
+		return uuid.NullUUID{UUID: in.CategoryID, Valid: true}, nil
+	}
+
+	rules, err := categories.GroupRules(tx, groupID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	candidate := categories.Candidate{Description: in.Description, Amount: in.Amount}
+	for _, payer := range payers {
+		candidate.Payers = append(candidate.Payers, payer.UserID)
+	}
+	if categoryID, ok := categories.Match(rules, candidate); ok {
+		return uuid.NullUUID{UUID: categoryID, Valid: true}, nil
+	}
+	return current, nil
+}
+
+// DeleteExpense moves the expense to the group's trash, where it no longer
+// counts in lists or balances until it is restored or purged.
+func (s *Service) DeleteExpense(groupID, expenseID, deletedBy uuid.UUID) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`DELETE FROM expense_splits WHERE expense_id = $1`, expenseID)
+	// lock the expense first so a foreign group's expense is never touched
+	var day time.Time
+	err = tx.QueryRow(`SELECT incurred_on FROM expenses WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL FOR UPDATE`, expenseID, groupID).Scan(&day)
 	if err != nil {
 		return err
 	}
+	if err := checkUnlocked(tx, groupID, day); err != nil {
+		return err
+	}
 
-	_, err = tx.Exec(`DELETE FROM expenses WHERE id = $1`, expenseID)
+	expense, err := scanExpense(tx.QueryRow(`UPDATE expenses SET deleted_at = now(), deleted_by = $1 WHERE id = $2 RETURNING `+expenseColumns, deletedBy, expenseID))
 	if err != nil {
 		return err
 	}
+	if err := recordActivity(tx, deletedBy, models.VerbDeleted, &expense, nil); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
+
+// GetDeletedExpenses returns the expenses in the group's trash, most
+// recently deleted first.
+func (s *Service) GetDeletedExpenses(groupID uuid.UUID) ([]models.ExpenseDetail, error) {
+	return s.loadExpenses(`SELECT `+expenseColumns+` FROM expenses e
+		WHERE e.group_id = $1 AND e.deleted_at IS NOT NULL
+		ORDER BY e.deleted_at DESC, e.id`, groupID)
+}
+
+// RestoreExpense takes the expense out of the trash. Unless restoreAny is
+// set, only members who recorded it, paid for it or deleted it can restore
+// it, and anyone else gets ErrCannotRestore. It returns sql.ErrNoRows when the
+// expense is not in the group's trash and ErrPeriodLocked when it falls in
+// the locked period.
+func (s *Service) RestoreExpense(groupID, expenseID, userID uuid.UUID, restoreAny bool) (models.ExpenseDetail, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	defer tx.Rollback()
+
+	var day time.Time
+	var involved bool
+	err = tx.QueryRow(`SELECT incurred_on,
+			created_by = $3 OR deleted_by = $3 OR EXISTS (SELECT 1 FROM expense_payers p WHERE p.expense_id = expenses.id AND p.user_id = $3)
+		FROM expenses WHERE id = $1 AND group_id = $2 AND deleted_at IS NOT NULL FOR UPDATE`,
+		expenseID, groupID, userID).Scan(&day, &involved)
+	if err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	if !restoreAny && !involved {
+		return models.ExpenseDetail{}, ErrCannotRestore
+	}
+	if err := checkUnlocked(tx, groupID, day); err != nil {
+		return models.ExpenseDetail{}, err
+	}
+
+	expense, err := scanExpense(tx.QueryRow(`UPDATE expenses SET deleted_at = NULL, deleted_by = NULL WHERE id = $1 RETURNING `+expenseColumns, expenseID))
+	if err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	if err := recordActivity(tx, userID, models.VerbRestored, nil, &expense); err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.ExpenseDetail{}, err
+	}
+	return s.GetExpense(groupID, expenseID)
+}
+
+// PurgeDeleted permanently deletes the expenses that were moved to the trash
+// before cutoff, in every group, and returns how many there were.
+func (s *Service) PurgeDeleted(cutoff time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// splits predate ON DELETE CASCADE; everything else goes with the expense
+	_, err = tx.Exec(`DELETE FROM expense_splits WHERE expense_id IN (SELECT id FROM expenses WHERE deleted_at < $1)`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`DELETE FROM expenses WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// recordActivity logs verb on an expense in its group's activity. before or
+// after is nil when the expense wasn't there, or is in the trash, then.
+func recordActivity(tx *sql.Tx, actorID uuid.UUID, verb models.ActivityVerb, before, after *models.Expense) error {
+	entry := activity.Entry{ActorID: actorID, Verb: verb, ObjectType: models.ActivityExpense}
+	if before != nil {
+		entry.GroupID, entry.ObjectID, entry.Before = before.GroupID, before.ID, activity.Expense(*before)
+	}
+	if after != nil {
+		entry.GroupID, entry.ObjectID, entry.After = after.GroupID, after.ID, activity.Expense(*after)
+	}
+	return activity.Record(tx, entry)
+}
+
+func insertPayers(tx *sql.Tx, expenseID uuid.UUID, payers []models.ExpensePayer) error {
+	for _, payer := range payers {
+		_, err := tx.Exec(`INSERT INTO expense_payers (expense_id, user_id, amount) VALUES ($1, $2, $3)`,
+			expenseID, payer.UserID, payer.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachPayers loads the payers of expenses, indexed by their IDs, with a
+// single query.
+func (s *Service) attachPayers(expenses []models.ExpenseDetail, index map[uuid.UUID]int, ids database.UUIDs) error {
+	rows, err := s.db.Query(`SELECT p.expense_id, p.user_id, u.name, p.amount
+		FROM expense_payers p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.expense_id = ANY($1::uuid[])`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var expenseID uuid.UUID
+		var payer models.ExpensePayer
+		if err := rows.Scan(&expenseID, &payer.UserID, &payer.Name, &payer.Amount); err != nil {
+			return err
+		}
+		i, ok := index[expenseID]
+		if !ok {
+			continue
+		}
+		payer.Amount.Currency = expenses[i].Currency
+		expenses[i].Payers = append(expenses[i].Payers, payer)
+	}
+	for i := range expenses {
+		sortPayers(expenses[i].Payers)
+	}
+	return rows.Err()
+}
+
+// attachSplits loads the splits of expenses like attachPayers, ordered by
+// user ID.
+func (s *Service) attachSplits(expenses []models.ExpenseDetail, index map[uuid.UUID]int, ids database.UUIDs) error {
+	rows, err := s.db.Query(`SELECT s.id, s.expense_id, s.user_id, u.name, s.amount, s.share
+		FROM expense_splits s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.expense_id = ANY($1::uuid[])
+		ORDER BY s.user_id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var split models.ExpenseSplit
+		if err := rows.Scan(&split.ID, &split.ExpenseID, &split.UserID, &split.Name, &split.Amount, &split.Share); err != nil {
+			return err
+		}
+		i, ok := index[split.ExpenseID]
+		if !ok {
+			continue
+		}
+		split.Amount.Currency = expenses[i].Currency
+		expenses[i].Splits = append(expenses[i].Splits, split)
+	}
+	return rows.Err()
+}
+
+// attachTags loads the tags of expenses, in the same order as ids, with a
+// single query.
+func (s *Service) attachTags(expenses []models.ExpenseDetail, ids database.UUIDs) error {
+	byExpense, err := tags.ExpenseTags(s.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range expenses {
+		expenses[i].Tags = byExpense[expenses[i].ID]
+		if expenses[i].Tags == nil {
+			expenses[i].Tags = []models.Tag{}
+		}
+	}
+	return nil
+}
+
+// expenseTags returns the current tags of an expense being updated.
+func expenseTags(tx *sql.Tx, expenseID uuid.UUID) ([]models.Tag, error) {
+	byExpense, err := tags.ExpenseTags(tx, database.UUIDs{expenseID})
+	if err != nil || byExpense[expenseID] == nil {
+		return []models.Tag{}, err
+	}
+	return byExpense[expenseID], nil
+}
+
+// withCurrency sets the expense's currency on payers computed before the
+// currency was known.
+func withCurrency(payers []models.ExpensePayer, currency string) []models.ExpensePayer {
+	for i := range payers {
+		payers[i].Amount.Currency = currency
+	}
+	return payers
+}
+
+func insertSplits(tx *sql.Tx, expenseID uuid.UUID, splits []models.ExpenseSplit) error {
+	for _, split := range splits {
+		_, err := tx.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount, share) VALUES ($1, $2, $3, $4)`,
+			expenseID, split.UserID, split.Amount, split.Share)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanExpense(row interface{ Scan(...any) error }) (models.Expense, error) {
+	var expense models.Expense
+	var day time.Time
+	var timeZone sql.NullString
+	var categoryID, deletedBy uuid.NullUUID
+	var deletedAt sql.NullTime
+	err := row.Scan(&expense.ID, &expense.GroupID, &expense.PaidBy, &expense.Description, &expense.Amount, &expense.Currency, &expense.SplitType,
+		&day, &timeZone, &categoryID, &expense.CreatedBy, &expense.CreatedAt, &deletedAt, &deletedBy)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if categoryID.Valid {
+		expense.CategoryID = &categoryID.UUID
+	}
+	if deletedAt.Valid {
+		expense.DeletedAt = &deletedAt.Time
+	}
+	if deletedBy.Valid {
+		expense.DeletedBy = &deletedBy.UUID
+	}
+	expense.Amount.Currency = expense.Currency
+	expense.IncurredOn = day.Format(models.DateLayout)
+	expense.TimeZone = timeZone.String
+	return expense, nil
+}
@@ -3,12 +3,19 @@ package expenses_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/categories"
 	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
@@ -51,12 +58,20 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
+		return fmt.Errorf("failed to list migrations: %w", err)
 	}
-	_, err = db.Exec(string(migration))
-	return err
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
 }
 
 func TestCreateExpense(t *testing.T) {
@@ -68,7 +83,8 @@ func TestCreateExpense(t *testing.T) {
 	}
 
 	var groupID string
-	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
 		"Trip to Rome", userID).Scan(&groupID)
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
@@ -86,11 +102,16 @@ func TestCreateExpense(t *testing.T) {
 
 	// splits
 	splits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 90.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
 	}
 
 	service := expenses.NewService(testDB)
-	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, "Dinner", 90.00, splits)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
 	if err != nil {
 		t.Fatalf("failed to create expense: %s", err)
 	}
@@ -102,8 +123,8 @@ func TestCreateExpense(t *testing.T) {
 	if expense.Description != "Dinner" {
 		t.Errorf("expected description 'Dinner', got %s", expense.Description)
 	}
-	if expense.Amount != 90.00 {
-		t.Errorf("expected amount 90, got %f", expense.Amount)
+	if expense.Amount.Minor != 9000 {
+		t.Errorf("expected amount 90, got %s", expense.Amount)
 	}
 	if expense.GroupID != parsedGroupID {
 		t.Errorf("expected groupID %s, got %s", parsedGroupID, expense.GroupID)
@@ -111,6 +132,9 @@ func TestCreateExpense(t *testing.T) {
 	if expense.PaidBy != parsedUserID {
 		t.Errorf("expected paidBy %s, got %s", parsedUserID, expense.PaidBy)
 	}
+	if expense.Currency != "EUR" {
+		t.Errorf("expected the group's currency EUR, got %s", expense.Currency)
+	}
 }
 
 func TestGetExpenses(t *testing.T) {
@@ -122,7 +146,8 @@ func TestGetExpenses(t *testing.T) {
 	}
 
 	var groupID string
-	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
 		"Trip to Rome", userID).Scan(&groupID)
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
@@ -140,26 +165,31 @@ func TestGetExpenses(t *testing.T) {
 
 	service := expenses.NewService(testDB)
 	splits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 90.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
 	}
-	_, err = service.CreateExpense(parsedGroupID, parsedUserID, "Dinner", 90.00, splits)
+	_, err = service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
 	if err != nil {
 		t.Fatalf("failed to create expense: %s", err)
 	}
 
-	result, err := service.GetExpenses(parsedGroupID)
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{})
 	if err != nil {
 		t.Fatalf("failed to get expenses: %s", err)
 	}
 
-	if len(result) == 0 {
+	if len(page.Expenses) == 0 {
 		t.Fatalf("expected at least 1 expense, got 0")
 	}
-	if result[0].Description != "Dinner" {
-		t.Errorf("expected description 'Dinner', got %s", result[0].Description)
+	if page.Expenses[0].Description != "Dinner" {
+		t.Errorf("expected description 'Dinner', got %s", page.Expenses[0].Description)
 	}
-	if result[0].Amount != 90.00 {
-		t.Errorf("expected amount 90, got %f", result[0].Amount)
+	if page.Expenses[0].Amount.Minor != 9000 {
+		t.Errorf("expected amount 90, got %s", page.Expenses[0].Amount)
 	}
 }
 
@@ -172,7 +202,8 @@ func TestGetExpense(t *testing.T) {
 	}
 
 	var groupID string
-	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
 		"Trip to Rome", userID).Scan(&groupID)
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
@@ -183,14 +214,19 @@ func TestGetExpense(t *testing.T) {
 
 	service := expenses.NewService(testDB)
 	splits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 90.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
 	}
-	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, "Dinner", 90.00, splits)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
 	if err != nil {
 		t.Fatalf("failed to create expense: %s", err)
 	}
 
-	result, err := service.GetExpense(expense.ID)
+	result, err := service.GetExpense(parsedGroupID, expense.ID)
 	if err != nil {
 		t.Fatalf("failed to get expense: %s", err)
 	}
@@ -212,7 +248,8 @@ func TestUpdateExpense(t *testing.T) {
 	}
 
 	var groupID string
-	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
 		"Trip to Rome", userID).Scan(&groupID)
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
@@ -223,17 +260,27 @@ func TestUpdateExpense(t *testing.T) {
 
 	service := expenses.NewService(testDB)
 	splits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 90.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
 	}
-	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, "Dinner", 90.00, splits)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
 	if err != nil {
 		t.Fatalf("failed to create expense: %s", err)
 	}
 
 	updatedSplits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 50.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(5000, "")},
 	}
-	updated, err := service.UpdateExpense(expense.ID, "Lunch", 50.00, updatedSplits)
+	updated, err := service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Lunch",
+		Amount:      models.NewMoney(5000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      updatedSplits,
+	})
 	if err != nil {
 		t.Fatalf("failed to update expense: %s", err)
 	}
@@ -241,8 +288,8 @@ func TestUpdateExpense(t *testing.T) {
 	if updated.Description != "Lunch" {
 		t.Errorf("expected description 'Lunch', got %s", updated.Description)
 	}
-	if updated.Amount != 50.00 {
-		t.Errorf("expected amount 50, got %f", updated.Amount)
+	if updated.Amount.Minor != 5000 {
+		t.Errorf("expected amount 50, got %s", updated.Amount)
 	}
 	if updated.PaidBy != parsedUserID {
 		t.Errorf("expected paidBy to be unchanged, got %s", updated.PaidBy)
@@ -258,7 +305,8 @@ func TestDeleteExpense(t *testing.T) {
 	}
 
 	var groupID string
-	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
 		"Trip to Rome", userID).Scan(&groupID)
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
@@ -276,23 +324,1107 @@ func TestDeleteExpense(t *testing.T) {
 
 	service := expenses.NewService(testDB)
 	splits := []expenses.SplitInput{
-		{UserID: parsedUserID, Amount: 90.00},
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
 	}
-	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, "Dinner", 90.00, splits)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
 	if err != nil {
 		t.Fatalf("failed to create expense: %s", err)
 	}
-	err = service.DeleteExpense(expense.ID)
+	err = service.DeleteExpense(parsedGroupID, expense.ID, parsedUserID)
 	if err != nil {
 		t.Fatalf("failed to delete expense: %s", err)
 	}
 
 	// assert expense no longer exists
-	result, err := service.GetExpenses(parsedGroupID)
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{})
+	if err != nil {
+		t.Fatalf("failed to get expenses: %s", err)
+	}
+	if len(page.Expenses) != 0 {
+		t.Errorf("expected 0 expenses after delete, got %d", len(page.Expenses))
+	}
+}
+
+func TestGetExpense_OtherGroup(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 6", "user6@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID, otherGroupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip to Rome", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip to Paris", userID).Scan(&otherGroupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+	parsedOtherGroupID, _ := uuid.Parse(otherGroupID)
+
+	service := expenses.NewService(testDB)
+	splits := []expenses.SplitInput{
+		{UserID: parsedUserID, Amount: models.NewMoney(9000, "")},
+	}
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      splits,
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	_, err = service.GetExpense(parsedOtherGroupID, expense.ID)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for expense of another group, got %v", err)
+	}
+
+	err = service.DeleteExpense(parsedOtherGroupID, expense.ID, parsedUserID)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting expense of another group, got %v", err)
+	}
+}
+
+func TestUpdateExpense_SplitType(t *testing.T) {
+	var userID, friendID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 7", "user7@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 8", "user8@test.com", "hashedpassword").Scan(&friendID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip to Rome", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedFriendID, _ := uuid.Parse(friendID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	_, err = testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, friendID)
+	if err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedFriendID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if expense.SplitType != models.SplitEqual {
+		t.Errorf("expected split type equal, got %s", expense.SplitType)
+	}
+
+	updated, err := service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitPercentage,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID, Share: 7000}, {UserID: parsedFriendID, Share: 3000}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if updated.SplitType != models.SplitPercentage {
+		t.Errorf("expected split type percentage, got %s", updated.SplitType)
+	}
+
+	// the percentages are kept next to the computed amounts
+	var amount models.Money
+	var share models.Weight
+	err = testDB.QueryRow(`SELECT amount, share FROM expense_splits WHERE expense_id = $1 AND user_id = $2`, expense.ID, parsedFriendID).
+		Scan(&amount, &share)
+	if err != nil {
+		t.Fatalf("failed to read split: %s", err)
+	}
+	if amount.Minor != 3000 {
+		t.Errorf("expected split amount 30, got %s", amount)
+	}
+	if share != 3000 {
+		t.Errorf("expected share 30, got %s", share)
+	}
+
+	_, err = service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitPercentage,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID, Share: 7000}},
+	})
+	if !errors.Is(err, expenses.ErrInvalidSplit) {
+		t.Errorf("expected ErrInvalidSplit, got %v", err)
+	}
+}
+
+func TestCreateExpense_MultiplePayers(t *testing.T) {
+	var userID, friendID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 9", "user9@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 10", "user10@test.com", "hashedpassword").Scan(&friendID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip to Rome", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedFriendID, _ := uuid.Parse(friendID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	_, err = testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, friendID)
+	if err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	splits := []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedFriendID}}
+	payers := []expenses.PayerInput{
+		{UserID: parsedUserID, Amount: models.NewMoney(3000, "")},
+		{UserID: parsedFriendID, Amount: models.NewMoney(7000, "")},
+	}
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Hotel",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      splits,
+		Payers:      payers,
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if expense.PaidBy != parsedFriendID {
+		t.Errorf("expected paid_by to be the largest payer %s, got %s", parsedFriendID, expense.PaidBy)
+	}
+	if expense.CreatedBy != parsedUserID {
+		t.Errorf("expected created_by %s, got %s", parsedUserID, expense.CreatedBy)
+	}
+
+	fetched, err := service.GetExpense(parsedGroupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get expense: %s", err)
+	}
+	if len(fetched.Payers) != 2 {
+		t.Fatalf("expected 2 payers, got %d", len(fetched.Payers))
+	}
+	if fetched.Payers[0].UserID != parsedFriendID || fetched.Payers[0].Amount.Minor != 7000 {
+		t.Errorf("expected friend to have paid 70.00, got %+v", fetched.Payers[0])
+	}
+	if fetched.Payers[1].UserID != parsedUserID || fetched.Payers[1].Amount.Minor != 3000 {
+		t.Errorf("expected user to have paid 30.00, got %+v", fetched.Payers[1])
+	}
+	if fetched.Payers[0].Amount.Currency != "EUR" {
+		t.Errorf("expected payer amounts in EUR, got %q", fetched.Payers[0].Amount.Currency)
+	}
+
+	_, err = service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Hotel",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      splits,
+		Payers:      []expenses.PayerInput{{UserID: parsedUserID, Amount: models.NewMoney(3000, "")}},
+	})
+	if !errors.Is(err, expenses.ErrInvalidPayers) {
+		t.Errorf("expected ErrInvalidPayers, got %v", err)
+	}
+
+	// the payers are kept while the amount doesn't change
+	updated, err := service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Hotel and breakfast",
+		Amount:      models.NewMoney(10000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      splits,
+	})
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if len(updated.Payers) != 2 {
+		t.Errorf("expected the 2 payers to be kept, got %d", len(updated.Payers))
+	}
+
+	_, err = service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Hotel",
+		Amount:      models.NewMoney(12000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      splits,
+	})
+	if !errors.Is(err, expenses.ErrInvalidPayers) {
+		t.Errorf("expected ErrInvalidPayers when the amount changes, got %v", err)
+	}
+}
+
+func TestCreateExpense_NonMembers(t *testing.T) {
+	var userID, outsiderID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 11", "user11@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 12", "user12@test.com", "hashedpassword").Scan(&outsiderID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip to Rome", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedOutsiderID, _ := uuid.Parse(outsiderID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	service := expenses.NewService(testDB)
+	splits := []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedOutsiderID}}
+	payers := []expenses.PayerInput{{UserID: parsedOutsiderID, Amount: models.NewMoney(5000, "")}}
+	_, err = service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(5000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      splits,
+		Payers:      payers,
+	})
+
+	var invalid *expenses.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if len(invalid.Fields["payers"]) != 1 || invalid.Fields["payers"][0] != parsedOutsiderID {
+		t.Errorf("expected the outsider among the payers, got %v", invalid.Fields["payers"])
+	}
+	if len(invalid.Fields["splits"]) != 1 || invalid.Fields["splits"][0] != parsedOutsiderID {
+		t.Errorf("expected the outsider among the splits, got %v", invalid.Fields["splits"])
+	}
+
+	var count int
+	err = testDB.QueryRow(`SELECT COUNT(*) FROM expenses WHERE group_id = $1`, groupID).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to count expenses: %s", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no expense to be stored, got %d", count)
+	}
+}
+
+func TestCreateExpense_Itemized(t *testing.T) {
+	var userID, friendID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 13", "user13@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 14", "user14@test.com", "hashedpassword").Scan(&friendID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Dinner", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedFriendID, _ := uuid.Parse(friendID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	_, err = testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, friendID)
+	if err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(5500, ""),
+		SplitType:   models.SplitItemized,
+		Receipt: &expenses.ReceiptInput{
+			Items: []expenses.ItemInput{
+				{Description: "Pizza", Price: models.NewMoney(2000, ""), UserIDs: []uuid.UUID{parsedUserID, parsedFriendID}},
+				{Description: "Steak", Price: models.NewMoney(3000, ""), UserIDs: []uuid.UUID{parsedFriendID}},
+			},
+			Tip: models.NewMoney(500, ""),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	// subtotals 10.00 and 40.00 share the 5.00 tip 1.00/4.00
+	want := map[uuid.UUID]int64{parsedUserID: 1100, parsedFriendID: 4400}
+	for userID, amount := range want {
+		var got models.Money
+		err = testDB.QueryRow(`SELECT amount FROM expense_splits WHERE expense_id = $1 AND user_id = $2`, expense.ID, userID).Scan(&got)
+		if err != nil {
+			t.Fatalf("failed to get split: %s", err)
+		}
+		if got.Minor != amount {
+			t.Errorf("expected split of %d for %s, got %d", amount, userID, got.Minor)
+		}
+	}
+
+	fetched, err := service.GetExpense(parsedGroupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get expense: %s", err)
+	}
+	if fetched.Receipt == nil {
+		t.Fatal("expected the receipt to be returned")
+	}
+	if len(fetched.Receipt.Items) != 2 || fetched.Receipt.Items[1].Description != "Steak" {
+		t.Fatalf("expected the 2 items in order, got %+v", fetched.Receipt.Items)
+	}
+	if len(fetched.Receipt.Items[0].Shares) != 2 || fetched.Receipt.Items[0].Shares[0].Amount.Minor != 1000 {
+		t.Errorf("expected the pizza to be shared 10.00/10.00, got %+v", fetched.Receipt.Items[0].Shares)
+	}
+	if fetched.Receipt.Tip.Minor != 500 || fetched.Receipt.Tip.Currency != "EUR" {
+		t.Errorf("expected a tip of 5.00 EUR, got %s", fetched.Receipt.Tip)
+	}
+
+	// switching to an equal split drops the receipt
+	_, err = service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, expenses.ExpenseInput{
+		Description: "Dinner",
+		Amount:      models.NewMoney(5500, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedFriendID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	fetched, err = service.GetExpense(parsedGroupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get expense: %s", err)
+	}
+	if fetched.Receipt != nil {
+		t.Errorf("expected no receipt after switching to an equal split, got %+v", fetched.Receipt)
+	}
+}
+
+func TestGetExpenses_Details(t *testing.T) {
+	var userID, friendID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 15", "user15@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 16", "user16@test.com", "hashedpassword").Scan(&friendID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedFriendID, _ := uuid.Parse(friendID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	_, err = testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, friendID)
+	if err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	_, err = service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Rent",
+		Amount:      models.NewMoney(90000, ""),
+		SplitType:   models.SplitShares,
+		Splits: []expenses.SplitInput{
+			{UserID: parsedUserID, Share: 200},
+			{UserID: parsedFriendID, Share: 100},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	_, err = service.CreateExpense(parsedGroupID, parsedFriendID, expenses.ExpenseInput{
+		Description: "Internet",
+		Amount:      models.NewMoney(3000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedFriendID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{})
+	if err != nil {
+		t.Fatalf("failed to get expenses: %s", err)
+	}
+	if len(page.Expenses) != 2 {
+		t.Fatalf("expected 2 expenses, got %d", len(page.Expenses))
+	}
+
+	names := map[uuid.UUID]string{parsedUserID: "User 15", parsedFriendID: "User 16"}
+	for _, expense := range page.Expenses {
+		if len(expense.Splits) != 2 {
+			t.Fatalf("expected 2 splits on %q, got %d", expense.Description, len(expense.Splits))
+		}
+		var sum int64
+		for _, split := range expense.Splits {
+			if split.Name != names[split.UserID] {
+				t.Errorf("expected name %q for %s, got %q", names[split.UserID], split.UserID, split.Name)
+			}
+			if split.ExpenseID != expense.ID {
+				t.Errorf("expected split of %s, got %s", expense.ID, split.ExpenseID)
+			}
+			if split.Amount.Currency != "EUR" {
+				t.Errorf("expected split in EUR, got %q", split.Amount.Currency)
+			}
+			sum += split.Amount.Minor
+		}
+		if sum != expense.Amount.Minor {
+			t.Errorf("expected splits of %q to add up to %d, got %d", expense.Description, expense.Amount.Minor, sum)
+		}
+		if len(expense.Payers) != 1 || expense.Payers[0].Name != names[expense.Payers[0].UserID] {
+			t.Errorf("expected the payer's name on %q, got %+v", expense.Description, expense.Payers)
+		}
+
+		if expense.Description == "Rent" {
+			for _, split := range expense.Splits {
+				if split.UserID == parsedUserID && (split.Amount.Minor != 60000 || split.Share == nil || *split.Share != 200) {
+					t.Errorf("expected 2 shares paying 600.00, got %+v", split)
+				}
+			}
+		}
+	}
+}
+
+func TestGetExpenses_Filters(t *testing.T) {
+	var userID, friendID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 17", "user17@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 18", "user18@test.com", "hashedpassword").Scan(&friendID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Household", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedFriendID, _ := uuid.Parse(friendID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	_, err = testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, friendID)
+	if err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	both := []expenses.SplitInput{{UserID: parsedUserID}, {UserID: parsedFriendID}}
+	created := []struct {
+		description string
+		amount      int64
+		payer       uuid.UUID
+		splits      []expenses.SplitInput
+	}{
+		{"Groceries", 4000, parsedUserID, both},
+		{"Electricity bill", 9000, parsedFriendID, both},
+		{"Groceries again", 2500, parsedFriendID, both},
+		{"Gym", 3000, parsedUserID, []expenses.SplitInput{{UserID: parsedUserID}}},
+		{"Water bill", 1500, parsedUserID, both},
+	}
+	for _, c := range created {
+		_, err := service.CreateExpense(parsedGroupID, c.payer, expenses.ExpenseInput{
+			Description: c.description,
+			Amount:      models.NewMoney(c.amount, ""),
+			SplitType:   models.SplitEqual,
+			Splits:      c.splits,
+		})
+		if err != nil {
+			t.Fatalf("failed to create expense: %s", err)
+		}
+	}
+
+	descriptions := func(filter expenses.ExpenseFilter) ([]string, string) {
+		t.Helper()
+		page, err := service.GetExpenses(parsedGroupID, filter)
+		if err != nil {
+			t.Fatalf("failed to get expenses: %s", err)
+		}
+		var got []string
+		for _, e := range page.Expenses {
+			got = append(got, e.Description)
+		}
+		return got, page.NextCursor
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Errorf("%s: expected [%s], got [%s]", name, strings.Join(want, ", "), strings.Join(got, ", "))
+		}
+	}
+
+	got, _ := descriptions(expenses.ExpenseFilter{})
+	expect("newest first", got, "Water bill", "Gym", "Groceries again", "Electricity bill", "Groceries")
+
+	got, _ = descriptions(expenses.ExpenseFilter{Search: "groceries"})
+	expect("search", got, "Groceries again", "Groceries")
+
+	got, _ = descriptions(expenses.ExpenseFilter{PaidBy: parsedFriendID, Sort: expenses.SortOldest})
+	expect("paid by", got, "Electricity bill", "Groceries again")
+
+	got, _ = descriptions(expenses.ExpenseFilter{Participant: parsedFriendID, MinAmount: models.NewMoney(2500, ""), MaxAmount: models.NewMoney(4000, "")})
+	expect("participant and amount range", got, "Groceries again", "Groceries")
+
+	got, _ = descriptions(expenses.ExpenseFilter{From: time.Now().AddDate(0, 0, 2)})
+	expect("from", got)
+
+	// page through by amount, two at a time
+	var all []string
+	filter := expenses.ExpenseFilter{Sort: expenses.SortLargest, Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("expected 3 pages at most")
+		}
+		got, next := descriptions(filter)
+		all = append(all, got...)
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	expect("pages by amount", all, "Electricity bill", "Groceries", "Gym", "Groceries again", "Water bill")
+
+	_, err = service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{Sort: expenses.SortOldest, Cursor: filter.Cursor})
+	if !errors.Is(err, expenses.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for a cursor of another sort, got %v", err)
+	}
+}
+
+func TestExpense_IncurredOn(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 19", "user19@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Books", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	service := expenses.NewService(testDB)
+	input := func(description, day string) expenses.ExpenseInput {
+		in := expenses.ExpenseInput{
+			Description: description,
+			Amount:      models.NewMoney(1000, ""),
+			SplitType:   models.SplitEqual,
+			Splits:      []expenses.SplitInput{{UserID: parsedUserID}},
+			TimeZone:    "Europe/Lisbon",
+		}
+		if day != "" {
+			in.IncurredOn, _ = time.Parse(models.DateLayout, day)
+		}
+		return in
+	}
+
+	january, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Hosting", "2024-01-20"))
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if january.IncurredOn != "2024-01-20" || january.TimeZone != "Europe/Lisbon" {
+		t.Errorf("expected incurred on 2024-01-20 in Europe/Lisbon, got %s in %q", january.IncurredOn, january.TimeZone)
+	}
+
+	today, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Domain", ""))
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	if want := time.Now().In(lisbon).Format(models.DateLayout); today.IncurredOn != want {
+		t.Errorf("expected the expense to default to %s, got %s", want, today.IncurredOn)
+	}
+
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{Sort: expenses.SortOldest})
+	if err != nil {
+		t.Fatalf("failed to get expenses: %s", err)
+	}
+	if len(page.Expenses) != 2 || page.Expenses[0].ID != january.ID {
+		t.Errorf("expected the backdated expense first, got %+v", page.Expenses)
+	}
+
+	_, err = service.CreateExpense(parsedGroupID, parsedUserID, expenses.ExpenseInput{
+		Description: "Mail",
+		Amount:      models.NewMoney(1000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID}},
+		TimeZone:    "Mars/Olympus_Mons",
+	})
+	if !errors.Is(err, expenses.ErrInvalidTimeZone) {
+		t.Errorf("expected ErrInvalidTimeZone, got %v", err)
+	}
+
+	// lock January
+	_, err = testDB.Exec(`UPDATE groups SET locked_until = '2024-01-31' WHERE id = $1`, groupID)
+	if err != nil {
+		t.Fatalf("failed to lock group: %s", err)
+	}
+
+	if _, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Late invoice", "2024-01-31")); !errors.Is(err, expenses.ErrPeriodLocked) {
+		t.Errorf("expected ErrPeriodLocked when adding to the locked period, got %v", err)
+	}
+	if _, err := service.UpdateExpense(parsedGroupID, january.ID, parsedUserID, input("Hosting", "2024-02-01")); !errors.Is(err, expenses.ErrPeriodLocked) {
+		t.Errorf("expected ErrPeriodLocked when moving out of the locked period, got %v", err)
+	}
+	if _, err := service.UpdateExpense(parsedGroupID, today.ID, parsedUserID, input("Domain", "2024-01-15")); !errors.Is(err, expenses.ErrPeriodLocked) {
+		t.Errorf("expected ErrPeriodLocked when moving into the locked period, got %v", err)
+	}
+	if err := service.DeleteExpense(parsedGroupID, january.ID, parsedUserID); !errors.Is(err, expenses.ErrPeriodLocked) {
+		t.Errorf("expected ErrPeriodLocked when deleting from the locked period, got %v", err)
+	}
+
+	updated, err := service.UpdateExpense(parsedGroupID, today.ID, parsedUserID, input("Domain renewal", "2024-02-01"))
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if updated.IncurredOn != "2024-02-01" {
+		t.Errorf("expected incurred on 2024-02-01, got %s", updated.IncurredOn)
+	}
+}
+
+func TestExpense_Category(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 20", "user20@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Household", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	var groceries, utilities uuid.UUID
+	err = testDB.QueryRow(`SELECT (SELECT id FROM categories WHERE group_id IS NULL AND name = 'Groceries'),
+		(SELECT id FROM categories WHERE group_id IS NULL AND name = 'Utilities')`).Scan(&groceries, &utilities)
+	if err != nil {
+		t.Fatalf("failed to find system categories: %s", err)
+	}
+	_, err = testDB.Exec(`INSERT INTO category_rules (group_id, category_id, position, pattern) VALUES ($1, $2, 0, 'market')`,
+		parsedGroupID, groceries)
+	if err != nil {
+		t.Fatalf("failed to insert rule: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	input := expenses.ExpenseInput{
+		Description: "Supermarket",
+		Amount:      models.NewMoney(3000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: parsedUserID}},
+	}
+
+	expense, err := service.CreateExpense(parsedGroupID, parsedUserID, input)
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if expense.CategoryID == nil || *expense.CategoryID != groceries {
+		t.Errorf("expected category %s from the rule, got %v", groceries, expense.CategoryID)
+	}
+
+	// a category given explicitly wins over the rules
+	input.CategoryID = utilities
+	expense, err = service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, input)
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if expense.CategoryID == nil || *expense.CategoryID != utilities {
+		t.Errorf("expected category %s, got %v", utilities, expense.CategoryID)
+	}
+
+	// without a category or a matching rule the current category is kept
+	input.CategoryID = uuid.Nil
+	input.Description = "Electricity"
+	expense, err = service.UpdateExpense(parsedGroupID, expense.ID, parsedUserID, input)
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if expense.CategoryID == nil || *expense.CategoryID != utilities {
+		t.Errorf("expected category %s to be kept, got %v", utilities, expense.CategoryID)
+	}
+
+	input.CategoryID = uuid.New()
+	if _, err := service.CreateExpense(parsedGroupID, parsedUserID, input); !errors.Is(err, categories.ErrUnknownCategory) {
+		t.Errorf("expected ErrUnknownCategory, got %v", err)
+	}
+
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{CategoryID: utilities})
 	if err != nil {
 		t.Fatalf("failed to get expenses: %s", err)
 	}
-	if len(result) != 0 {
-		t.Errorf("expected 0 expenses after delete, got %d", len(result))
+	if len(page.Expenses) != 1 || page.Expenses[0].ID != expense.ID {
+		t.Errorf("expected only the electricity expense, got %d expenses", len(page.Expenses))
+	}
+}
+
+func TestExpense_Tags(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 21", "user21@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Summer", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	parsedUserID, _ := uuid.Parse(userID)
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	tagService := tags.NewService(testDB)
+	trip, err := tagService.CreateTag(parsedGroupID, "lisbon-trip")
+	if err != nil {
+		t.Fatalf("failed to create tag: %s", err)
+	}
+	party, err := tagService.CreateTag(parsedGroupID, "bday-party")
+	if err != nil {
+		t.Fatalf("failed to create tag: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	input := func(description string, tagIDs ...uuid.UUID) expenses.ExpenseInput {
+		return expenses.ExpenseInput{
+			Description: description,
+			Amount:      models.NewMoney(2000, ""),
+			SplitType:   models.SplitEqual,
+			Splits:      []expenses.SplitInput{{UserID: parsedUserID}},
+			TagIDs:      tagIDs,
+		}
+	}
+
+	both, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Cake in Lisbon", trip.ID, party.ID))
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if len(both.Tags) != 2 || both.Tags[0].ID != party.ID {
+		t.Errorf("expected bday-party and lisbon-trip, got %+v", both.Tags)
+	}
+	tram, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Tram", trip.ID))
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	if _, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Balloons")); err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	page, err := service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{TagIDs: []uuid.UUID{trip.ID}})
+	if err != nil {
+		t.Fatalf("failed to get expenses: %s", err)
+	}
+	if len(page.Expenses) != 2 {
+		t.Errorf("expected 2 lisbon-trip expenses, got %d", len(page.Expenses))
+	}
+	page, err = service.GetExpenses(parsedGroupID, expenses.ExpenseFilter{TagIDs: []uuid.UUID{trip.ID, party.ID}})
+	if err != nil {
+		t.Fatalf("failed to get expenses: %s", err)
+	}
+	if len(page.Expenses) != 1 || page.Expenses[0].ID != both.ID {
+		t.Errorf("expected only the cake to have both tags, got %d expenses", len(page.Expenses))
+	}
+
+	// leaving the tags out of an update keeps them, an empty list removes them
+	updated, err := service.UpdateExpense(parsedGroupID, tram.ID, parsedUserID, input("Tram 28"))
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if len(updated.Tags) != 1 || updated.Tags[0].ID != trip.ID {
+		t.Errorf("expected lisbon-trip to be kept, got %+v", updated.Tags)
+	}
+	updated, err = service.UpdateExpense(parsedGroupID, tram.ID, parsedUserID, input("Tram 28", []uuid.UUID{}...))
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	if len(updated.Tags) != 0 {
+		t.Errorf("expected no tags, got %+v", updated.Tags)
+	}
+
+	if _, err := service.CreateExpense(parsedGroupID, parsedUserID, input("Taxi", uuid.New())); !errors.Is(err, tags.ErrUnknownTag) {
+		t.Errorf("expected ErrUnknownTag, got %v", err)
+	}
+}
+
+func TestExpense_Trash(t *testing.T) {
+	var ownerID, memberID, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"user22@test.com", &ownerID}, {"user23@test.com", &memberID}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", ownerID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, memberID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	expense, err := service.CreateExpense(groupID, ownerID, expenses.ExpenseInput{
+		Description: "Groceries",
+		Amount:      models.NewMoney(3000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ownerID}, {UserID: memberID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	if err := service.DeleteExpense(groupID, expense.ID, ownerID); err != nil {
+		t.Fatalf("failed to delete expense: %s", err)
+	}
+	if _, err := service.GetExpense(groupID, expense.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a deleted expense, got %v", err)
+	}
+	if err := service.DeleteExpense(groupID, expense.ID, ownerID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+
+	trash, err := service.GetDeletedExpenses(groupID)
+	if err != nil {
+		t.Fatalf("failed to get deleted expenses: %s", err)
+	}
+	if len(trash) != 1 || trash[0].ID != expense.ID || len(trash[0].Splits) != 2 {
+		t.Fatalf("expected the expense with its splits in the trash, got %+v", trash)
+	}
+	if trash[0].DeletedAt == nil || trash[0].DeletedBy == nil || *trash[0].DeletedBy != ownerID {
+		t.Errorf("expected the expense to be deleted by the owner, got %v by %v", trash[0].DeletedAt, trash[0].DeletedBy)
+	}
+
+	// the member only has a split, so only an admin can bring it back
+	if _, err := service.RestoreExpense(groupID, expense.ID, memberID, false); !errors.Is(err, expenses.ErrCannotRestore) {
+		t.Errorf("expected ErrCannotRestore, got %v", err)
+	}
+	restored, err := service.RestoreExpense(groupID, expense.ID, memberID, true)
+	if err != nil {
+		t.Fatalf("failed to restore expense: %s", err)
+	}
+	if restored.DeletedAt != nil || len(restored.Splits) != 2 {
+		t.Errorf("expected the restored expense with its splits, got %+v", restored)
+	}
+	if _, err := service.RestoreExpense(groupID, expense.ID, ownerID, true); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows restoring an expense not in the trash, got %v", err)
+	}
+
+	// only expenses deleted before the cutoff are purged
+	if err := service.DeleteExpense(groupID, expense.ID, memberID); err != nil {
+		t.Fatalf("failed to delete expense: %s", err)
+	}
+	if _, err := service.PurgeDeleted(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to purge: %s", err)
+	}
+	if trash, _ := service.GetDeletedExpenses(groupID); len(trash) != 1 {
+		t.Errorf("expected the expense to stay in the trash, got %d", len(trash))
+	}
+	if _, err := service.PurgeDeleted(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to purge: %s", err)
+	}
+	if trash, _ := service.GetDeletedExpenses(groupID); len(trash) != 0 {
+		t.Errorf("expected the trash to be empty, got %d", len(trash))
+	}
+	var splits int
+	if err := testDB.QueryRow(`SELECT count(*) FROM expense_splits WHERE expense_id = $1`, expense.ID).Scan(&splits); err != nil || splits != 0 {
+		t.Errorf("expected the splits to be purged, got %d (%v)", splits, err)
+	}
+}
+
+func TestExpense_History(t *testing.T) {
+	var ownerID, memberID, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"user24@test.com", &ownerID}, {"user25@test.com", &memberID}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip", ownerID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, memberID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	service := expenses.NewService(testDB)
+	expense, err := service.CreateExpense(groupID, ownerID, expenses.ExpenseInput{
+		Description: "Hotel",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ownerID}, {UserID: memberID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	_, err = service.UpdateExpense(groupID, expense.ID, memberID, expenses.ExpenseInput{
+		Description: "Hotel, 2 nights",
+		Amount:      models.NewMoney(12000, ""),
+		SplitType:   models.SplitExact,
+		Splits:      []expenses.SplitInput{{UserID: ownerID, Amount: models.NewMoney(12000, "")}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+
+	history, err := service.GetHistory(groupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get history: %s", err)
+	}
+	if len(history) != 2 || history[0].Revision != 2 || history[0].EditedBy != memberID || history[1].EditedBy != ownerID {
+		t.Fatalf("expected the member's edit then the owner's creation, got %+v", history)
+	}
+	if len(history[1].Changes) != 0 {
+		t.Errorf("expected no changes in the first revision, got %+v", history[1].Changes)
+	}
+	fields := map[string]int{}
+	for _, c := range history[0].Changes {
+		fields[c.Field]++
+	}
+	if fields["description"] != 1 || fields["amount"] != 1 || fields["split_type"] != 1 || fields["splits"] != 2 {
+		t.Errorf("expected description, amount, split type and both splits to change, got %+v", history[0].Changes)
+	}
+
+	reverted, err := service.RevertExpense(groupID, expense.ID, ownerID, 1)
+	if err != nil {
+		t.Fatalf("failed to revert expense: %s", err)
+	}
+	if reverted.Description != "Hotel" || reverted.Amount.Minor != 9000 || reverted.SplitType != models.SplitEqual {
+		t.Errorf("expected the original expense back, got %+v", reverted)
+	}
+	detail, err := service.GetExpense(groupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get expense: %s", err)
+	}
+	if len(detail.Splits) != 2 || detail.Splits[0].Amount.Minor != 4500 {
+		t.Errorf("expected two splits of 45.00, got %+v", detail.Splits)
+	}
+	history, err = service.GetHistory(groupID, expense.ID)
+	if err != nil {
+		t.Fatalf("failed to get history: %s", err)
+	}
+	if len(history) != 3 || history[0].RevertedFrom == nil || *history[0].RevertedFrom != 1 {
+		t.Fatalf("expected a third revision reverting to the first, got %+v", history)
+	}
+	if _, err := service.RevertExpense(groupID, expense.ID, ownerID, 9); !errors.Is(err, expenses.ErrUnknownRevision) {
+		t.Errorf("expected ErrUnknownRevision, got %v", err)
+	}
+
+	// expenses recorded before revisions were kept get their first one on update
+	var legacyID uuid.UUID
+	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, 'Taxi', '20.00', 'EUR') RETURNING id`,
+		groupID, ownerID).Scan(&legacyID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO expense_payers (expense_id, user_id, amount) VALUES ($1, $2, '20.00')`, legacyID, ownerID); err != nil {
+		t.Fatalf("failed to insert payer: %s", err)
+	}
+	if history, err := service.GetHistory(groupID, legacyID); err != nil || len(history) != 1 || history[0].Snapshot.Description != "Taxi" {
+		t.Errorf("expected the current state as only revision, got %+v (%v)", history, err)
+	}
+	_, err = service.UpdateExpense(groupID, legacyID, memberID, expenses.ExpenseInput{
+		Description: "Taxi to the airport",
+		Amount:      models.NewMoney(2000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ownerID}, {UserID: memberID}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update expense: %s", err)
+	}
+	history, err = service.GetHistory(groupID, legacyID)
+	if err != nil {
+		t.Fatalf("failed to get history: %s", err)
+	}
+	if len(history) != 2 || history[1].EditedBy != ownerID || history[1].Snapshot.Description != "Taxi" {
+		t.Errorf("expected the owner's original as first revision, got %+v", history)
 	}
 }
@@ -0,0 +1,133 @@
+package expenses
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+// ErrInvalidSplit is returned when the splits don't fit the split type or
+// don't add up to the expense amount.
+var ErrInvalidSplit = errors.New("invalid split")
+
+// hundredPercent is 100% in Weight hundredths.
+const hundredPercent = 100 * 100
+
+// computeSplits turns the participants' inputs into the split rows stored for
+// the expense. Amounts always add up to total exactly; leftover cents go to
+// the largest remainders, ties going to the lowest user ID, so the same input
+// always produces the same rows whatever order the participants came in.
+func computeSplits(splitType models.SplitType, total models.Money, inputs []SplitInput) ([]models.ExpenseSplit, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: at least one split is required", ErrInvalidSplit)
+	}
+
+	// work on a copy sorted by user ID so allocation doesn't depend on input order
+	sorted := make([]SplitInput, len(inputs))
+	copy(sorted, inputs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserID.String() < sorted[j].UserID.String() })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].UserID == sorted[i-1].UserID {
+			return nil, fmt.Errorf("%w: user %s appears more than once", ErrInvalidSplit, sorted[i].UserID)
+		}
+	}
+	for _, in := range sorted {
+		if in.Amount.IsNegative() || in.Share < 0 {
+			return nil, fmt.Errorf("%w: amounts and shares must not be negative", ErrInvalidSplit)
+		}
+		if in.Share > models.MaxWeight {
+			return nil, fmt.Errorf("%w: shares must not be more than %s", ErrInvalidSplit, models.MaxWeight)
+		}
+	}
+
+	splits := make([]models.ExpenseSplit, len(sorted))
+	for i, in := range sorted {
+		splits[i] = models.ExpenseSplit{UserID: in.UserID}
+	}
+
+	switch splitType {
+	case models.SplitExact:
+		var sum models.Money
+		for i, in := range sorted {
+			splits[i].Amount = in.Amount
+			sum = sum.Add(in.Amount)
+		}
+		if sum.Minor != total.Minor {
+			return nil, fmt.Errorf("%w: splits must add up to total amount", ErrInvalidSplit)
+		}
+
+	case models.SplitEqual:
+		parts, err := total.Split(len(sorted))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		for i := range splits {
+			splits[i].Amount = parts[i]
+		}
+
+	case models.SplitPercentage, models.SplitShares:
+		weights := make([]int64, len(sorted))
+		var sum int64
+		for i, in := range sorted {
+			weights[i] = int64(in.Share)
+			if weights[i] > math.MaxInt64-sum {
+				return nil, fmt.Errorf("%w: shares add up to too much", ErrInvalidSplit)
+			}
+			sum += weights[i]
+			share := in.Share
+			splits[i].Share = &share
+		}
+		if splitType == models.SplitPercentage && sum != hundredPercent {
+			return nil, fmt.Errorf("%w: percentages must add up to 100", ErrInvalidSplit)
+		}
+		parts, err := total.Allocate(weights)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		for i := range splits {
+			splits[i].Amount = parts[i]
+		}
+
+	case models.SplitAdjustment:
+		// participants with an amount pay exactly that, the rest share what's left
+		remainder := total
+		var rest []int
+		for i, in := range sorted {
+			if in.Amount.IsZero() {
+				rest = append(rest, i)
+				continue
+			}
+			fixed := models.Weight(in.Amount.Minor)
+			splits[i].Amount = in.Amount
+			splits[i].Share = &fixed
+			remainder = remainder.Sub(in.Amount)
+		}
+		if remainder.IsNegative() {
+			return nil, fmt.Errorf("%w: fixed amounts exceed the total amount", ErrInvalidSplit)
+		}
+		if len(rest) == 0 {
+			if !remainder.IsZero() {
+				return nil, fmt.Errorf("%w: nobody is left to pay the remainder", ErrInvalidSplit)
+			}
+			break
+		}
+		parts, err := remainder.Split(len(rest))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		for j, i := range rest {
+			splits[i].Amount = parts[j]
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown split type %q", ErrInvalidSplit, splitType)
+	}
+
+	for i := range splits {
+		splits[i].Amount.Currency = total.Currency
+	}
+	return splits, nil
+}
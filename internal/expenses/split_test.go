@@ -0,0 +1,140 @@
+package expenses
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	userA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	userB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	userC = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	userD = uuid.MustParse("00000000-0000-0000-0000-00000000000d")
+)
+
+func TestComputeSplits(t *testing.T) {
+	tests := []struct {
+		name      string
+		splitType models.SplitType
+		total     int64
+		inputs    []SplitInput
+		want      map[uuid.UUID]int64
+	}{
+		{
+			name:      "exact",
+			splitType: models.SplitExact,
+			total:     1000,
+			inputs:    []SplitInput{{UserID: userA, Amount: models.NewMoney(700, "")}, {UserID: userB, Amount: models.NewMoney(300, "")}},
+			want:      map[uuid.UUID]int64{userA: 700, userB: 300},
+		},
+		{
+			name:      "equal gives the extra cent to the lowest user ID",
+			splitType: models.SplitEqual,
+			total:     1000,
+			inputs:    []SplitInput{{UserID: userC}, {UserID: userB}, {UserID: userA}},
+			want:      map[uuid.UUID]int64{userA: 334, userB: 333, userC: 333},
+		},
+		{
+			name:      "percentage",
+			splitType: models.SplitPercentage,
+			total:     1999,
+			inputs:    []SplitInput{{UserID: userA, Share: 5000}, {UserID: userB, Share: 3000}, {UserID: userC, Share: 2000}},
+			want:      map[uuid.UUID]int64{userA: 999, userB: 600, userC: 400},
+		},
+		{
+			name:      "shares",
+			splitType: models.SplitShares,
+			total:     100,
+			inputs:    []SplitInput{{UserID: userA, Share: 100}, {UserID: userB, Share: 200}, {UserID: userC, Share: 300}},
+			want:      map[uuid.UUID]int64{userA: 17, userB: 33, userC: 50},
+		},
+		{
+			name:      "adjustment",
+			splitType: models.SplitAdjustment,
+			total:     1000,
+			inputs:    []SplitInput{{UserID: userA, Amount: models.NewMoney(400, "")}, {UserID: userB}, {UserID: userC}},
+			want:      map[uuid.UUID]int64{userA: 400, userB: 300, userC: 300},
+		},
+		{
+			name:      "adjustment fully fixed",
+			splitType: models.SplitAdjustment,
+			total:     1000,
+			inputs:    []SplitInput{{UserID: userA, Amount: models.NewMoney(1000, "")}},
+			want:      map[uuid.UUID]int64{userA: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		splits, err := computeSplits(tt.splitType, models.NewMoney(tt.total, "EUR"), tt.inputs)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		if len(splits) != len(tt.want) {
+			t.Errorf("%s: expected %d splits, got %d", tt.name, len(tt.want), len(splits))
+			continue
+		}
+		for _, split := range splits {
+			if split.Amount.Minor != tt.want[split.UserID] {
+				t.Errorf("%s: expected %d for %s, got %d", tt.name, tt.want[split.UserID], split.UserID, split.Amount.Minor)
+			}
+			if split.Amount.Currency != "EUR" {
+				t.Errorf("%s: expected currency EUR, got %s", tt.name, split.Amount.Currency)
+			}
+		}
+	}
+}
+
+func TestComputeSplits_StoredShare(t *testing.T) {
+	splits, err := computeSplits(models.SplitAdjustment, models.NewMoney(1000, ""),
+		[]SplitInput{{UserID: userA, Amount: models.NewMoney(400, "")}, {UserID: userB}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if splits[0].Share == nil || *splits[0].Share != 400 {
+		t.Errorf("expected the fixed amount to be stored as share, got %v", splits[0].Share)
+	}
+	if splits[1].Share != nil {
+		t.Errorf("expected no share for a remainder participant, got %s", splits[1].Share)
+	}
+
+	splits, err = computeSplits(models.SplitEqual, models.NewMoney(1000, ""), []SplitInput{{UserID: userA}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if splits[0].Share != nil {
+		t.Errorf("expected no share for an equal split, got %s", splits[0].Share)
+	}
+}
+
+func TestComputeSplits_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		splitType models.SplitType
+		inputs    []SplitInput
+	}{
+		{"no splits", models.SplitEqual, nil},
+		{"unknown type", "random", []SplitInput{{UserID: userA}}},
+		{"duplicate user", models.SplitEqual, []SplitInput{{UserID: userA}, {UserID: userA}}},
+		{"exact short", models.SplitExact, []SplitInput{{UserID: userA, Amount: models.NewMoney(999, "")}}},
+		{"negative amount", models.SplitExact, []SplitInput{{UserID: userA, Amount: models.NewMoney(1100, "")}, {UserID: userB, Amount: models.NewMoney(-100, "")}}},
+		{"percentages short", models.SplitPercentage, []SplitInput{{UserID: userA, Share: 5000}, {UserID: userB, Share: 4999}}},
+		{"zero shares", models.SplitShares, []SplitInput{{UserID: userA}, {UserID: userB}}},
+		{"share over the column", models.SplitShares, []SplitInput{{UserID: userA, Share: models.MaxWeight + 1}, {UserID: userB, Share: 100}}},
+		{"percentages wrapping to 100", models.SplitPercentage, []SplitInput{
+			{UserID: userA, Share: 1 << 62}, {UserID: userB, Share: 1 << 62}, {UserID: userC, Share: 1 << 62}, {UserID: userD, Share: 1<<62 + hundredPercent},
+		}},
+		{"adjustment over total", models.SplitAdjustment, []SplitInput{{UserID: userA, Amount: models.NewMoney(1001, "")}, {UserID: userB}}},
+		{"adjustment without remainder payer", models.SplitAdjustment, []SplitInput{{UserID: userA, Amount: models.NewMoney(600, "")}}},
+	}
+
+	for _, tt := range tests {
+		_, err := computeSplits(tt.splitType, models.NewMoney(1000, ""), tt.inputs)
+		if !errors.Is(err, ErrInvalidSplit) {
+			t.Errorf("%s: expected ErrInvalidSplit, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package expenses
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/IvanLouren/GoSplit/pkg/database"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ValidationError is returned when an expense refers to users who are not
+// members of its group. Fields maps each request field to the offending IDs.
+type ValidationError struct {
+	Message string                 `json:"error" example:"users are not members of the group"`
+	Fields  map[string][]uuid.UUID `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	msg := e.Message
+	for _, field := range fields {
+		msg += fmt.Sprintf("; %s: %v", field, e.Fields[field])
+	}
+	return msg
+}
+
+// checkMembers returns a *ValidationError naming the payers and split
+// participants who are not members of the group. The member rows are locked
+// until tx ends, so nobody can leave the group while the expense is written.
+func checkMembers(tx *sql.Tx, groupID uuid.UUID, payers []models.ExpensePayer, splits []models.ExpenseSplit) error {
+	var ids []uuid.UUID
+	for _, p := range payers {
+		ids = append(ids, p.UserID)
+	}
+	for _, s := range splits {
+		ids = append(ids, s.UserID)
+	}
+
+	rows, err := tx.Query(`SELECT user_id FROM group_members WHERE group_id = $1 AND user_id = ANY($2::uuid[]) FOR SHARE`,
+		groupID, database.UUIDs(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	members := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		members[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	fields := make(map[string][]uuid.UUID)
+	for _, p := range payers {
+		if !members[p.UserID] {
+			fields["payers"] = append(fields["payers"], p.UserID)
+		}
+	}
+	for _, s := range splits {
+		if !members[s.UserID] {
+			fields["splits"] = append(fields["splits"], s.UserID)
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Message: "users are not members of the group", Fields: fields}
+	}
+	return nil
+}
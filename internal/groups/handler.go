@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/IvanLouren/GoSplit/pkg/middleware"
 	"github.com/IvanLouren/GoSplit/pkg/models"
@@ -20,9 +21,29 @@ func NewHandler(service *Service) *Handler {
 
 type CreateGroupRequest struct {
 	Name string `json:"name"`
+	// Currency is the group's base currency; EUR when creating without one,
+	// unchanged when updating without one.
+	Currency string `json:"currency" example:"EUR"`
+	// DebtMode is only read on update; new groups start simplified.
+	DebtMode models.DebtMode `json:"debt_mode"`
 }
 
 type AddMemberRequest struct {
+	UserID string      `json:"user_id"`
+	Role   models.Role `json:"role"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role models.Role `json:"role"`
+}
+
+// LockPeriodRequest locks the expenses incurred on or before LockedUntil;
+// an empty LockedUntil lifts the lock.
+type LockPeriodRequest struct {
+	LockedUntil string `json:"locked_until" example:"2024-03-31"`
+}
+
+type TransferOwnershipRequest struct {
 	UserID string `json:"user_id"`
 }
 
@@ -32,7 +53,7 @@ type AddMemberRequest struct {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        body  body      CreateGroupRequest  true  "Group name"
+// @Param        body  body      CreateGroupRequest  true  "Group name and currency"
 // @Success      201   {object}  models.Group
 // @Failure      400   {string}  string  "invalid request body"
 // @Failure      401   {string}  string  "unauthorized"
@@ -53,7 +74,15 @@ func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	group, err := h.service.CreateGroup(req.Name, parsedID)
+	currency := models.DefaultCurrency
+	if req.Currency != "" {
+		if currency, err = models.ParseCurrency(req.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	group, err := h.service.CreateGroup(req.Name, currency, parsedID)
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -135,20 +164,26 @@ func (h *Handler) GetGroup(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateGroup godoc
-// @Summary      Update a group name
+// @Summary      Update a group's name, currency and debt mode
 // @Tags         groups
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        id    path      string              true  "Group ID"
-// @Param        body  body      CreateGroupRequest  true  "New group name"
+// @Param        body  body      CreateGroupRequest  true  "New group name, currency and debt mode"
 // @Success      200   {object}  models.Group
 // @Failure      400   {string}  string  "invalid request"
 // @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
 // @Failure      404   {string}  string  "group not found"
 // @Failure      500   {string}  string  "internal error"
 // @Router       /api/groups/{id} [put]
 func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	callerID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
 
 	groupIDStr := r.PathValue("id")
 	groupID, err := uuid.Parse(groupIDStr)
@@ -158,13 +193,31 @@ func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to edit this group", http.StatusForbidden)
+		return
+	}
+
 	var req CreateGroupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	updatedGroup, err := h.service.UpdateGroup(groupID, req.Name)
+	var currency string
+	if req.Currency != "" {
+		if currency, err = models.ParseCurrency(req.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.DebtMode != "" && !req.DebtMode.Valid() {
+		http.Error(w, "invalid debt mode", http.StatusBadRequest)
+		return
+	}
+
+	updatedGroup, err := h.service.UpdateGroup(groupID, req.Name, currency, req.DebtMode, callerID)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "group not found", http.StatusNotFound)
@@ -189,6 +242,8 @@ func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 // @Success      204
 // @Failure      400  {string}  string  "invalid group ID"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "group not found"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id} [delete]
 func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
@@ -200,6 +255,11 @@ func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.GetGroupRole(r).Can(models.PermissionDeleteGroup) {
+		http.Error(w, "only the group owner can delete the group", http.StatusForbidden)
+		return
+	}
+
 	err = h.service.DeleteGroup(groupID)
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -208,8 +268,45 @@ func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetMembers godoc
+// @Summary      List the members of a group with their roles
+// @Tags         groups
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {array}   models.GroupMember
+// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/members [get]
+func (h *Handler) GetMembers(w http.ResponseWriter, r *http.Request) {
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.service.GetMembers(groupID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if members == nil {
+		members = []models.GroupMember{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
 // AddMember godoc
 // @Summary      Add a member to a group
+// @Description  The role defaults to "member". Only the owner can add admins.
 // @Tags         groups
 // @Accept       json
 // @Security     BearerAuth
@@ -218,9 +315,16 @@ func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 // @Success      204
 // @Failure      400  {string}  string  "invalid request"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "group not found"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/members [post]
 func (h *Handler) AddMember(w http.ResponseWriter, r *http.Request) {
+	callerID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
 
 	groupIDStr := r.PathValue("id")
 	groupID, err := uuid.Parse(groupIDStr)
@@ -240,7 +344,179 @@ func (h *Handler) AddMember(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid user ID", http.StatusBadRequest)
 		return
 	}
-	err = h.service.AddMember(groupID, userID)
+
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if !req.Role.Valid() || req.Role == models.RoleOwner {
+		http.Error(w, "role must be one of admin, member or viewer", http.StatusBadRequest)
+		return
+	}
+
+	callerRole := middleware.GetGroupRole(r)
+	if !callerRole.Can(models.PermissionManageMembers) {
+		http.Error(w, "you do not have permission to manage members", http.StatusForbidden)
+		return
+	}
+	if req.Role == models.RoleAdmin && !callerRole.Can(models.PermissionManageAdmins) {
+		http.Error(w, "only the group owner can add admins", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.AddMember(groupID, userID, req.Role, callerID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateMemberRole godoc
+// @Summary      Change a member's role
+// @Description  Only the owner can promote to or demote from admin. The owner role changes through an ownership transfer.
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                   true  "Group ID"
+// @Param        user_id  path      string                   true  "User ID"
+// @Param        body     body      UpdateMemberRoleRequest  true  "New role"
+// @Success      200  {object}  models.GroupMember
+// @Failure      400  {string}  string  "invalid request"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "member not found"
+// @Failure      409  {string}  string  "owner cannot be changed"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/members/{user_id} [put]
+func (h *Handler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	callerID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDStr := r.PathValue("user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Role.Valid() {
+		http.Error(w, "role must be one of admin, member or viewer", http.StatusBadRequest)
+		return
+	}
+
+	callerRole := middleware.GetGroupRole(r)
+	if !callerRole.Can(models.PermissionManageMembers) {
+		http.Error(w, "you do not have permission to manage members", http.StatusForbidden)
+		return
+	}
+
+	currentRole, err := h.service.GetMemberRole(groupID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if (currentRole == models.RoleAdmin || req.Role == models.RoleAdmin) && !callerRole.Can(models.PermissionManageAdmins) {
+		http.Error(w, "only the group owner can promote or demote admins", http.StatusForbidden)
+		return
+	}
+
+	member, err := h.service.UpdateMemberRole(groupID, userID, req.Role, callerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrOwnerMembership {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(member)
+}
+
+// TransferOwnership godoc
+// @Summary      Transfer group ownership to another member
+// @Description  The current owner becomes an admin.
+// @Tags         groups
+// @Accept       json
+// @Security     BearerAuth
+// @Param        id    path      string                    true  "Group ID"
+// @Param        body  body      TransferOwnershipRequest  true  "New owner"
+// @Success      204
+// @Failure      400  {string}  string  "invalid request"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "member not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/owner [put]
+func (h *Handler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+
+	userID := middleware.GetUserID(r)
+	parsedID, err := uuid.Parse(userID)
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	newOwnerID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if newOwnerID == parsedID {
+		http.Error(w, "you already own this group", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionTransferOwnership) {
+		http.Error(w, "only the group owner can transfer ownership", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.TransferOwnership(groupID, parsedID, newOwnerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -251,6 +527,7 @@ func (h *Handler) AddMember(w http.ResponseWriter, r *http.Request) {
 
 // RemoveMember godoc
 // @Summary      Remove a member from a group
+// @Description  Members may always remove themselves. The owner has to transfer ownership before leaving.
 // @Tags         groups
 // @Security     BearerAuth
 // @Param        id       path      string  true  "Group ID"
@@ -258,10 +535,19 @@ func (h *Handler) AddMember(w http.ResponseWriter, r *http.Request) {
 // @Success      204
 // @Failure      400  {string}  string  "invalid ID"
 // @Failure      401  {string}  string  "unauthorized"
+// @Failure      403  {string}  string  "forbidden"
+// @Failure      404  {string}  string  "member not found"
+// @Failure      409  {string}  string  "owner cannot be removed"
 // @Failure      500  {string}  string  "internal error"
 // @Router       /api/groups/{id}/members/{user_id} [delete]
 func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 
+	callerID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
 	groupIDStr := r.PathValue("id")
 	groupID, err := uuid.Parse(groupIDStr)
 	if err != nil {
@@ -276,7 +562,38 @@ func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.RemoveMember(groupID, userID)
+	// leaving a group needs no permission, removing someone else does
+	if userID != callerID {
+		callerRole := middleware.GetGroupRole(r)
+		if !callerRole.Can(models.PermissionManageMembers) {
+			http.Error(w, "you do not have permission to manage members", http.StatusForbidden)
+			return
+		}
+
+		targetRole, err := h.service.GetMemberRole(groupID, userID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "member not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if targetRole == models.RoleAdmin && !callerRole.Can(models.PermissionManageAdmins) {
+			http.Error(w, "only the group owner can remove admins", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = h.service.RemoveMember(groupID, userID, callerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrOwnerMembership {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -284,3 +601,67 @@ func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// LockPeriod godoc
+// @Summary      Lock the group's expenses up to a day
+// @Description  Expenses incurred on or before locked_until can no longer be added, edited or deleted. An empty locked_until lifts the lock.
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string             true  "Group ID"
+// @Param        body  body      LockPeriodRequest  true  "Last locked day"
+// @Success      200   {object}  models.Group
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/lock [put]
+func (h *Handler) LockPeriod(w http.ResponseWriter, r *http.Request) {
+	callerID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDStr := r.PathValue("id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to edit this group", http.StatusForbidden)
+		return
+	}
+
+	var req LockPeriodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var until time.Time
+	if req.LockedUntil != "" {
+		if until, err = time.Parse(models.DateLayout, req.LockedUntil); err != nil {
+			http.Error(w, "invalid locked_until, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	group, err := h.service.LockPeriod(groupID, until, callerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(group)
+}
@@ -2,12 +2,20 @@ package groups
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/activity"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
 
+// ErrOwnerMembership is returned when a change would remove or demote the
+// group owner; ownership has to be transferred first.
+var ErrOwnerMembership = errors.New("the owner's membership can only change through an ownership transfer")
+
+const groupColumns = `id, name, currency, debt_mode, locked_until, created_by, created_at`
+
 type Service struct {
 	db *sql.DB
 }
@@ -16,7 +24,9 @@ func NewService(db *sql.DB) *Service {
 	return &Service{db: db}
 }
 
-func (s *Service) CreateGroup(name string, createdBy uuid.UUID) (*models.Group, error) {
+// CreateGroup creates the group with currency as its base currency and makes
+// the creator its owner.
+func (s *Service) CreateGroup(name, currency string, createdBy uuid.UUID) (*models.Group, error) {
 	groupID := uuid.New()
 	createdAt := time.Now()
 
@@ -27,14 +37,21 @@ func (s *Service) CreateGroup(name string, createdBy uuid.UUID) (*models.Group,
 	}
 	defer tx.Rollback() // rolls back if we don't commit
 
-	_, err = tx.Exec(`INSERT INTO groups (id, name, created_by, created_at) VALUES ($1, $2, $3, $4)`,
-		groupID, name, createdBy, createdAt)
+	_, err = tx.Exec(`INSERT INTO groups (id, name, currency, created_by, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		groupID, name, currency, createdBy, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO group_members (id, group_id, user_id, role, joined_at) VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New(), groupID, createdBy, models.RoleOwner, createdAt)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = tx.Exec(`INSERT INTO group_members (id, group_id, user_id, joined_at) VALUES ($1, $2, $3, $4)`,
-		uuid.New(), groupID, createdBy, createdAt)
+	group := &models.Group{ID: groupID, Name: name, Currency: currency, DebtMode: models.DebtModeSimplified, CreatedBy: createdBy, CreatedAt: createdAt}
+	err = activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: createdBy, Verb: models.VerbCreated,
+		ObjectType: models.ActivityGroup, ObjectID: groupID, After: activity.Group(*group)})
 	if err != nil {
 		return nil, err
 	}
@@ -44,12 +61,12 @@ func (s *Service) CreateGroup(name string, createdBy uuid.UUID) (*models.Group,
 		return nil, err
 	}
 
-	return &models.Group{ID: groupID, Name: name, CreatedBy: createdBy, CreatedAt: createdAt}, nil
+	return group, nil
 }
 
 func (s *Service) GetGroups(userID uuid.UUID) ([]models.Group, error) {
 	rows, err := s.db.Query(`
-        SELECT g.id, g.name, g.created_by, g.created_at
+        SELECT g.id, g.name, g.currency, g.debt_mode, g.locked_until, g.created_by, g.created_at
         FROM groups g
         JOIN group_members gm ON g.id = gm.group_id
         WHERE gm.user_id = $1
@@ -61,8 +78,8 @@ func (s *Service) GetGroups(userID uuid.UUID) ([]models.Group, error) {
 
 	var groups []models.Group
 	for rows.Next() {
-		var group models.Group
-		if err := rows.Scan(&group.ID, &group.Name, &group.CreatedBy, &group.CreatedAt); err != nil {
+		group, err := scanGroup(rows)
+		if err != nil {
 			return nil, err
 		}
 		groups = append(groups, group)
@@ -71,21 +88,68 @@ func (s *Service) GetGroups(userID uuid.UUID) ([]models.Group, error) {
 }
 
 func (s *Service) GetGroup(groupID uuid.UUID) (*models.Group, error) {
-	var group models.Group
-	err := s.db.QueryRow(`SELECT id, name, created_by, created_at FROM groups WHERE id = $1`, groupID).
-		Scan(&group.ID, &group.Name, &group.CreatedBy, &group.CreatedAt)
+	group, err := scanGroup(s.db.QueryRow(`SELECT `+groupColumns+` FROM groups WHERE id = $1`, groupID))
 	if err != nil {
 		return nil, err
 	}
 	return &group, nil
 }
 
-func (s *Service) UpdateGroup(groupID uuid.UUID, name string) (*models.Group, error) {
-	_, err := s.db.Exec(`UPDATE groups SET name = $1 WHERE id = $2`, name, groupID)
+func scanGroup(row interface{ Scan(...any) error }) (models.Group, error) {
+	var group models.Group
+	var lockedUntil sql.NullTime
+	err := row.Scan(&group.ID, &group.Name, &group.Currency, &group.DebtMode, &lockedUntil, &group.CreatedBy, &group.CreatedAt)
 	if err != nil {
+		return models.Group{}, err
+	}
+	if lockedUntil.Valid {
+		group.LockedUntil = lockedUntil.Time.Format(models.DateLayout)
+	}
+	return group, nil
+}
+
+// UpdateGroup renames the group and changes its base currency and debt mode
+// unless they are empty. Balances and debts are computed on the fly, so
+// nothing else changes.
+func (s *Service) UpdateGroup(groupID uuid.UUID, name, currency string, debtMode models.DebtMode, updatedBy uuid.UUID) (*models.Group, error) {
+	return s.updateGroup(groupID, updatedBy, `UPDATE groups SET name = $2, currency = COALESCE(NULLIF($3, ''), currency), debt_mode = COALESCE(NULLIF($4, ''), debt_mode)
+		WHERE id = $1 RETURNING `+groupColumns, name, currency, debtMode)
+}
+
+// LockPeriod locks the group's expenses incurred on or before until, or
+// lifts the lock when until is zero.
+func (s *Service) LockPeriod(groupID uuid.UUID, until time.Time, lockedBy uuid.UUID) (*models.Group, error) {
+	lockedUntil := sql.NullTime{Time: until, Valid: !until.IsZero()}
+	return s.updateGroup(groupID, lockedBy, `UPDATE groups SET locked_until = $2 WHERE id = $1 RETURNING `+groupColumns, lockedUntil)
+}
+
+// updateGroup runs update, which changes the group $1 using args from $2 on
+// and returns its columns, and records the change in the group's activity.
+func (s *Service) updateGroup(groupID, updatedBy uuid.UUID, update string, args ...any) (*models.Group, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanGroup(tx.QueryRow(`SELECT `+groupColumns+` FROM groups WHERE id = $1 FOR UPDATE`, groupID))
+	if err != nil {
+		return nil, err
+	}
+	group, err := scanGroup(tx.QueryRow(update, append([]any{groupID}, args...)...))
+	if err != nil {
+		return nil, err
+	}
+	err = activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: updatedBy, Verb: models.VerbUpdated,
+		ObjectType: models.ActivityGroup, ObjectID: groupID, Before: activity.Group(before), After: activity.Group(group)})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return s.GetGroup(groupID) // now reads after the update is committed
+	return &group, nil
 }
 
 func (s *Service) DeleteGroup(groupID uuid.UUID) error {
@@ -109,21 +173,163 @@ func (s *Service) DeleteGroup(groupID uuid.UUID) error {
 	return tx.Commit()
 }
 
-func (s *Service) AddMember(groupID, userID uuid.UUID) error {
+func (s *Service) GetMembers(groupID uuid.UUID) ([]models.GroupMember, error) {
+	rows, err := s.db.Query(`SELECT id, group_id, user_id, role, joined_at FROM group_members WHERE group_id = $1 ORDER BY joined_at`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.GroupMember
+	for rows.Next() {
+		var member models.GroupMember
+		if err := rows.Scan(&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
 
-	_, err := s.db.Exec(`INSERT INTO group_members (id, group_id, user_id, joined_at) VALUES ($1, $2, $3, $4)`,
-		uuid.New(), groupID, userID, time.Now())
+// GetMemberRole returns sql.ErrNoRows when the user is not a member of the group.
+func (s *Service) GetMemberRole(groupID, userID uuid.UUID) (models.Role, error) {
+	var role models.Role
+	err := s.db.QueryRow(`SELECT role FROM group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// AddMember is idempotent: adding an existing member keeps their current role.
+func (s *Service) AddMember(groupID, userID uuid.UUID, role models.Role, addedBy uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO group_members (id, group_id, user_id, role, joined_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (group_id, user_id) DO NOTHING`,
+		uuid.New(), groupID, userID, role, time.Now())
 	if err != nil {
 		return err
 	}
-	return nil
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	err = activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: addedBy, Verb: models.VerbAdded,
+		ObjectType: models.ActivityMember, ObjectID: userID, After: activity.Member(userID, role)})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *Service) RemoveMember(groupID, userID uuid.UUID) error {
+func (s *Service) UpdateMemberRole(groupID, userID uuid.UUID, role models.Role, changedBy uuid.UUID) (*models.GroupMember, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current models.Role
+	err = tx.QueryRow(`SELECT role FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE`, groupID, userID).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+	if current == models.RoleOwner || role == models.RoleOwner {
+		return nil, ErrOwnerMembership
+	}
 
-	_, err := s.db.Exec(`DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID)
+	var member models.GroupMember
+	err = tx.QueryRow(`UPDATE group_members SET role = $1 WHERE group_id = $2 AND user_id = $3
+		RETURNING id, group_id, user_id, role, joined_at`, role, groupID, userID).
+		Scan(&member.ID, &member.GroupID, &member.UserID, &member.Role, &member.JoinedAt)
+	if err != nil {
+		return nil, err
+	}
+	err = activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: changedBy, Verb: models.VerbUpdated,
+		ObjectType: models.ActivityMember, ObjectID: userID, Before: activity.Member(userID, current), After: activity.Member(userID, role)})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// TransferOwnership makes newOwnerID the owner and demotes the current owner to admin.
+func (s *Service) TransferOwnership(groupID, ownerID, newOwnerID uuid.UUID) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	var role models.Role
+	err = tx.QueryRow(`SELECT role FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE`, groupID, newOwnerID).Scan(&role)
+	if err != nil {
+		return err
+	}
+
+	// demote first, the schema allows only one owner per group
+	res, err := tx.Exec(`UPDATE group_members SET role = $1 WHERE group_id = $2 AND user_id = $3 AND role = $4`,
+		models.RoleAdmin, groupID, ownerID, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrOwnerMembership
+	}
+
+	_, err = tx.Exec(`UPDATE group_members SET role = $1 WHERE group_id = $2 AND user_id = $3`, models.RoleOwner, groupID, newOwnerID)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range []struct {
+		userID   uuid.UUID
+		from, to models.Role
+	}{{ownerID, models.RoleOwner, models.RoleAdmin}, {newOwnerID, role, models.RoleOwner}} {
+		err := activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: ownerID, Verb: models.VerbUpdated, ObjectType: models.ActivityMember,
+			ObjectID: change.userID, Before: activity.Member(change.userID, change.from), After: activity.Member(change.userID, change.to)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Service) RemoveMember(groupID, userID, removedBy uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role models.Role
+	err = tx.QueryRow(`SELECT role FROM group_members WHERE group_id = $1 AND user_id = $2 FOR UPDATE`, groupID, userID).Scan(&role)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleOwner {
+		return ErrOwnerMembership
+	}
+
+	_, err = tx.Exec(`DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID)
+	if err != nil {
+		return err
+	}
+	err = activity.Record(tx, activity.Entry{GroupID: groupID, ActorID: removedBy, Verb: models.VerbRemoved,
+		ObjectType: models.ActivityMember, ObjectID: userID, Before: activity.Member(userID, role)})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/IvanLouren/GoSplit/internal/groups"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
@@ -51,12 +54,20 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
+		return fmt.Errorf("failed to list migrations: %w", err)
 	}
-	_, err = db.Exec(string(migration))
-	return err
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
 }
 
 func TestCreateGroup(t *testing.T) {
@@ -74,7 +85,7 @@ func TestCreateGroup(t *testing.T) {
 
 	service := groups.NewService(testDB)
 
-	group, err := service.CreateGroup("Trip to Rome", parsedUserID)
+	group, err := service.CreateGroup("Trip to Rome", models.DefaultCurrency, parsedUserID)
 	if err != nil {
 		t.Fatalf("expected no error, got: %s", err)
 	}
@@ -185,7 +196,7 @@ func TestUpdateGroup(t *testing.T) {
 	}
 
 	service := groups.NewService(testDB)
-	updGroup, err := service.UpdateGroup(parsedGroupID, "New Name")
+	updGroup, err := service.UpdateGroup(parsedGroupID, "New Name", "", "", uuid.MustParse(userID))
 	if err != nil {
 		t.Fatalf("failed to update group: %s", err)
 	}
@@ -197,6 +208,18 @@ func TestUpdateGroup(t *testing.T) {
 	if updGroup.ID != parsedGroupID {
 		t.Errorf("expected group ID %s, got %s", parsedGroupID, updGroup.ID)
 	}
+
+	if updGroup.Currency != "EUR" || updGroup.DebtMode != models.DebtModeSimplified {
+		t.Errorf("expected currency and debt mode unchanged, got %s and %s", updGroup.Currency, updGroup.DebtMode)
+	}
+
+	updGroup, err = service.UpdateGroup(parsedGroupID, "New Name", "CHF", models.DebtModePairwise, uuid.MustParse(userID))
+	if err != nil {
+		t.Fatalf("failed to update group: %s", err)
+	}
+	if updGroup.Currency != "CHF" || updGroup.DebtMode != models.DebtModePairwise {
+		t.Errorf("expected CHF and pairwise, got %s and %s", updGroup.Currency, updGroup.DebtMode)
+	}
 }
 
 func TestDeleteGroup(t *testing.T) {
@@ -261,7 +284,7 @@ func TestAddMember(t *testing.T) {
 	}
 
 	service := groups.NewService(testDB)
-	err = service.AddMember(parsedGroupID, parsedMemberID)
+	err = service.AddMember(parsedGroupID, parsedMemberID, models.RoleMember, uuid.MustParse(userID))
 	if err != nil {
 		t.Fatalf("failed to add member: %s", err)
 	}
@@ -308,12 +331,12 @@ func TestRemoveMember(t *testing.T) {
 	}
 
 	service := groups.NewService(testDB)
-	err = service.AddMember(parsedGroupID, parsedMemberID)
+	err = service.AddMember(parsedGroupID, parsedMemberID, models.RoleMember, uuid.MustParse(userID))
 	if err != nil {
 		t.Fatalf("failed to add member: %s", err)
 	}
 
-	err = service.RemoveMember(parsedGroupID, parsedMemberID)
+	err = service.RemoveMember(parsedGroupID, parsedMemberID, uuid.MustParse(userID))
 	if err != nil {
 		t.Fatalf("failed to remove member: %s", err)
 	}
@@ -326,3 +349,203 @@ func TestRemoveMember(t *testing.T) {
 		t.Errorf("expected member to be 0 group, got %d", len(groupMember))
 	}
 }
+
+func TestGetMemberRole(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 10", "user10@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	var outsiderID string
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 11", "user11@test.com", "hashedpassword").Scan(&outsiderID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		t.Fatalf("failed to parse userID: %s", err)
+	}
+	parsedOutsiderID, err := uuid.Parse(outsiderID)
+	if err != nil {
+		t.Fatalf("failed to parse outsiderID: %s", err)
+	}
+
+	service := groups.NewService(testDB)
+	group, err := service.CreateGroup("Trip to Rome", models.DefaultCurrency, parsedUserID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	role, err := service.GetMemberRole(group.ID, parsedUserID)
+	if err != nil {
+		t.Fatalf("failed to get member role: %s", err)
+	}
+	if role != models.RoleOwner {
+		t.Errorf("expected creator to be owner, got %s", role)
+	}
+
+	_, err = service.GetMemberRole(group.ID, parsedOutsiderID)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for outsider, got %v", err)
+	}
+}
+
+func TestUpdateMemberRole(t *testing.T) {
+	var ownerID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 12", "user12@test.com", "hashedpassword").Scan(&ownerID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	var memberID string
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 13", "user13@test.com", "hashedpassword").Scan(&memberID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	parsedOwnerID, _ := uuid.Parse(ownerID)
+	parsedMemberID, _ := uuid.Parse(memberID)
+
+	service := groups.NewService(testDB)
+	group, err := service.CreateGroup("Flat", models.DefaultCurrency, parsedOwnerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := service.AddMember(group.ID, parsedMemberID, models.RoleViewer, parsedOwnerID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	member, err := service.UpdateMemberRole(group.ID, parsedMemberID, models.RoleAdmin, parsedOwnerID)
+	if err != nil {
+		t.Fatalf("failed to update member role: %s", err)
+	}
+	if member.Role != models.RoleAdmin {
+		t.Errorf("expected role admin, got %s", member.Role)
+	}
+
+	_, err = service.UpdateMemberRole(group.ID, parsedOwnerID, models.RoleMember, parsedOwnerID)
+	if err != groups.ErrOwnerMembership {
+		t.Errorf("expected ErrOwnerMembership when demoting the owner, got %v", err)
+	}
+
+	_, err = service.UpdateMemberRole(group.ID, parsedMemberID, models.RoleOwner, parsedOwnerID)
+	if err != groups.ErrOwnerMembership {
+		t.Errorf("expected ErrOwnerMembership when promoting to owner, got %v", err)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	var ownerID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 14", "user14@test.com", "hashedpassword").Scan(&ownerID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	var memberID string
+	err = testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 15", "user15@test.com", "hashedpassword").Scan(&memberID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	parsedOwnerID, _ := uuid.Parse(ownerID)
+	parsedMemberID, _ := uuid.Parse(memberID)
+
+	service := groups.NewService(testDB)
+	group, err := service.CreateGroup("Flat", models.DefaultCurrency, parsedOwnerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+	if err := service.AddMember(group.ID, parsedMemberID, models.RoleMember, parsedOwnerID); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	if err := service.TransferOwnership(group.ID, parsedOwnerID, parsedMemberID); err != nil {
+		t.Fatalf("failed to transfer ownership: %s", err)
+	}
+
+	newOwnerRole, err := service.GetMemberRole(group.ID, parsedMemberID)
+	if err != nil {
+		t.Fatalf("failed to get member role: %s", err)
+	}
+	if newOwnerRole != models.RoleOwner {
+		t.Errorf("expected new owner role owner, got %s", newOwnerRole)
+	}
+
+	oldOwnerRole, err := service.GetMemberRole(group.ID, parsedOwnerID)
+	if err != nil {
+		t.Fatalf("failed to get member role: %s", err)
+	}
+	if oldOwnerRole != models.RoleAdmin {
+		t.Errorf("expected previous owner to become admin, got %s", oldOwnerRole)
+	}
+
+	// the previous owner no longer owns the group
+	err = service.TransferOwnership(group.ID, parsedOwnerID, parsedOwnerID)
+	if err != groups.ErrOwnerMembership {
+		t.Errorf("expected ErrOwnerMembership for a non-owner transfer, got %v", err)
+	}
+}
+
+func TestRemoveMember_Owner(t *testing.T) {
+	var ownerID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 16", "user16@test.com", "hashedpassword").Scan(&ownerID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	parsedOwnerID, _ := uuid.Parse(ownerID)
+
+	service := groups.NewService(testDB)
+	group, err := service.CreateGroup("Flat", models.DefaultCurrency, parsedOwnerID)
+	if err != nil {
+		t.Fatalf("failed to create group: %s", err)
+	}
+
+	err = service.RemoveMember(group.ID, parsedOwnerID, parsedOwnerID)
+	if err != groups.ErrOwnerMembership {
+		t.Errorf("expected ErrOwnerMembership when removing the owner, got %v", err)
+	}
+}
+
+func TestLockPeriod(t *testing.T) {
+	var userID string
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User 17", "user17@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+
+	var groupID string
+	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+		"Club Accounts", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	parsedGroupID, _ := uuid.Parse(groupID)
+
+	service := groups.NewService(testDB)
+	group, err := service.LockPeriod(parsedGroupID, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), uuid.MustParse(userID))
+	if err != nil {
+		t.Fatalf("failed to lock period: %s", err)
+	}
+	if group.LockedUntil != "2024-03-31" {
+		t.Errorf("expected locked until 2024-03-31, got %q", group.LockedUntil)
+	}
+
+	group, err = service.LockPeriod(parsedGroupID, time.Time{}, uuid.MustParse(userID))
+	if err != nil {
+		t.Fatalf("failed to unlock period: %s", err)
+	}
+	if group.LockedUntil != "" {
+		t.Errorf("expected the lock to be lifted, got %q", group.LockedUntil)
+	}
+
+	if _, err := service.LockPeriod(uuid.New(), time.Time{}, uuid.MustParse(userID)); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an unknown group, got %v", err)
+	}
+}
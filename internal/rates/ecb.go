@@ -0,0 +1,192 @@
+package rates
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+// ErrInvalidFile is returned when an imported rate file can't be parsed.
+var ErrInvalidFile = errors.New("invalid rate file")
+
+// ecbBase is the currency every rate in an ECB reference file is quoted against.
+const ecbBase = "EUR"
+
+// ecbCSVDateLayout is the date format of the single-day eurofxref.csv file;
+// the historical file uses DateLayout.
+const ecbCSVDateLayout = "02 January 2006"
+
+// ParseECB reads euro foreign exchange reference rates in the formats the ECB
+// publishes: the eurofxref XML envelope (daily, 90-day or historical) and the
+// eurofxref CSV files. Every rate is returned with EUR as the base currency.
+func ParseECB(r io.Reader) ([]models.ExchangeRate, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: empty file", ErrInvalidFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		}
+		switch {
+		case isSpace(b[0]):
+			br.ReadByte()
+		case b[0] == '<':
+			return parseECBXML(br)
+		default:
+			return parseECBCSV(br)
+		}
+	}
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func parseECBXML(r io.Reader) ([]models.ExchangeRate, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+
+	var rates []models.ExchangeRate
+	for _, d := range envelope.Cube.Days {
+		date, err := time.Parse(DateLayout, d.Time)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q", ErrInvalidFile, d.Time)
+		}
+		for _, c := range d.Rates {
+			rate, err := ecbRate(date, c.Currency, c.Rate)
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, rate)
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: no rates found", ErrInvalidFile)
+	}
+	return rates, nil
+}
+
+func parseECBCSV(r io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	if len(header) < 2 || !strings.EqualFold(strings.TrimSpace(header[0]), "Date") {
+		return nil, fmt.Errorf("%w: expected a Date column first", ErrInvalidFile)
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		}
+
+		dateStr := strings.TrimSpace(record[0])
+		if dateStr == "" {
+			continue
+		}
+		date, err := time.Parse(DateLayout, dateStr)
+		if err != nil {
+			date, err = time.Parse(ecbCSVDateLayout, dateStr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q", ErrInvalidFile, dateStr)
+		}
+
+		for i := 1; i < len(record) && i < len(header); i++ {
+			currency, value := strings.TrimSpace(header[i]), strings.TrimSpace(record[i])
+			// the files end every line with a comma and mark missing rates N/A
+			if currency == "" || value == "" || value == "N/A" {
+				continue
+			}
+			rate, err := ecbRate(date, currency, value)
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, rate)
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: no rates found", ErrInvalidFile)
+	}
+	return rates, nil
+}
+
+func ecbRate(date time.Time, currency, value string) (models.ExchangeRate, error) {
+	quote, err := models.ParseCurrency(currency)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	rate, err := ParseRate(value)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s %s: %s", ErrInvalidFile, quote, date.Format(DateLayout), err)
+	}
+	return models.ExchangeRate{
+		Base:   ecbBase,
+		Quote:  quote,
+		Date:   date.Format(DateLayout),
+		Rate:   rate,
+		Source: SourceImport,
+	}, nil
+}
+
+// ParseRate validates a positive decimal rate such as "0.9312" and returns it
+// in canonical form.
+func ParseRate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "eE/") {
+		return "", fmt.Errorf("invalid rate %q", s)
+	}
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return "", fmt.Errorf("invalid rate %q", s)
+	}
+	formatted := formatRate(rate)
+	if formatted == "0" {
+		return "", fmt.Errorf("rate %q is too small", s)
+	}
+	return formatted, nil
+}
+
+// formatRate prints a rate with up to the 10 decimals the schema stores.
+func formatRate(rate *big.Rat) string {
+	s := rate.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
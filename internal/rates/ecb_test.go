@@ -0,0 +1,82 @@
+package rates_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/rates"
+)
+
+const ecbXML = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time="2024-01-03">
+			<Cube currency="USD" rate="1.0919"/>
+			<Cube currency="GBP" rate="0.86053"/>
+		</Cube>
+		<Cube time="2024-01-02">
+			<Cube currency="USD" rate="1.0956"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestParseECB_XML(t *testing.T) {
+	parsed, err := rates.ParseECB(strings.NewReader(ecbXML))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if len(parsed) != 3 {
+		t.Fatalf("expected 3 rates, got %d", len(parsed))
+	}
+
+	got := parsed[1]
+	if got.Base != "EUR" || got.Quote != "GBP" || got.Date != "2024-01-03" || got.Rate != "0.86053" || got.Source != rates.SourceImport {
+		t.Errorf("expected EUR/GBP 0.86053 on 2024-01-03 from import, got %s/%s %s on %s from %s", got.Base, got.Quote, got.Rate, got.Date, got.Source)
+	}
+}
+
+func TestParseECB_CSV(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want int
+	}{
+		{"daily", "\ufeffDate, USD, JPY, BGN, \n03 January 2024, 1.0919, 155.86, 1.9558, \n", 3},
+		{"historical", "Date,USD,CYP,\n2024-01-03,1.0919,N/A,\n2024-01-02,1.0956,N/A,\n", 2},
+	}
+
+	for _, tt := range tests {
+		parsed, err := rates.ParseECB(strings.NewReader(tt.file))
+		if err != nil {
+			t.Errorf("%s: failed to parse: %s", tt.name, err)
+			continue
+		}
+		if len(parsed) != tt.want {
+			t.Errorf("%s: expected %d rates, got %d", tt.name, tt.want, len(parsed))
+			continue
+		}
+		if parsed[0].Quote != "USD" || parsed[0].Date != "2024-01-03" || parsed[0].Rate != "1.0919" {
+			t.Errorf("%s: expected USD 1.0919 on 2024-01-03, got %s %s on %s", tt.name, parsed[0].Quote, parsed[0].Rate, parsed[0].Date)
+		}
+	}
+}
+
+func TestParseECB_Invalid(t *testing.T) {
+	for _, file := range []string{
+		"",
+		"<Envelope><Cube></Cube></Envelope>",
+		`<Envelope><Cube><Cube time="yesterday"><Cube currency="USD" rate="1.09"/></Cube></Cube></Envelope>`,
+		"Currency,USD\n2024-01-02,1.09\n",
+		"Date,USD\n2024-01-02,-1\n",
+		"Date,US\n2024-01-02,1.09\n",
+	} {
+		if _, err := rates.ParseECB(strings.NewReader(file)); !errors.Is(err, rates.ErrInvalidFile) {
+			t.Errorf("ParseECB(%q): expected ErrInvalidFile, got %v", file, err)
+		}
+	}
+}
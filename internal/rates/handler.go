@@ -0,0 +1,199 @@
+package rates
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// maxImportSize is enough for the full ECB history file.
+const maxImportSize = 16 << 20
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type SetRateRequest struct {
+	Base  string      `json:"base" example:"EUR"`
+	Quote string      `json:"quote" example:"CHF"`
+	Date  string      `json:"date" example:"2024-01-02"`
+	Rate  json.Number `json:"rate" swaggertype:"number" example:"0.9312"`
+}
+
+type ImportRatesResponse struct {
+	Imported int `json:"imported"`
+}
+
+// GetRates godoc
+// @Summary      List the exchange rates of a group
+// @Tags         rates
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {array}   models.ExchangeRate
+// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/rates [get]
+func (h *Handler) GetRates(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	rates, err := h.service.GetRates(groupID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if rates == nil {
+		rates = []models.ExchangeRate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rates)
+}
+
+// SetRate godoc
+// @Summary      Set an exchange rate manually
+// @Description  Stores how many units of quote one unit of base was worth on date (today if empty), replacing any rate for the same pair and day.
+// @Tags         rates
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string          true  "Group ID"
+// @Param        body  body      SetRateRequest  true  "Exchange rate"
+// @Success      201   {object}  models.ExchangeRate
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/rates [post]
+func (h *Handler) SetRate(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage exchange rates", http.StatusForbidden)
+		return
+	}
+
+	var req SetRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	base, err := models.ParseCurrency(req.Base)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	quote, err := models.ParseCurrency(req.Quote)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if base == quote {
+		http.Error(w, "base and quote currencies must differ", http.StatusBadRequest)
+		return
+	}
+
+	if req.Date == "" {
+		req.Date = time.Now().UTC().Format(DateLayout)
+	}
+	if _, err := time.Parse(DateLayout, req.Date); err != nil {
+		http.Error(w, "date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	value, err := ParseRate(req.Rate.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.SetRate(groupID, models.ExchangeRate{
+		Base:   base,
+		Quote:  quote,
+		Date:   req.Date,
+		Rate:   value,
+		Source: SourceManual,
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rate)
+}
+
+// ImportRates godoc
+// @Summary      Import exchange rates from an ECB reference file
+// @Description  The body is an ECB euro foreign exchange reference file, either the eurofxref XML envelope or the eurofxref CSV (daily or historical). Rates are stored with EUR as the base and replace existing rates for the same pair and day.
+// @Tags         rates
+// @Accept       xml
+// @Accept       plain
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string  true  "Group ID"
+// @Param        body  body      string  true  "ECB XML or CSV file"
+// @Success      201   {object}  ImportRatesResponse
+// @Failure      400   {string}  string  "invalid rate file"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      413   {string}  string  "rate file is too large"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/rates/import [post]
+func (h *Handler) ImportRates(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage exchange rates", http.StatusForbidden)
+		return
+	}
+
+	rates, err := ParseECB(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "rate file is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imported, err := h.service.ImportRates(groupID, rates)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ImportRatesResponse{Imported: imported})
+}
@@ -0,0 +1,103 @@
+package rates
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Rate sources stored with every rate.
+const (
+	SourceManual = "manual"
+	SourceImport = "import"
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// SetRate stores a rate, replacing any rate for the same pair and day.
+func (s *Service) SetRate(groupID uuid.UUID, rate models.ExchangeRate) (models.ExchangeRate, error) {
+	return upsertRate(s.db, groupID, rate)
+}
+
+// ImportRates stores all rates in one transaction and returns how many were stored.
+func (s *Service) ImportRates(groupID uuid.UUID, rates []models.ExchangeRate) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		if _, err := upsertRate(tx, groupID, rate); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func (s *Service) GetRates(groupID uuid.UUID) ([]models.ExchangeRate, error) {
+	rows, err := s.db.Query(`SELECT id, group_id, base, quote, rate_date, rate, source, created_at
+		FROM exchange_rates WHERE group_id = $1 ORDER BY rate_date DESC, base, quote`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// Table loads all of the group's rates for conversions.
+func (s *Service) Table(groupID uuid.UUID) (*Table, error) {
+	rates, err := s.GetRates(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return NewTable(rates)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func upsertRate(db queryRower, groupID uuid.UUID, rate models.ExchangeRate) (models.ExchangeRate, error) {
+	return scanRate(db.QueryRow(`INSERT INTO exchange_rates (group_id, base, quote, rate_date, rate, source)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (group_id, base, quote, rate_date) DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, created_at = now()
+		RETURNING id, group_id, base, quote, rate_date, rate, source, created_at`,
+		groupID, rate.Base, rate.Quote, rate.Date, rate.Rate, rate.Source))
+}
+
+func scanRate(row interface{ Scan(...any) error }) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	var date time.Time
+	var value string
+	err := row.Scan(&rate.ID, &rate.GroupID, &rate.Base, &rate.Quote, &date, &value, &rate.Source, &rate.CreatedAt)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	rate.Date = date.Format(DateLayout)
+	if rate.Rate, err = ParseRate(value); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	return rate, nil
+}
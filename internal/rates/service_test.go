@@ -0,0 +1,150 @@
+package rates_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func createGroup(t *testing.T, email string) uuid.UUID {
+	t.Helper()
+	var userID, groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User", email, "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`,
+		"Trip to Zurich", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	return groupID
+}
+
+func TestSetRate(t *testing.T) {
+	groupID := createGroup(t, "user1@test.com")
+	service := rates.NewService(testDB)
+
+	rate, err := service.SetRate(groupID, models.ExchangeRate{Base: "EUR", Quote: "CHF", Date: "2024-01-02", Rate: "0.93", Source: rates.SourceManual})
+	if err != nil {
+		t.Fatalf("failed to set rate: %s", err)
+	}
+	if rate.Rate != "0.93" || rate.Date != "2024-01-02" {
+		t.Errorf("expected EUR/CHF 0.93 on 2024-01-02, got %s on %s", rate.Rate, rate.Date)
+	}
+
+	// same pair and day replaces the rate
+	_, err = service.SetRate(groupID, models.ExchangeRate{Base: "EUR", Quote: "CHF", Date: "2024-01-02", Rate: "0.9312", Source: rates.SourceManual})
+	if err != nil {
+		t.Fatalf("failed to replace rate: %s", err)
+	}
+
+	list, err := service.GetRates(groupID)
+	if err != nil {
+		t.Fatalf("failed to get rates: %s", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 rate, got %d", len(list))
+	}
+	if list[0].Rate != "0.9312" {
+		t.Errorf("expected rate 0.9312, got %s", list[0].Rate)
+	}
+}
+
+func TestImportRates(t *testing.T) {
+	groupID := createGroup(t, "user2@test.com")
+	service := rates.NewService(testDB)
+
+	parsed, err := rates.ParseECB(strings.NewReader("Date,GBP,CHF,\n2024-01-03,0.8653,0.9305,\n2024-01-02,0.8664,0.9312,\n"))
+	if err != nil {
+		t.Fatalf("failed to parse file: %s", err)
+	}
+
+	imported, err := service.ImportRates(groupID, parsed)
+	if err != nil {
+		t.Fatalf("failed to import rates: %s", err)
+	}
+	if imported != 4 {
+		t.Errorf("expected 4 rates imported, got %d", imported)
+	}
+
+	table, err := service.Table(groupID)
+	if err != nil {
+		t.Fatalf("failed to load table: %s", err)
+	}
+
+	// GBP -> CHF goes through EUR with the rates of that day
+	converted, err := table.Convert(models.NewMoney(10000, "GBP"), "CHF", time.Date(2024, 1, 2, 18, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to convert: %s", err)
+	}
+	if converted.Minor != 10748 || converted.Currency != "CHF" {
+		t.Errorf("expected 107.48 CHF, got %s %s", converted, converted.Currency)
+	}
+}
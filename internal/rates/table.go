@@ -0,0 +1,174 @@
+package rates
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+// ErrNoRate is returned when no rate on or before the requested date links
+// two currencies.
+var ErrNoRate = errors.New("no exchange rate")
+
+// DateLayout is the format of rate dates in the API and in imported files.
+const DateLayout = models.DateLayout
+
+// pivotCurrency is tried first when triangulating: imported ECB files quote
+// every currency against the euro.
+const pivotCurrency = "EUR"
+
+type datedRate struct {
+	date time.Time
+	rate *big.Rat
+}
+
+type pair struct {
+	base, quote string
+}
+
+// Table converts amounts using a group's rates. A conversion uses the most
+// recent rate published on or before the date of the amount, either quoted
+// directly, inverted, or triangulated through one other currency.
+type Table struct {
+	rates      map[pair][]datedRate
+	currencies []string
+}
+
+// NewTable builds a table from stored rates.
+func NewTable(rates []models.ExchangeRate) (*Table, error) {
+	t := &Table{rates: make(map[pair][]datedRate)}
+	seen := make(map[string]bool)
+	for _, r := range rates {
+		date, err := time.Parse(DateLayout, r.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate date %q: %w", r.Date, err)
+		}
+		rate, ok := new(big.Rat).SetString(r.Rate)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q", r.Rate)
+		}
+		p := pair{r.Base, r.Quote}
+		t.rates[p] = append(t.rates[p], datedRate{date: date, rate: rate})
+		for _, c := range []string{r.Base, r.Quote} {
+			if !seen[c] {
+				seen[c] = true
+				t.currencies = append(t.currencies, c)
+			}
+		}
+	}
+
+	for _, list := range t.rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].date.Before(list[j].date) })
+	}
+	sort.Slice(t.currencies, func(i, j int) bool {
+		if (t.currencies[i] == pivotCurrency) != (t.currencies[j] == pivotCurrency) {
+			return t.currencies[i] == pivotCurrency
+		}
+		return t.currencies[i] < t.currencies[j]
+	})
+	return t, nil
+}
+
+// Rate returns how many units of to one unit of from was worth on the given day.
+func (t *Table) Rate(from, to string, on time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	on = day(on)
+
+	if rate, _, ok := t.lookup(from, to, on); ok {
+		return rate, nil
+	}
+
+	// triangulate through the pivot with the most recent legs
+	var best *big.Rat
+	var bestDate time.Time
+	for _, via := range t.currencies {
+		if via == from || via == to {
+			continue
+		}
+		first, firstDate, ok := t.lookup(from, via, on)
+		if !ok {
+			continue
+		}
+		second, secondDate, ok := t.lookup(via, to, on)
+		if !ok {
+			continue
+		}
+		date := firstDate
+		if secondDate.Before(date) {
+			date = secondDate
+		}
+		if best == nil || date.After(bestDate) {
+			best, bestDate = new(big.Rat).Mul(first, second), date
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w from %s to %s on %s", ErrNoRate, from, to, on.Format(DateLayout))
+	}
+	return best, nil
+}
+
+// Convert converts m into the to currency, rounding half away from zero.
+func (t *Table) Convert(m models.Money, to string, on time.Time) (models.Money, error) {
+	rate, err := t.Rate(m.Currency, to, on)
+	if err != nil {
+		return models.Money{}, err
+	}
+	minor, err := convertMinor(m.Minor, rate)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return models.NewMoney(minor, to), nil
+}
+
+// lookup finds the most recent direct or inverted rate on or before on.
+func (t *Table) lookup(from, to string, on time.Time) (*big.Rat, time.Time, bool) {
+	direct, directDate, directOK := latest(t.rates[pair{from, to}], on)
+	inverse, inverseDate, inverseOK := latest(t.rates[pair{to, from}], on)
+
+	switch {
+	case directOK && (!inverseOK || !inverseDate.After(directDate)):
+		return new(big.Rat).Set(direct), directDate, true
+	case inverseOK:
+		return new(big.Rat).Inv(inverse), inverseDate, true
+	}
+	return nil, time.Time{}, false
+}
+
+func latest(list []datedRate, on time.Time) (*big.Rat, time.Time, bool) {
+	// first rate after on, the one before it is the latest usable
+	i := sort.Search(len(list), func(i int) bool { return list[i].date.After(on) })
+	if i == 0 {
+		return nil, time.Time{}, false
+	}
+	return list[i-1].rate, list[i-1].date, true
+}
+
+func convertMinor(minor int64, rate *big.Rat) (int64, error) {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(minor), rate)
+	num, den := product.Num(), product.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("%w: converted amount out of range", models.ErrInvalidMoney)
+	}
+	return quo.Int64(), nil
+}
+
+// day truncates t to its UTC calendar day, the granularity of rates.
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,82 @@
+package rates_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(rates.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func newTable(t *testing.T) *rates.Table {
+	t.Helper()
+	table, err := rates.NewTable([]models.ExchangeRate{
+		{Base: "EUR", Quote: "CHF", Date: "2024-01-02", Rate: "0.9312"},
+		{Base: "EUR", Quote: "CHF", Date: "2024-01-05", Rate: "0.9300"},
+		{Base: "EUR", Quote: "GBP", Date: "2024-01-02", Rate: "0.8664"},
+		{Base: "GBP", Quote: "EUR", Date: "2024-01-04", Rate: "1.16"},
+		{Base: "USD", Quote: "JPY", Date: "2024-01-02", Rate: "141.5"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build table: %s", err)
+	}
+	return table
+}
+
+func TestTableConvert(t *testing.T) {
+	table := newTable(t)
+
+	tests := []struct {
+		name string
+		from models.Money
+		to   string
+		on   string
+		want int64
+	}{
+		{"same currency", models.NewMoney(1234, "EUR"), "EUR", "2020-01-01", 1234},
+		{"direct", models.NewMoney(10000, "EUR"), "CHF", "2024-01-02", 9312},
+		{"latest rate before the date", models.NewMoney(10000, "EUR"), "CHF", "2024-01-04", 9312},
+		{"newer rate", models.NewMoney(10000, "EUR"), "CHF", "2024-01-06", 9300},
+		{"inverted", models.NewMoney(9312, "CHF"), "EUR", "2024-01-03", 10000},
+		{"newer inverse wins", models.NewMoney(10000, "GBP"), "EUR", "2024-01-04", 11600},
+		{"triangulated", models.NewMoney(10000, "GBP"), "CHF", "2024-01-02", 10748},
+		{"rounds half away from zero", models.NewMoney(-5, "EUR"), "GBP", "2024-01-02", -4},
+	}
+
+	for _, tt := range tests {
+		got, err := table.Convert(tt.from, tt.to, date(tt.on))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		if got.Minor != tt.want || got.Currency != tt.to {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.want, tt.to, got.Minor, got.Currency)
+		}
+	}
+}
+
+func TestTableConvert_NoRate(t *testing.T) {
+	table := newTable(t)
+
+	for _, tt := range []struct {
+		from, to, on string
+	}{
+		{"EUR", "CHF", "2024-01-01"},
+		{"EUR", "JPY", "2024-01-02"},
+		{"SEK", "EUR", "2024-01-02"},
+	} {
+		_, err := table.Convert(models.NewMoney(100, tt.from), tt.to, date(tt.on))
+		if !errors.Is(err, rates.ErrNoRate) {
+			t.Errorf("%s to %s on %s: expected ErrNoRate, got %v", tt.from, tt.to, tt.on, err)
+		}
+	}
+}
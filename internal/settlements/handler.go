@@ -1,9 +1,17 @@
 package settlements
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/IvanLouren/GoSplit/internal/allocations"
+	"github.com/IvanLouren/GoSplit/internal/tags"
 	"github.com/IvanLouren/GoSplit/pkg/middleware"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
@@ -18,8 +26,12 @@ func NewHandler(service *Service) *Handler {
 }
 
 type CreateSettlementRequest struct {
-	PaidTo string  `json:"paid_to"`
-	Amount float64 `json:"amount"`
+	PaidTo string       `json:"paid_to"`
+	Amount models.Money `json:"amount" swaggertype:"number"`
+	// Currency defaults to the group's currency.
+	Currency string `json:"currency" example:"EUR"`
+	// TagIDs are the group's tags to put on the settlement.
+	TagIDs []string `json:"tag_ids"`
 }
 
 // CreateSettlement godoc
@@ -33,6 +45,9 @@ type CreateSettlementRequest struct {
 // @Success      201   {object}  models.Settlement
 // @Failure      400   {string}  string  "invalid request"
 // @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      422   {string}  string  "paid_by and paid_to must be members of the group"
 // @Failure      500   {string}  string  "internal error"
 // @Router       /api/groups/{id}/settlements [post]
 func (h *Handler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
@@ -51,13 +66,18 @@ func (h *Handler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.GetGroupRole(r).Can(models.PermissionRecordSettlement) {
+		http.Error(w, "you do not have permission to record settlements", http.StatusForbidden)
+		return
+	}
+
 	var req CreateSettlementRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		http.Error(w, "Amount must not be zero", http.StatusBadRequest)
 		return
 	}
@@ -73,7 +93,32 @@ func (h *Handler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	settlement, err := h.service.CreateSettlement(groupID, parsedID, parsedPaidTo, req.Amount)
+	if req.Currency != "" {
+		if req.Amount.Currency, err = models.ParseCurrency(req.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var tagIDs []uuid.UUID
+	for _, id := range req.TagIDs {
+		tagID, err := uuid.Parse(id)
+		if err != nil {
+			http.Error(w, "invalid tag ID in tag_ids", http.StatusBadRequest)
+			return
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	settlement, err := h.service.CreateSettlement(groupID, parsedID, parsedPaidTo, req.Amount, tagIDs)
+	if errors.Is(err, tags.ErrUnknownTag) || errors.Is(err, ErrSameUser) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrNotMember) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -89,11 +134,13 @@ func (h *Handler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
 // @Tags         settlements
 // @Produce      json
 // @Security     BearerAuth
-// @Param        id   path      string  true  "Group ID"
-// @Success      200  {array}   models.Settlement
-// @Failure      400  {string}  string  "invalid group ID"
-// @Failure      401  {string}  string  "unauthorized"
-// @Failure      500  {string}  string  "internal error"
+// @Param        id      path      string  true   "Group ID"
+// @Param        status  query     string  false  "Only settlements with this status"  Enums(pending, confirmed, rejected, cancelled)
+// @Success      200     {array}   models.Settlement
+// @Failure      400     {string}  string  "invalid group ID or status"
+// @Failure      401     {string}  string  "unauthorized"
+// @Failure      404     {string}  string  "group not found"
+// @Failure      500     {string}  string  "internal error"
 // @Router       /api/groups/{id}/settlements [get]
 func (h *Handler) GetSettlements(w http.ResponseWriter, r *http.Request) {
 	groupIDStr := r.PathValue("id")
@@ -103,7 +150,13 @@ func (h *Handler) GetSettlements(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	settlements, err := h.service.GetSettlements(groupID)
+	status := models.SettlementStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	settlements, err := h.service.GetSettlements(groupID, status)
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
@@ -116,3 +169,482 @@ func (h *Handler) GetSettlements(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(settlements)
 }
+
+// GetSettlement godoc
+// @Summary      Get a settlement
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        settlementId  path      string  true  "Settlement ID"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId} [get]
+func (h *Handler) GetSettlement(w http.ResponseWriter, r *http.Request) {
+	_, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+
+	settlement, err := h.service.GetSettlement(groupID, settlementID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+type UpdateSettlementRequest struct {
+	// PaidBy defaults to the settlement's current payer.
+	PaidBy string       `json:"paid_by"`
+	PaidTo string       `json:"paid_to"`
+	Amount models.Money `json:"amount" swaggertype:"number"`
+	// Currency defaults to the settlement's current currency.
+	Currency string `json:"currency" example:"EUR"`
+	// TagIDs replace the settlement's tags; leaving them out keeps them.
+	TagIDs []string `json:"tag_ids"`
+}
+
+// UpdateSettlement godoc
+// @Summary      Update a settlement
+// @Description  Corrects who paid whom how much, and the tags. Only the payer, the payee, owners and admins can edit a settlement. A change to the payer, payee or amount has to be confirmed by the payee again, unless they made it. Rejected and cancelled settlements can't be edited.
+// @Tags         settlements
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string                   true  "Group ID"
+// @Param        settlementId  path      string                   true  "Settlement ID"
+// @Param        body          body      UpdateSettlementRequest  true  "Updated settlement"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid request"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      409           {string}  string  "settlement is no longer pending"
+// @Failure      422           {string}  string  "paid_by and paid_to must be members of the group"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId} [put]
+func (h *Handler) UpdateSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotInvolved.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req UpdateSettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !req.Amount.IsPositive() {
+		http.Error(w, "amount must not be zero", http.StatusBadRequest)
+		return
+	}
+
+	var in SettlementInput
+	var err error
+	if in.PaidTo, err = uuid.Parse(req.PaidTo); err != nil {
+		http.Error(w, "invalid user ID in paid_to", http.StatusBadRequest)
+		return
+	}
+	if req.PaidBy != "" {
+		if in.PaidBy, err = uuid.Parse(req.PaidBy); err != nil {
+			http.Error(w, "invalid user ID in paid_by", http.StatusBadRequest)
+			return
+		}
+	}
+	in.Amount = req.Amount
+	if req.Currency != "" {
+		if in.Amount.Currency, err = models.ParseCurrency(req.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TagIDs != nil {
+		in.TagIDs = make([]uuid.UUID, 0, len(req.TagIDs))
+		for _, id := range req.TagIDs {
+			tagID, err := uuid.Parse(id)
+			if err != nil {
+				http.Error(w, "invalid tag ID in tag_ids", http.StatusBadRequest)
+				return
+			}
+			in.TagIDs = append(in.TagIDs, tagID)
+		}
+	}
+
+	settlement, err := h.service.UpdateSettlement(groupID, settlementID, userID, role.Can(models.PermissionEditAnyExpense), in)
+	if errors.Is(err, ErrNotInvolved) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrNotPending) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, tags.ErrUnknownTag) || errors.Is(err, ErrSameUser) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrNotMember) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+type AllocationRequest struct {
+	ExpenseID string       `json:"expense_id"`
+	Amount    models.Money `json:"amount" swaggertype:"number"`
+}
+
+type AllocateSettlementRequest struct {
+	// Allocations say how much of the settlement pays off which expense;
+	// leaving them out allocates it to the oldest debts first.
+	Allocations []AllocationRequest `json:"allocations"`
+}
+
+// AllocateSettlement godoc
+// @Summary      Allocate a settlement to expenses
+// @Description  Says which expenses the settlement pays off, replacing its current allocations. Each allocation must be to an expense in the settlement's currency on which the payer still owes the payee at least that much, and together they can't be more than the settlement. Leaving allocations out allocates it to the payer's oldest debts to the payee first, like a new settlement. Only the payer, the payee, owners and admins can allocate a settlement.
+// @Tags         settlements
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string                     true   "Group ID"
+// @Param        settlementId  path      string                     true   "Settlement ID"
+// @Param        body          body      AllocateSettlementRequest  false  "Allocations"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid request"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      422           {string}  string  "invalid allocation"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/allocations [put]
+func (h *Handler) AllocateSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotInvolved.Error(), http.StatusForbidden)
+		return
+	}
+
+	// without a body the settlement is allocated oldest debt first
+	var req AllocateSettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var explicit []models.Allocation
+	if req.Allocations != nil {
+		explicit = make([]models.Allocation, 0, len(req.Allocations))
+		for _, allocation := range req.Allocations {
+			expenseID, err := uuid.Parse(allocation.ExpenseID)
+			if err != nil {
+				http.Error(w, "invalid expense ID in allocations", http.StatusBadRequest)
+				return
+			}
+			explicit = append(explicit, models.Allocation{ExpenseID: expenseID, Amount: allocation.Amount})
+		}
+	}
+
+	settlement, err := h.service.AllocateSettlement(groupID, settlementID, userID, role.Can(models.PermissionEditAnyExpense), explicit)
+	if errors.Is(err, ErrNotInvolved) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, allocations.ErrInvalidAllocation) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// DeleteSettlement godoc
+// @Summary      Delete a settlement
+// @Description  Moves the settlement to the group's trash, from where it can be restored until it is purged. Only the payer, the payee, owners and admins can delete a settlement.
+// @Tags         settlements
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        settlementId  path      string  true  "Settlement ID"
+// @Success      204
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId} [delete]
+func (h *Handler) DeleteSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotInvolved.Error(), http.StatusForbidden)
+		return
+	}
+
+	err := h.service.DeleteSettlement(groupID, settlementID, userID, role.Can(models.PermissionEditAnyExpense))
+	if errors.Is(err, ErrNotInvolved) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreSettlement godoc
+// @Summary      Restore a deleted settlement
+// @Description  Takes the settlement out of the trash so it counts in balances again, with the same permissions as deleting it.
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        settlementId  path      string  true  "Settlement ID"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not in the trash"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/restore [post]
+func (h *Handler) RestoreSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !role.Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotInvolved.Error(), http.StatusForbidden)
+		return
+	}
+
+	settlement, err := h.service.RestoreSettlement(groupID, settlementID, userID, role.Can(models.PermissionEditAnyExpense))
+	if errors.Is(err, ErrNotInvolved) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not in the trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+type RejectSettlementRequest struct {
+	// Reason tells the payer why the settlement was rejected.
+	Reason string `json:"reason" example:"Nothing arrived in my account"`
+}
+
+// ConfirmSettlement godoc
+// @Summary      Confirm a settlement
+// @Description  Confirms that the settlement was received, so it counts in balances. Only the payee can confirm a pending settlement.
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        settlementId  path      string  true  "Settlement ID"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      409           {string}  string  "settlement is no longer pending"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/confirm [post]
+func (h *Handler) ConfirmSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+	if !middleware.GetGroupRole(r).Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotPayee.Error(), http.StatusForbidden)
+		return
+	}
+
+	settlement, err := h.service.ConfirmSettlement(groupID, settlementID, userID)
+	writeResolved(w, settlement, err)
+}
+
+// RejectSettlement godoc
+// @Summary      Reject a settlement
+// @Description  Records that the settlement was not received, and why. It never counts in balances. Only the payee can reject a pending settlement.
+// @Tags         settlements
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string                   true  "Group ID"
+// @Param        settlementId  path      string                   true  "Settlement ID"
+// @Param        body          body      RejectSettlementRequest  true  "Reason"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid request"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      409           {string}  string  "settlement is no longer pending"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/reject [post]
+func (h *Handler) RejectSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+	if !middleware.GetGroupRole(r).Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotPayee.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req RejectSettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		http.Error(w, "reason must not be empty", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		http.Error(w, fmt.Sprintf("reason must be at most %d characters", MaxReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	settlement, err := h.service.RejectSettlement(groupID, settlementID, userID, reason)
+	writeResolved(w, settlement, err)
+}
+
+// CancelSettlement godoc
+// @Summary      Cancel a settlement
+// @Description  Withdraws a pending settlement, which then never counts in balances. Only the payer can cancel it.
+// @Tags         settlements
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id            path      string  true  "Group ID"
+// @Param        settlementId  path      string  true  "Settlement ID"
+// @Success      200           {object}  models.Settlement
+// @Failure      400           {string}  string  "invalid ID"
+// @Failure      401           {string}  string  "unauthorized"
+// @Failure      403           {string}  string  "forbidden"
+// @Failure      404           {string}  string  "settlement not found"
+// @Failure      409           {string}  string  "settlement is no longer pending"
+// @Failure      500           {string}  string  "internal error"
+// @Router       /api/groups/{id}/settlements/{settlementId}/cancel [post]
+func (h *Handler) CancelSettlement(w http.ResponseWriter, r *http.Request) {
+	userID, groupID, settlementID, ok := settlementParams(w, r)
+	if !ok {
+		return
+	}
+	if !middleware.GetGroupRole(r).Can(models.PermissionRecordSettlement) {
+		http.Error(w, ErrNotPayer.Error(), http.StatusForbidden)
+		return
+	}
+
+	settlement, err := h.service.CancelSettlement(groupID, settlementID, userID)
+	writeResolved(w, settlement, err)
+}
+
+// writeResolved writes the response to confirming, rejecting or cancelling a
+// settlement.
+func writeResolved(w http.ResponseWriter, settlement models.Settlement, err error) {
+	if errors.Is(err, ErrNotPayee) || errors.Is(err, ErrNotPayer) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrNotPending) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "settlement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// settlementParams parses the caller and the group and settlement IDs in the
+// path. It writes the error response itself and returns false when one is
+// invalid.
+func settlementParams(w http.ResponseWriter, r *http.Request) (userID, groupID, settlementID uuid.UUID, ok bool) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	groupID, err = uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	settlementID, err = uuid.Parse(r.PathValue("settlementId"))
+	if err != nil {
+		http.Error(w, "invalid settlement ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return userID, groupID, settlementID, true
+}
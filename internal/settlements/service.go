@@ -2,11 +2,41 @@ package settlements
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/IvanLouren/GoSplit/internal/activity"
+	"github.com/IvanLouren/GoSplit/internal/allocations"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/database"
 	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
 
+const settlementColumns = `id, group_id, paid_by, paid_to, amount, currency, created_at, status, rejection_reason, resolved_at, resolved_by, deleted_at, deleted_by`
+
+var (
+	// ErrNotInvolved is returned when a member changes a settlement they
+	// neither paid nor received.
+	ErrNotInvolved = errors.New("only the payer, the payee or an admin can change this settlement")
+	// ErrSameUser is returned for a settlement someone pays to themselves.
+	ErrSameUser = errors.New("paid_by and paid_to must be different users")
+	// ErrNotMember is returned when the payer or the payee of a settlement is
+	// not a member of its group.
+	ErrNotMember = errors.New("paid_by and paid_to must be members of the group")
+)
+
+// SettlementInput is what UpdateSettlement sets on a settlement.
+type SettlementInput struct {
+	// PaidBy keeps the settlement's payer when it is uuid.Nil.
+	PaidBy uuid.UUID
+	PaidTo uuid.UUID
+	// Amount keeps the settlement's currency when it has none.
+	Amount models.Money
+	// TagIDs replace the settlement's tags; nil keeps them.
+	TagIDs []uuid.UUID
+}
+
 type Service struct {
 	db *sql.DB
 }
@@ -15,34 +45,423 @@ func NewService(db *sql.DB) *Service {
 	return &Service{db: db}
 }
 
-func (s *Service) CreateSettlement(groupID, paidBy, paidTo uuid.UUID, amount float64) (models.Settlement, error) {
-	var settlement models.Settlement
-	err := s.db.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount) VALUES
-					 ($1, $2, $3, $4) RETURNING id, group_id, paid_by, paid_to, amount, created_at`, groupID, paidBy, paidTo, amount).
-		Scan(&settlement.ID, &settlement.GroupID, &settlement.PaidBy, &settlement.PaidTo, &settlement.Amount, &settlement.CreatedAt)
+// CreateSettlement records the settlement in amount's currency, or the
+// group's currency when it has none, with the given tags. It is pending
+// until paidTo confirms it. It returns ErrSameUser, ErrNotMember, and
+// tags.ErrUnknownTag when a tag is not the group's.
+func (s *Service) CreateSettlement(groupID, paidBy, paidTo uuid.UUID, amount models.Money, tagIDs []uuid.UUID) (models.Settlement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	defer tx.Rollback()
+
+	settlement, err := create(tx, groupID, paidBy, paidTo, paidBy, amount, tagIDs)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	return settlement, tx.Commit()
+}
+
+// RecordTransfers records each of the transfers plan returns as a settlement
+// recorded by recordedBy, with the given tags, all or none of them. The
+// group is locked before plan runs, so two callers can't record the same
+// transfers twice. The settlements are pending except those paid to
+// recordedBy, which are confirmed. It returns the same errors as
+// CreateSettlement and whatever plan returns.
+func (s *Service) RecordTransfers(groupID, recordedBy uuid.UUID, tagIDs []uuid.UUID, plan func() ([]models.Transfer, error)) ([]models.Transfer, []models.Settlement, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM groups WHERE id = $1 FOR UPDATE`, groupID); err != nil {
+		return nil, nil, err
+	}
+	transfers, err := plan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settlements := []models.Settlement{}
+	for _, transfer := range transfers {
+		settlement, err := create(tx, groupID, transfer.From, transfer.To, recordedBy, transfer.Amount, tagIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		settlements = append(settlements, settlement)
+	}
+	return transfers, settlements, tx.Commit()
+}
+
+// create inserts a settlement recorded by recordedBy, pending unless it is
+// paid to them, and allocates it to the payer's oldest debts to the payee.
+func create(tx *sql.Tx, groupID, paidBy, paidTo, recordedBy uuid.UUID, amount models.Money, tagIDs []uuid.UUID) (models.Settlement, error) {
+	if err := checkParties(tx, groupID, paidBy, paidTo); err != nil {
 		return models.Settlement{}, err
 	}
 
+	status, resolvedBy := models.SettlementPending, uuid.NullUUID{}
+	if recordedBy == paidTo {
+		status, resolvedBy = models.SettlementConfirmed, uuid.NullUUID{UUID: recordedBy, Valid: true}
+	}
+	settlement, err := scanSettlement(tx.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency, status, resolved_at, resolved_by, pending_since) VALUES
+					 ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), (SELECT currency FROM groups WHERE id = $1)), $6, CASE WHEN $7::uuid IS NULL THEN NULL ELSE now() END, $7, CASE WHEN $7::uuid IS NULL THEN now() END) RETURNING `+settlementColumns,
+		groupID, paidBy, paidTo, amount, amount.Currency, status, resolvedBy))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	settlement.Tags, err = tags.SetSettlementTags(tx, groupID, settlement.ID, tagIDs)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	settlement.Allocations, err = allocations.Allocate(tx, settlement, nil)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if err := recordActivity(tx, recordedBy, models.VerbCreated, nil, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
 	return settlement, nil
 }
 
-func (s *Service) GetSettlements(groupID uuid.UUID) ([]models.Settlement, error) {
-	settlements, err := s.db.Query(`SELECT id, group_id, paid_by, paid_to, amount, created_at FROM settlements WHERE group_id = $1`, groupID)
+// GetSettlements returns the group's settlements with the given status, or
+// all of them when status is empty, leaving out the ones in the trash.
+func (s *Service) GetSettlements(groupID uuid.UUID, status models.SettlementStatus) ([]models.Settlement, error) {
+	return s.loadSettlements(`SELECT `+settlementColumns+` FROM settlements
+		WHERE group_id = $1 AND deleted_at IS NULL AND ($2::varchar = '' OR status = $2)`, groupID, status)
+}
+
+// GetSettlement returns the settlement with its tags and allocations. It returns
+// sql.ErrNoRows when the settlement is not in the group or is in the trash.
+func (s *Service) GetSettlement(groupID, settlementID uuid.UUID) (models.Settlement, error) {
+	settlements, err := s.loadSettlements(`SELECT `+settlementColumns+` FROM settlements
+		WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL`, settlementID, groupID)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if len(settlements) == 0 {
+		return models.Settlement{}, sql.ErrNoRows
+	}
+	return settlements[0], nil
+}
+
+// UpdateSettlement corrects the settlement's payer, payee, amount and tags.
+// Unless editAny is set, only the payer and the payee can edit it and anyone
+// else gets ErrNotInvolved. A change to who paid whom how much has to be
+// confirmed again, so it puts the settlement back to pending, unless the
+// payee made it, and allocates it again from scratch. Rejected and cancelled
+// settlements can't be edited back to life and return ErrNotPending. It
+// returns the same errors as CreateSettlement, and sql.ErrNoRows when the
+// settlement is not in the group or is in the trash.
+func (s *Service) UpdateSettlement(groupID, settlementID, userID uuid.UUID, editAny bool, in SettlementInput) (models.Settlement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkInvolved(tx, groupID, settlementID, userID, editAny, false); err != nil {
+		return models.Settlement{}, err
+	}
+	before, err := scanSettlement(tx.QueryRow(`SELECT `+settlementColumns+` FROM settlements WHERE id = $1`, settlementID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if before.Status != models.SettlementPending && before.Status != models.SettlementConfirmed {
+		return models.Settlement{}, ErrNotPending
+	}
+	if err := loadLinks(tx, &before); err != nil {
+		return models.Settlement{}, err
+	}
+
+	if in.PaidBy == uuid.Nil {
+		in.PaidBy = before.PaidBy
+	}
+	if in.Amount.Currency == "" {
+		in.Amount.Currency = before.Currency
+	}
+	if err := checkParties(tx, groupID, in.PaidBy, in.PaidTo); err != nil {
+		return models.Settlement{}, err
+	}
+	settlement, err := scanSettlement(tx.QueryRow(`UPDATE settlements SET paid_by = $1, paid_to = $2, amount = $3, currency = $4
+		WHERE id = $5 RETURNING `+settlementColumns, in.PaidBy, in.PaidTo, in.Amount, in.Amount.Currency, settlementID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if settlement.PaidBy != before.PaidBy || settlement.PaidTo != before.PaidTo || settlement.Amount != before.Amount {
+		status, resolvedBy := models.SettlementPending, uuid.NullUUID{}
+		if userID == settlement.PaidTo {
+			status, resolvedBy = models.SettlementConfirmed, uuid.NullUUID{UUID: userID, Valid: true}
+		}
+		settlement, err = scanSettlement(tx.QueryRow(`UPDATE settlements SET status = $1, rejection_reason = NULL,
+			resolved_at = CASE WHEN $2::uuid IS NULL THEN NULL ELSE now() END, resolved_by = $2,
+			pending_since = CASE WHEN $2::uuid IS NULL THEN now() END
+			WHERE id = $3 RETURNING `+settlementColumns, status, resolvedBy, settlementID))
+		if err != nil {
+			return models.Settlement{}, err
+		}
+	}
+
+	if in.TagIDs != nil {
+		settlement.Tags, err = tags.SetSettlementTags(tx, groupID, settlementID, in.TagIDs)
+	} else {
+		settlement.Tags = before.Tags
+	}
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if settlement.PaidBy != before.PaidBy || settlement.PaidTo != before.PaidTo || settlement.Amount != before.Amount {
+		settlement.Allocations, err = allocations.Allocate(tx, settlement, nil)
+	} else {
+		settlement.Allocations = before.Allocations
+	}
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if err := recordActivity(tx, userID, models.VerbUpdated, &before, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	return settlement, tx.Commit()
+}
+
+// AllocateSettlement replaces the settlement's allocations with explicit, or
+// allocates it to the payer's oldest debts to the payee when explicit is nil,
+// with the same permission as UpdateSettlement. It returns
+// allocations.ErrInvalidAllocation when explicit doesn't fit, and
+// sql.ErrNoRows when the settlement is not in the group or is in the trash.
+func (s *Service) AllocateSettlement(groupID, settlementID, userID uuid.UUID, editAny bool, explicit []models.Allocation) (models.Settlement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkInvolved(tx, groupID, settlementID, userID, editAny, false); err != nil {
+		return models.Settlement{}, err
+	}
+	settlement, err := scanSettlement(tx.QueryRow(`SELECT `+settlementColumns+` FROM settlements WHERE id = $1`, settlementID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if err := loadLinks(tx, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	settlement.Allocations, err = allocations.Allocate(tx, settlement, explicit)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	return settlement, tx.Commit()
+}
+
+// DeleteSettlement moves the settlement to the group's trash, where it no
+// longer counts in balances until it is restored or purged. Unless deleteAny
+// is set, only the payer and the payee can delete it and anyone else gets
+// ErrNotInvolved.
+func (s *Service) DeleteSettlement(groupID, settlementID, userID uuid.UUID, deleteAny bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkInvolved(tx, groupID, settlementID, userID, deleteAny, false); err != nil {
+		return err
+	}
+	settlement, err := scanSettlement(tx.QueryRow(`UPDATE settlements SET deleted_at = now(), deleted_by = $1 WHERE id = $2 RETURNING `+settlementColumns, userID, settlementID))
+	if err != nil {
+		return err
+	}
+	if err := recordActivity(tx, userID, models.VerbDeleted, &settlement, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDeletedSettlements returns the settlements in the group's trash, most
+// recently deleted first.
+func (s *Service) GetDeletedSettlements(groupID uuid.UUID) ([]models.Settlement, error) {
+	return s.loadSettlements(`SELECT `+settlementColumns+` FROM settlements
+		WHERE group_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id`, groupID)
+}
+
+// RestoreSettlement takes the settlement out of the trash, with the same
+// permission as DeleteSettlement. A pending settlement waits for its payee
+// afresh, so it isn't auto-confirmed the moment it comes back. It returns sql.ErrNoRows when the
+// settlement is not in the group's trash.
+func (s *Service) RestoreSettlement(groupID, settlementID, userID uuid.UUID, restoreAny bool) (models.Settlement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkInvolved(tx, groupID, settlementID, userID, restoreAny, true); err != nil {
+		return models.Settlement{}, err
+	}
+	settlement, err := scanSettlement(tx.QueryRow(`UPDATE settlements SET deleted_at = NULL, deleted_by = NULL,
+		pending_since = CASE WHEN status = $1 THEN now() ELSE pending_since END
+		WHERE id = $2 RETURNING `+settlementColumns, models.SettlementPending, settlementID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if err := loadLinks(tx, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	if err := recordActivity(tx, userID, models.VerbRestored, nil, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	return settlement, tx.Commit()
+}
+
+// PurgeDeleted permanently deletes the settlements that were moved to the
+// trash before cutoff, in every group, and returns how many there were.
+func (s *Service) PurgeDeleted(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM settlements WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// checkInvolved locks the settlement, in the trash when deleted is set, and
+// returns ErrNotInvolved unless anyone is set or userID paid or received it.
+func checkInvolved(tx *sql.Tx, groupID, settlementID, userID uuid.UUID, anyone, deleted bool) error {
+	var paidBy, paidTo uuid.UUID
+	err := tx.QueryRow(`SELECT paid_by, paid_to FROM settlements
+		WHERE id = $1 AND group_id = $2 AND (deleted_at IS NOT NULL) = $3 FOR UPDATE`,
+		settlementID, groupID, deleted).Scan(&paidBy, &paidTo)
+	if err != nil {
+		return err
+	}
+	if !anyone && userID != paidBy && userID != paidTo {
+		return ErrNotInvolved
+	}
+	return nil
+}
+
+// checkParties returns ErrSameUser or ErrNotMember unless paidBy and paidTo
+// are two members of the group. Their member rows are locked until tx ends,
+// so neither can leave the group while the settlement is written.
+func checkParties(tx *sql.Tx, groupID, paidBy, paidTo uuid.UUID) error {
+	if paidBy == paidTo {
+		return ErrSameUser
+	}
+	var members int
+	err := tx.QueryRow(`SELECT count(*) FROM (SELECT 1 FROM group_members WHERE group_id = $1 AND user_id IN ($2, $3) FOR SHARE) m`,
+		groupID, paidBy, paidTo).Scan(&members)
+	if err != nil {
+		return err
+	}
+	if members != 2 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// loadLinks sets the settlement's tags and allocations.
+func loadLinks(tx *sql.Tx, settlement *models.Settlement) error {
+	byID, err := tags.SettlementTags(tx, database.UUIDs{settlement.ID})
+	if err != nil {
+		return err
+	}
+	settlement.Tags = byID[settlement.ID]
+	if settlement.Tags == nil {
+		settlement.Tags = []models.Tag{}
+	}
+	allocated, err := allocations.SettlementAllocations(tx, database.UUIDs{settlement.ID})
+	if err != nil {
+		return err
+	}
+	settlement.Allocations = allocated[settlement.ID]
+	if settlement.Allocations == nil {
+		settlement.Allocations = []models.Allocation{}
+	}
+	return nil
+}
+
+// recordActivity logs verb on a settlement in its group's activity. before
+// or after is nil when the settlement wasn't there, or is in the trash, then.
+func recordActivity(tx *sql.Tx, actorID uuid.UUID, verb models.ActivityVerb, before, after *models.Settlement) error {
+	entry := activity.Entry{ActorID: actorID, Verb: verb, ObjectType: models.ActivitySettlement}
+	if before != nil {
+		entry.GroupID, entry.ObjectID, entry.Before = before.GroupID, before.ID, activity.Settlement(*before)
+	}
+	if after != nil {
+		entry.GroupID, entry.ObjectID, entry.After = after.GroupID, after.ID, activity.Settlement(*after)
+	}
+	return activity.Record(tx, entry)
+}
+
+// loadSettlements reads the settlements query selects, with their tags and
+// allocations.
+func (s *Service) loadSettlements(query string, args ...any) ([]models.Settlement, error) {
+	settlements, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer settlements.Close()
 
 	var result []models.Settlement
+	var ids database.UUIDs
 	for settlements.Next() {
-		var settlement models.Settlement
-		err = settlements.Scan(&settlement.ID, &settlement.GroupID, &settlement.PaidBy, &settlement.PaidTo, &settlement.Amount, &settlement.CreatedAt)
+		settlement, err := scanSettlement(settlements)
 		if err != nil {
 			return nil, err
 		}
 		result = append(result, settlement)
+		ids = append(ids, settlement.ID)
+	}
+	if err := settlements.Err(); err != nil {
+		return nil, err
 	}
 
+	bySettlement, err := tags.SettlementTags(s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := allocations.SettlementAllocations(s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].Tags = bySettlement[result[i].ID]
+		if result[i].Tags == nil {
+			result[i].Tags = []models.Tag{}
+		}
+		result[i].Allocations = allocated[result[i].ID]
+		if result[i].Allocations == nil {
+			result[i].Allocations = []models.Allocation{}
+		}
+	}
 	return result, nil
 }
+
+func scanSettlement(row interface{ Scan(...any) error }) (models.Settlement, error) {
+	var settlement models.Settlement
+	var rejectionReason sql.NullString
+	var resolvedAt, deletedAt sql.NullTime
+	var resolvedBy, deletedBy uuid.NullUUID
+	err := row.Scan(&settlement.ID, &settlement.GroupID, &settlement.PaidBy, &settlement.PaidTo, &settlement.Amount, &settlement.Currency, &settlement.CreatedAt,
+		&settlement.Status, &rejectionReason, &resolvedAt, &resolvedBy, &deletedAt, &deletedBy)
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	settlement.RejectionReason = rejectionReason.String
+	if resolvedAt.Valid {
+		settlement.ResolvedAt = &resolvedAt.Time
+	}
+	if resolvedBy.Valid {
+		settlement.ResolvedBy = &resolvedBy.UUID
+	}
+	if deletedAt.Valid {
+		settlement.DeletedAt = &deletedAt.Time
+	}
+	if deletedBy.Valid {
+		settlement.DeletedBy = &deletedBy.UUID
+	}
+	settlement.Amount.Currency = settlement.Currency
+	return settlement, nil
+}
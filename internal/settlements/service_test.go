@@ -3,12 +3,16 @@ package settlements_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
@@ -51,12 +55,30 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// addMembers adds the users to the group as members.
+func addMembers(t *testing.T, groupID any, userIDs ...any) {
+	t.Helper()
+	for _, userID := range userIDs {
+		if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id) VALUES ($1, $2)`, groupID, userID); err != nil {
+			t.Fatalf("failed to add member: %s", err)
+		}
 	}
-	_, err = db.Exec(string(migration))
-	return err
 }
 
 func TestCreateSettlement(t *testing.T) {
@@ -80,6 +102,7 @@ func TestCreateSettlement(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
 	}
+	addMembers(t, groupID, paidByID, paidToID)
 
 	parsedGroupID, err := uuid.Parse(groupID)
 	if err != nil {
@@ -97,7 +120,7 @@ func TestCreateSettlement(t *testing.T) {
 	}
 
 	service := settlements.NewService(testDB)
-	settlement, err := service.CreateSettlement(parsedGroupID, parsedPaidByID, parsedPaidToID, 45.00)
+	settlement, err := service.CreateSettlement(parsedGroupID, parsedPaidByID, parsedPaidToID, models.NewMoney(4500, ""), nil)
 	if err != nil {
 		t.Fatalf("expected no error, got: %s", err)
 	}
@@ -114,8 +137,21 @@ func TestCreateSettlement(t *testing.T) {
 	if settlement.PaidTo != parsedPaidToID {
 		t.Errorf("expected paidTo %s, got %s", parsedPaidToID, settlement.PaidTo)
 	}
-	if settlement.Amount != 45.00 {
-		t.Errorf("expected amount 45.00, got %f", settlement.Amount)
+	if settlement.Amount.Minor != 4500 {
+		t.Errorf("expected amount 45.00, got %s", settlement.Amount)
+	}
+	if settlement.Currency != "EUR" {
+		t.Errorf("expected the group's currency EUR, got %s", settlement.Currency)
+	}
+	if settlement.Status != models.SettlementPending || settlement.ResolvedAt != nil {
+		t.Errorf("expected a pending settlement, got %s", settlement.Status)
+	}
+
+	if _, err := service.CreateSettlement(parsedGroupID, parsedPaidByID, parsedPaidByID, models.NewMoney(4500, ""), nil); !errors.Is(err, settlements.ErrSameUser) {
+		t.Errorf("expected ErrSameUser paying oneself, got %v", err)
+	}
+	if _, err := service.CreateSettlement(parsedGroupID, parsedPaidByID, uuid.New(), models.NewMoney(4500, ""), nil); !errors.Is(err, settlements.ErrNotMember) {
+		t.Errorf("expected ErrNotMember paying a non-member, got %v", err)
 	}
 }
 
@@ -140,6 +176,7 @@ func TestGetSettlements(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to insert group: %s", err)
 	}
+	addMembers(t, groupID, paidByID, paidToID)
 
 	parsedGroupID, err := uuid.Parse(groupID)
 	if err != nil {
@@ -157,12 +194,12 @@ func TestGetSettlements(t *testing.T) {
 	}
 
 	service := settlements.NewService(testDB)
-	_, err = service.CreateSettlement(parsedGroupID, parsedPaidByID, parsedPaidToID, 45.00)
+	_, err = service.CreateSettlement(parsedGroupID, parsedPaidByID, parsedPaidToID, models.NewMoney(4500, ""), nil)
 	if err != nil {
 		t.Fatalf("failed to create settlement: %s", err)
 	}
 
-	result, err := service.GetSettlements(parsedGroupID)
+	result, err := service.GetSettlements(parsedGroupID, "")
 	if err != nil {
 		t.Fatalf("expected no error, got: %s", err)
 	}
@@ -170,10 +207,270 @@ func TestGetSettlements(t *testing.T) {
 	if len(result) == 0 {
 		t.Fatalf("expected at least 1 settlement, got 0")
 	}
-	if result[0].Amount != 45.00 {
-		t.Errorf("expected amount 45.00, got %f", result[0].Amount)
+	if result[0].Amount.Minor != 4500 {
+		t.Errorf("expected amount 45.00, got %s", result[0].Amount)
 	}
 	if result[0].GroupID != parsedGroupID {
 		t.Errorf("expected groupID %s, got %s", parsedGroupID, result[0].GroupID)
 	}
 }
+
+func TestSettlement_Trash(t *testing.T) {
+	var payer, payee, other, groupID uuid.UUID
+	for i, id := range []*uuid.UUID{&payer, &payee, &other} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			fmt.Sprintf("User %d", i+5), fmt.Sprintf("user%d@test.com", i+5), "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Flat", payer).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	addMembers(t, groupID, payer, payee)
+
+	service := settlements.NewService(testDB)
+	settlement, err := service.CreateSettlement(groupID, payer, payee, models.NewMoney(2500, "EUR"), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+
+	if err := service.DeleteSettlement(groupID, settlement.ID, other, false); !errors.Is(err, settlements.ErrNotInvolved) {
+		t.Errorf("expected ErrNotInvolved, got %v", err)
+	}
+	if err := service.DeleteSettlement(groupID, settlement.ID, payee, false); err != nil {
+		t.Fatalf("failed to delete settlement: %s", err)
+	}
+	list, err := service.GetSettlements(groupID, "")
+	if err != nil {
+		t.Fatalf("failed to get settlements: %s", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected no settlements after delete, got %d", len(list))
+	}
+	trash, err := service.GetDeletedSettlements(groupID)
+	if err != nil {
+		t.Fatalf("failed to get deleted settlements: %s", err)
+	}
+	if len(trash) != 1 || trash[0].DeletedBy == nil || *trash[0].DeletedBy != payee {
+		t.Fatalf("expected the settlement deleted by the payee in the trash, got %+v", trash)
+	}
+
+	restored, err := service.RestoreSettlement(groupID, settlement.ID, other, true)
+	if err != nil {
+		t.Fatalf("failed to restore settlement: %s", err)
+	}
+	if restored.DeletedAt != nil || restored.Amount.Minor != 2500 {
+		t.Errorf("expected the restored settlement, got %+v", restored)
+	}
+	if _, err := service.RestoreSettlement(groupID, settlement.ID, payer, false); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows restoring a settlement not in the trash, got %v", err)
+	}
+
+	if err := service.DeleteSettlement(groupID, settlement.ID, payer, false); err != nil {
+		t.Fatalf("failed to delete settlement: %s", err)
+	}
+	if _, err := service.PurgeDeleted(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to purge: %s", err)
+	}
+	if trash, _ := service.GetDeletedSettlements(groupID); len(trash) != 0 {
+		t.Errorf("expected the trash to be empty, got %d", len(trash))
+	}
+}
+
+func TestSettlement_Status(t *testing.T) {
+	var payer, payee, other, groupID uuid.UUID
+	for i, id := range []*uuid.UUID{&payer, &payee, &other} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			fmt.Sprintf("User %d", i+8), fmt.Sprintf("user%d@test.com", i+8), "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Flat", payer).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	addMembers(t, groupID, payer, payee)
+
+	service := settlements.NewService(testDB)
+	create := func() models.Settlement {
+		settlement, err := service.CreateSettlement(groupID, payer, payee, models.NewMoney(2500, "EUR"), nil)
+		if err != nil {
+			t.Fatalf("failed to create settlement: %s", err)
+		}
+		return settlement
+	}
+
+	confirmed := create()
+	if _, err := service.ConfirmSettlement(groupID, confirmed.ID, payer); !errors.Is(err, settlements.ErrNotPayee) {
+		t.Errorf("expected ErrNotPayee for the payer, got %v", err)
+	}
+	got, err := service.ConfirmSettlement(groupID, confirmed.ID, payee)
+	if err != nil {
+		t.Fatalf("failed to confirm settlement: %s", err)
+	}
+	if got.Status != models.SettlementConfirmed || got.ResolvedBy == nil || *got.ResolvedBy != payee || got.ResolvedAt == nil {
+		t.Errorf("expected the settlement confirmed by the payee, got %+v", got)
+	}
+	if _, err := service.RejectSettlement(groupID, confirmed.ID, payee, "changed my mind"); !errors.Is(err, settlements.ErrNotPending) {
+		t.Errorf("expected ErrNotPending, got %v", err)
+	}
+
+	rejected := create()
+	got, err = service.RejectSettlement(groupID, rejected.ID, payee, "nothing arrived")
+	if err != nil {
+		t.Fatalf("failed to reject settlement: %s", err)
+	}
+	if got.Status != models.SettlementRejected || got.RejectionReason != "nothing arrived" {
+		t.Errorf("expected the settlement rejected with its reason, got %+v", got)
+	}
+
+	cancelled := create()
+	if _, err := service.CancelSettlement(groupID, cancelled.ID, payee); !errors.Is(err, settlements.ErrNotPayer) {
+		t.Errorf("expected ErrNotPayer for the payee, got %v", err)
+	}
+	if got, err = service.CancelSettlement(groupID, cancelled.ID, payer); err != nil || got.Status != models.SettlementCancelled {
+		t.Errorf("expected the settlement cancelled, got %+v and %v", got, err)
+	}
+	if _, err := service.ConfirmSettlement(uuid.New(), cancelled.ID, payee); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows in another group, got %v", err)
+	}
+
+	// pending settlements are confirmed on their own once they are old enough
+	pending := create()
+	if n, err := service.ConfirmPending(pending.CreatedAt); err != nil || n != 0 {
+		t.Errorf("expected nothing old enough to confirm, got %d and %v", n, err)
+	}
+	if n, err := service.ConfirmPending(time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("expected 1 settlement confirmed, got %d and %v", n, err)
+	}
+	var actorID uuid.UUID
+	err = testDB.QueryRow(`SELECT actor_id FROM activity WHERE object_id = $1 AND verb = $2`, pending.ID, models.VerbConfirmed).Scan(&actorID)
+	if err != nil || actorID != payee {
+		t.Errorf("expected the automatic confirmation logged on behalf of the payee, got %s and %v", actorID, err)
+	}
+
+	for status, want := range map[models.SettlementStatus]int{
+		models.SettlementPending:   0,
+		models.SettlementConfirmed: 2,
+		models.SettlementRejected:  1,
+		models.SettlementCancelled: 1,
+		"":                         4,
+	} {
+		list, err := service.GetSettlements(groupID, status)
+		if err != nil {
+			t.Fatalf("failed to get settlements: %s", err)
+		}
+		if len(list) != want {
+			t.Errorf("expected %d %q settlements, got %d", want, status, len(list))
+		}
+	}
+	if list, _ := service.GetSettlements(groupID, models.SettlementConfirmed); len(list) == 2 && list[0].ResolvedBy != nil && list[1].ResolvedBy != nil {
+		t.Errorf("expected the automatically confirmed settlement to have no resolved_by, got %+v", list)
+	}
+
+	// editing a rejection doesn't bring it back for the payee to confirm
+	in := settlements.SettlementInput{PaidTo: payee, Amount: models.NewMoney(2000, "")}
+	if _, err := service.UpdateSettlement(groupID, rejected.ID, payer, false, in); !errors.Is(err, settlements.ErrNotPending) {
+		t.Errorf("expected ErrNotPending editing a rejected settlement, got %v", err)
+	}
+
+	// an old settlement edited back to pending waits from the edit on
+	edited := create()
+	_, err = testDB.Exec(`UPDATE settlements SET created_at = now() - interval '10 days', pending_since = now() - interval '10 days' WHERE id = $1`, edited.ID)
+	if err != nil {
+		t.Fatalf("failed to backdate settlement: %s", err)
+	}
+	if _, err := service.UpdateSettlement(groupID, edited.ID, payer, false, in); err != nil {
+		t.Fatalf("failed to update settlement: %s", err)
+	}
+	if n, err := service.ConfirmPending(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected the edited settlement to stay pending, got %d confirmed and %v", n, err)
+	}
+	if got, err := service.GetSettlement(groupID, edited.ID); err != nil || got.Status != models.SettlementPending {
+		t.Errorf("expected the edited settlement pending, got %+v and %v", got, err)
+	}
+}
+
+func TestUpdateSettlement(t *testing.T) {
+	var payer, payee, third, outsider, groupID uuid.UUID
+	for i, id := range []*uuid.UUID{&payer, &payee, &third, &outsider} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			fmt.Sprintf("User %d", i+11), fmt.Sprintf("user%d@test.com", i+11), "hashedpassword").Scan(id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Flat", payer).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	addMembers(t, groupID, payer, payee, third)
+
+	service := settlements.NewService(testDB)
+	settlement, err := service.CreateSettlement(groupID, payer, payee, models.NewMoney(2500, "EUR"), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+	if _, err := service.ConfirmSettlement(groupID, settlement.ID, payee); err != nil {
+		t.Fatalf("failed to confirm settlement: %s", err)
+	}
+
+	// the payer fixes a typo in the amount, which the payee has to confirm again
+	in := settlements.SettlementInput{PaidTo: payee, Amount: models.NewMoney(2000, "")}
+	if _, err := service.UpdateSettlement(groupID, settlement.ID, third, false, in); !errors.Is(err, settlements.ErrNotInvolved) {
+		t.Errorf("expected ErrNotInvolved for a bystander, got %v", err)
+	}
+	updated, err := service.UpdateSettlement(groupID, settlement.ID, payer, false, in)
+	if err != nil {
+		t.Fatalf("failed to update settlement: %s", err)
+	}
+	if updated.PaidBy != payer || updated.Amount != models.NewMoney(2000, "EUR") || updated.Status != models.SettlementPending || updated.ResolvedBy != nil {
+		t.Errorf("expected a pending 20.00 EUR settlement from the payer, got %+v", updated)
+	}
+
+	// the payee's own correction needs no confirmation
+	in.Amount = models.NewMoney(1800, "")
+	if updated, err = service.UpdateSettlement(groupID, settlement.ID, payee, false, in); err != nil || updated.Status != models.SettlementConfirmed {
+		t.Errorf("expected the payee's edit to be confirmed, got %+v and %v", updated, err)
+	}
+
+	// changing only the tags keeps the status
+	in.TagIDs = []uuid.UUID{}
+	if updated, err = service.UpdateSettlement(groupID, settlement.ID, third, true, in); err != nil || updated.Status != models.SettlementConfirmed {
+		t.Errorf("expected an admin's tag edit to keep the settlement confirmed, got %+v and %v", updated, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		in   settlements.SettlementInput
+		want error
+	}{
+		{"pays themselves", settlements.SettlementInput{PaidTo: payer, Amount: models.NewMoney(100, "")}, settlements.ErrSameUser},
+		{"pays an outsider", settlements.SettlementInput{PaidTo: outsider, Amount: models.NewMoney(100, "")}, settlements.ErrNotMember},
+		{"paid by an outsider", settlements.SettlementInput{PaidBy: outsider, PaidTo: payee, Amount: models.NewMoney(100, "")}, settlements.ErrNotMember},
+	} {
+		if _, err := service.UpdateSettlement(groupID, settlement.ID, payer, false, tt.in); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	got, err := service.GetSettlement(groupID, settlement.ID)
+	if err != nil {
+		t.Fatalf("failed to get settlement: %s", err)
+	}
+	if got.Amount.Minor != 1800 || got.Status != models.SettlementConfirmed {
+		t.Errorf("expected the last good edit to stick, got %+v", got)
+	}
+	if _, err := service.GetSettlement(uuid.New(), settlement.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows in another group, got %v", err)
+	}
+	if err := service.DeleteSettlement(groupID, settlement.ID, payer, false); err != nil {
+		t.Fatalf("failed to delete settlement: %s", err)
+	}
+	if _, err := service.UpdateSettlement(groupID, settlement.ID, payer, false, in); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows editing a settlement in the trash, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package settlements
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAutoConfirmDays is how long a settlement stays pending before it
+	// is confirmed on its own when SETTLEMENT_AUTO_CONFIRM_DAYS is not set.
+	DefaultAutoConfirmDays = 7
+	// MaxReasonLength is the longest reason a settlement can be rejected
+	// with, in characters.
+	MaxReasonLength = 500
+)
+
+var (
+	// ErrNotPayee is returned when someone other than the payee confirms or
+	// rejects a settlement.
+	ErrNotPayee = errors.New("only the payee can confirm or reject this settlement")
+	// ErrNotPayer is returned when someone other than the payer cancels a
+	// settlement.
+	ErrNotPayer = errors.New("only the payer can cancel this settlement")
+	// ErrNotPending is returned when a settlement was already confirmed,
+	// rejected or cancelled, or when a rejected or cancelled one is edited.
+	ErrNotPending = errors.New("settlement is no longer pending")
+)
+
+// AutoConfirmFromEnv returns how long settlements stay pending before they
+// are confirmed on their own, in days, from SETTLEMENT_AUTO_CONFIRM_DAYS.
+func AutoConfirmFromEnv() (int, error) {
+	value := os.Getenv("SETTLEMENT_AUTO_CONFIRM_DAYS")
+	if value == "" {
+		return DefaultAutoConfirmDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("SETTLEMENT_AUTO_CONFIRM_DAYS must be a positive number of days, got %q", value)
+	}
+	return days, nil
+}
+
+// ConfirmSettlement records that userID, the payee, received the settlement,
+// so it counts in balances from now on. It returns sql.ErrNoRows when the
+// settlement is not in the group, ErrNotPayee and ErrNotPending.
+func (s *Service) ConfirmSettlement(groupID, settlementID, userID uuid.UUID) (models.Settlement, error) {
+	return s.resolve(groupID, settlementID, userID, models.SettlementConfirmed, "")
+}
+
+// RejectSettlement records that userID, the payee, didn't receive the
+// settlement, and why, like ConfirmSettlement.
+func (s *Service) RejectSettlement(groupID, settlementID, userID uuid.UUID, reason string) (models.Settlement, error) {
+	return s.resolve(groupID, settlementID, userID, models.SettlementRejected, reason)
+}
+
+// CancelSettlement withdraws a pending settlement userID paid. It returns
+// sql.ErrNoRows when the settlement is not in the group, ErrNotPayer and
+// ErrNotPending.
+func (s *Service) CancelSettlement(groupID, settlementID, userID uuid.UUID) (models.Settlement, error) {
+	return s.resolve(groupID, settlementID, userID, models.SettlementCancelled, "")
+}
+
+var statusVerbs = map[models.SettlementStatus]models.ActivityVerb{
+	models.SettlementConfirmed: models.VerbConfirmed,
+	models.SettlementRejected:  models.VerbRejected,
+	models.SettlementCancelled: models.VerbCancelled,
+}
+
+// resolve moves a pending settlement to status on behalf of userID, who has
+// to be its payer to cancel it and its payee otherwise.
+func (s *Service) resolve(groupID, settlementID, userID uuid.UUID, status models.SettlementStatus, reason string) (models.Settlement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanSettlement(tx.QueryRow(`SELECT `+settlementColumns+` FROM settlements
+		WHERE id = $1 AND group_id = $2 AND deleted_at IS NULL FOR UPDATE`, settlementID, groupID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if status == models.SettlementCancelled && userID != before.PaidBy {
+		return models.Settlement{}, ErrNotPayer
+	}
+	if status != models.SettlementCancelled && userID != before.PaidTo {
+		return models.Settlement{}, ErrNotPayee
+	}
+	if before.Status != models.SettlementPending {
+		return models.Settlement{}, ErrNotPending
+	}
+
+	settlement, err := scanSettlement(tx.QueryRow(`UPDATE settlements
+		SET status = $1, rejection_reason = NULLIF($2, ''), resolved_at = now(), resolved_by = $3
+		WHERE id = $4 RETURNING `+settlementColumns, status, reason, userID, settlementID))
+	if err != nil {
+		return models.Settlement{}, err
+	}
+	if err := loadLinks(tx, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	if err := recordActivity(tx, userID, statusVerbs[status], &before, &settlement); err != nil {
+		return models.Settlement{}, err
+	}
+	return settlement, tx.Commit()
+}
+
+// ConfirmPending confirms the settlements that have been pending since
+// before cutoff, in every group, and returns how many there were. A
+// settlement edited or restored back to pending waits from then on.
+// Settlements in the trash are left pending. resolved_by stays NULL, which is
+// how an automatic confirmation is told apart from one by the payee, but each
+// is logged in its group's activity on behalf of the payee, since the
+// activity log only has members as actors.
+func (s *Service) ConfirmPending(cutoff time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`UPDATE settlements SET status = $1, resolved_at = now()
+		WHERE status = $2 AND pending_since < $3 AND deleted_at IS NULL
+		RETURNING `+settlementColumns,
+		models.SettlementConfirmed, models.SettlementPending, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var confirmed []models.Settlement
+	for rows.Next() {
+		settlement, err := scanSettlement(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		confirmed = append(confirmed, settlement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, settlement := range confirmed {
+		if err := loadLinks(tx, &settlement); err != nil {
+			return 0, err
+		}
+		before := settlement
+		before.Status, before.ResolvedAt = models.SettlementPending, nil
+		if err := recordActivity(tx, settlement.PaidTo, models.VerbConfirmed, &before, &settlement); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(confirmed)), tx.Commit()
+}
+
+// RunAutoConfirm confirms the settlements that have been pending for more
+// than days now and then every interval, for as long as the process runs.
+// Failures are logged and retried at the next interval.
+func (s *Service) RunAutoConfirm(days int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().AddDate(0, 0, -days)
+		confirmed, err := s.ConfirmPending(cutoff)
+		if err != nil {
+			log.Printf("settlements: auto-confirm failed: %v", err)
+		} else if confirmed > 0 {
+			log.Printf("settlements: confirmed %d settlements pending since before %s", confirmed, cutoff.Format(time.RFC3339))
+		}
+		<-ticker.C
+	}
+}
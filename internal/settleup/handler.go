@@ -0,0 +1,114 @@
+package settleup
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/IvanLouren/GoSplit/internal/rates"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type SettleUpRequest struct {
+	// DryRun returns the transfers without recording them.
+	DryRun bool `json:"dry_run"`
+	// OnlyMine keeps only the transfers the caller pays or receives.
+	OnlyMine bool `json:"only_mine"`
+	// TagID settles only the expenses and settlements with this tag.
+	TagID string `json:"tag_id"`
+}
+
+// SettleUp godoc
+// @Summary      Record the transfers that settle the group
+// @Description  Works out who pays whom in the group's debt mode, leaving out what pending settlements already cover, and records every transfer as a settlement in one transaction. Transfers paid to the caller are confirmed; the others wait for their payee. Members can settle up their own transfers with only_mine; settling the whole group takes an owner or admin. A dry run returns the plan without recording anything.
+// @Tags         settlements
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string           true   "Group ID"
+// @Param        body  body      SettleUpRequest  false  "Options"
+// @Success      200   {object}  models.SettleUp  "dry run"
+// @Success      201   {object}  models.SettleUp
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      409   {string}  string  "missing exchange rate"
+// @Failure      422   {string}  string  "paid_by and paid_to must be members of the group"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/settle-up [post]
+func (h *Handler) SettleUp(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	// every option can be left out, and so can the body
+	var req SettleUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := Options{OnlyMine: req.OnlyMine, DryRun: req.DryRun}
+	if req.TagID != "" {
+		if opts.TagID, err = uuid.Parse(req.TagID); err != nil {
+			http.Error(w, "invalid tag", http.StatusBadRequest)
+			return
+		}
+	}
+
+	role := middleware.GetGroupRole(r)
+	if !opts.DryRun && !role.Can(models.PermissionRecordSettlement) {
+		http.Error(w, "you do not have permission to record settlements", http.StatusForbidden)
+		return
+	}
+	if !opts.DryRun && !opts.OnlyMine && !role.Can(models.PermissionEditAnyExpense) {
+		http.Error(w, "only owners and admins can settle up the whole group; use only_mine", http.StatusForbidden)
+		return
+	}
+
+	result, err := h.service.SettleUp(groupID, userID, opts)
+	if errors.Is(err, tags.ErrUnknownTag) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rates.ErrNoRate) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, settlements.ErrNotMember) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.DryRun {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
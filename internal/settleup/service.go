@@ -0,0 +1,73 @@
+package settleup
+
+import (
+	"github.com/IvanLouren/GoSplit/internal/balances"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Service turns the transfers that settle a group into settlements. Working
+// out the transfers is up to balances and recording them up to settlements.
+type Service struct {
+	balances    *balances.Service
+	settlements *settlements.Service
+}
+
+func NewService(balances *balances.Service, settlements *settlements.Service) *Service {
+	return &Service{balances: balances, settlements: settlements}
+}
+
+// Options narrow down a settle-up.
+type Options struct {
+	// TagID settles only the expenses and settlements with this tag, and
+	// puts it on the settlements recorded. uuid.Nil settles everything.
+	TagID uuid.UUID
+	// OnlyMine keeps only the transfers the caller pays or receives.
+	OnlyMine bool
+	// DryRun returns the transfers without recording them.
+	DryRun bool
+}
+
+// SettleUp works out the transfers that settle the group in its debt mode,
+// leaving out what pending settlements already cover, and records each as a
+// settlement by userID in one transaction. The settlements are pending until
+// their payee confirms them, except those paid to userID. It returns the
+// errors of balances.Service.GetDebts and settlements.Service.CreateSettlement.
+func (s *Service) SettleUp(groupID, userID uuid.UUID, opts Options) (models.SettleUp, error) {
+	var result models.SettleUp
+	plan := func() ([]models.Transfer, error) {
+		debts, err := s.balances.GetOutstandingDebts(groupID, opts.TagID)
+		if err != nil {
+			return nil, err
+		}
+		result.Mode, result.Currency = debts.Mode, debts.Currency
+		if !opts.OnlyMine {
+			return debts.Transfers, nil
+		}
+		mine := []models.Transfer{}
+		for _, transfer := range debts.Transfers {
+			if transfer.From == userID || transfer.To == userID {
+				mine = append(mine, transfer)
+			}
+		}
+		return mine, nil
+	}
+
+	var err error
+	if opts.DryRun {
+		result.DryRun = true
+		result.Transfers, err = plan()
+		result.Settlements = []models.Settlement{}
+	} else {
+		var tagIDs []uuid.UUID
+		if opts.TagID != uuid.Nil {
+			tagIDs = []uuid.UUID{opts.TagID}
+		}
+		result.Transfers, result.Settlements, err = s.settlements.RecordTransfers(groupID, userID, tagIDs, plan)
+	}
+	if err != nil {
+		return models.SettleUp{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package settleup_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/balances"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/internal/settleup"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestSettleUp(t *testing.T) {
+	var ana, bruno, carla, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"ana@test.com", &ana}, {"bruno@test.com", &bruno}, {"carla@test.com", &carla}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Trip", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	for _, id := range []uuid.UUID{bruno, carla} {
+		if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, id); err != nil {
+			t.Fatalf("failed to add member: %s", err)
+		}
+	}
+
+	// Ana paid 90.00 for the three of them
+	_, err = expenses.NewService(testDB).CreateExpense(groupID, ana, expenses.ExpenseInput{
+		Description: "Cabin",
+		Amount:      models.NewMoney(9000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ana}, {UserID: bruno}, {UserID: carla}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+
+	balanceService := balances.NewService(testDB)
+	settlementService := settlements.NewService(testDB)
+	service := settleup.NewService(balanceService, settlementService)
+
+	plan, err := service.SettleUp(groupID, bruno, settleup.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("failed to plan: %s", err)
+	}
+	if !plan.DryRun || len(plan.Transfers) != 2 || len(plan.Settlements) != 0 || plan.Currency != "EUR" {
+		t.Fatalf("expected a dry run with 2 transfers, got %+v", plan)
+	}
+	if list, _ := settlementService.GetSettlements(groupID, ""); len(list) != 0 {
+		t.Fatalf("expected a dry run to record nothing, got %+v", list)
+	}
+
+	// Bruno pays his part: it waits for Ana to confirm it
+	mine, err := service.SettleUp(groupID, bruno, settleup.Options{OnlyMine: true})
+	if err != nil {
+		t.Fatalf("failed to settle up: %s", err)
+	}
+	if len(mine.Settlements) != 1 {
+		t.Fatalf("expected only Bruno's settlement, got %+v", mine.Settlements)
+	}
+	if s := mine.Settlements[0]; s.PaidBy != bruno || s.PaidTo != ana || s.Amount.Minor != 3000 || s.Status != models.SettlementPending {
+		t.Errorf("expected a pending 30.00 from Bruno to Ana, got %+v", s)
+	}
+
+	// what Bruno has on its way is not asked for again
+	plan, err = service.SettleUp(groupID, ana, settleup.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("failed to plan: %s", err)
+	}
+	if len(plan.Transfers) != 1 || plan.Transfers[0].From != carla {
+		t.Fatalf("expected only Carla's transfer left, got %+v", plan.Transfers)
+	}
+
+	// Ana records the rest; what is paid to her is confirmed
+	all, err := service.SettleUp(groupID, ana, settleup.Options{})
+	if err != nil {
+		t.Fatalf("failed to settle up: %s", err)
+	}
+	if len(all.Settlements) != 1 || all.Settlements[0].PaidBy != carla || all.Settlements[0].Status != models.SettlementConfirmed {
+		t.Fatalf("expected Carla's confirmed settlement, got %+v", all.Settlements)
+	}
+
+	result, err := balanceService.GetBalances(groupID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get balances: %s", err)
+	}
+	want := map[uuid.UUID]int64{ana: 3000, bruno: -3000, carla: 0}
+	for _, b := range result {
+		if b.Balance.Minor != want[b.UserID] {
+			t.Errorf("expected %d for %s, got %s", want[b.UserID], b.UserID, b.Balance)
+		}
+	}
+
+	again, err := service.SettleUp(groupID, ana, settleup.Options{})
+	if err != nil {
+		t.Fatalf("failed to settle up: %s", err)
+	}
+	if len(again.Transfers) != 0 || len(again.Settlements) != 0 {
+		t.Errorf("expected nothing left to settle, got %+v", again)
+	}
+}
@@ -0,0 +1,225 @@
+package tags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+type TagRequest struct {
+	Name string `json:"name" example:"lisbon-trip"`
+}
+
+// GetTags godoc
+// @Summary      List the tags of a group
+// @Tags         tags
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {array}   models.Tag
+// @Failure      400  {string}  string  "invalid group ID"
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      404  {string}  string  "group not found"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/groups/{id}/tags [get]
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.service.GetTags(groupID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTag godoc
+// @Summary      Add a tag to a group
+// @Description  Any member who can add expenses can add tags.
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string      true  "Group ID"
+// @Param        body  body      TagRequest  true  "Tag"
+// @Success      201   {object}  models.Tag
+// @Failure      400   {string}  string  "invalid request"
+// @Failure      401   {string}  string  "unauthorized"
+// @Failure      403   {string}  string  "forbidden"
+// @Failure      404   {string}  string  "group not found"
+// @Failure      409   {string}  string  "a tag with this name already exists"
+// @Failure      500   {string}  string  "internal error"
+// @Router       /api/groups/{id}/tags [post]
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionAddExpense) {
+		http.Error(w, "you do not have permission to add tags", http.StatusForbidden)
+		return
+	}
+
+	name, ok := tagName(w, r)
+	if !ok {
+		return
+	}
+
+	tag, err := h.service.CreateTag(groupID, name)
+	if errors.Is(err, ErrDuplicateTag) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// RenameTag godoc
+// @Summary      Rename a tag
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      string      true  "Group ID"
+// @Param        tagId  path      string      true  "Tag ID"
+// @Param        body   body      TagRequest  true  "Tag"
+// @Success      200    {object}  models.Tag
+// @Failure      400    {string}  string  "invalid request"
+// @Failure      401    {string}  string  "unauthorized"
+// @Failure      403    {string}  string  "forbidden"
+// @Failure      404    {string}  string  "tag not found"
+// @Failure      409    {string}  string  "a tag with this name already exists"
+// @Failure      500    {string}  string  "internal error"
+// @Router       /api/groups/{id}/tags/{tagId} [put]
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	tagID, err := uuid.Parse(r.PathValue("tagId"))
+	if err != nil {
+		http.Error(w, "invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage tags", http.StatusForbidden)
+		return
+	}
+
+	name, ok := tagName(w, r)
+	if !ok {
+		return
+	}
+
+	tag, err := h.service.RenameTag(groupID, tagID, name)
+	if errors.Is(err, ErrDuplicateTag) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// tagName reads and trims the name of a TagRequest. It writes the error
+// response itself and returns false when the request is invalid.
+func tagName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name must not be empty", http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+// DeleteTag godoc
+// @Summary      Delete a tag
+// @Description  The tag is removed from every expense and settlement that has it.
+// @Tags         tags
+// @Security     BearerAuth
+// @Param        id     path      string  true  "Group ID"
+// @Param        tagId  path      string  true  "Tag ID"
+// @Success      204
+// @Failure      400    {string}  string  "invalid ID"
+// @Failure      401    {string}  string  "unauthorized"
+// @Failure      403    {string}  string  "forbidden"
+// @Failure      404    {string}  string  "tag not found"
+// @Failure      500    {string}  string  "internal error"
+// @Router       /api/groups/{id}/tags/{tagId} [delete]
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	tagID, err := uuid.Parse(r.PathValue("tagId"))
+	if err != nil {
+		http.Error(w, "invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.GetGroupRole(r).Can(models.PermissionEditGroup) {
+		http.Error(w, "you do not have permission to manage tags", http.StatusForbidden)
+		return
+	}
+
+	err = h.service.DeleteTag(groupID, tagID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package tags
+
+import (
+	"database/sql"
+
+	"github.com/IvanLouren/GoSplit/pkg/database"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Querier is implemented by *sql.DB and *sql.Tx.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// SetExpenseTags replaces the tags of an expense and returns them sorted by
+// name. It returns ErrUnknownTag when any of tagIDs is not the group's.
+func SetExpenseTags(tx *sql.Tx, groupID, expenseID uuid.UUID, tagIDs []uuid.UUID) ([]models.Tag, error) {
+	return setTags(tx, "expense_tags", "expense_id", groupID, expenseID, tagIDs)
+}
+
+// SetSettlementTags replaces the tags of a settlement like SetExpenseTags.
+func SetSettlementTags(tx *sql.Tx, groupID, settlementID uuid.UUID, tagIDs []uuid.UUID) ([]models.Tag, error) {
+	return setTags(tx, "settlement_tags", "settlement_id", groupID, settlementID, tagIDs)
+}
+
+// ExpenseTags returns the tags of each of the expenses, sorted by name.
+// Expenses without tags are left out.
+func ExpenseTags(q Querier, expenseIDs database.UUIDs) (map[uuid.UUID][]models.Tag, error) {
+	return linkedTags(q, "expense_tags", "expense_id", expenseIDs)
+}
+
+// SettlementTags returns the tags of each of the settlements like
+// ExpenseTags.
+func SettlementTags(q Querier, settlementIDs database.UUIDs) (map[uuid.UUID][]models.Tag, error) {
+	return linkedTags(q, "settlement_tags", "settlement_id", settlementIDs)
+}
+
+// setTags links id in table to the tags, replacing its current ones. The
+// tags are locked until tx ends so they can't be deleted meanwhile.
+func setTags(tx *sql.Tx, table, column string, groupID, id uuid.UUID, tagIDs []uuid.UUID) ([]models.Tag, error) {
+	seen := make(map[uuid.UUID]bool, len(tagIDs))
+	ids := database.UUIDs{}
+	for _, tagID := range tagIDs {
+		if !seen[tagID] {
+			seen[tagID] = true
+			ids = append(ids, tagID)
+		}
+	}
+
+	rows, err := tx.Query(`SELECT `+tagColumns+` FROM tags WHERE group_id = $1 AND id = ANY($2::uuid[])
+		ORDER BY lower(name) FOR SHARE`, groupID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tags) != len(ids) {
+		return nil, ErrUnknownTag
+	}
+
+	if _, err := tx.Exec(`DELETE FROM `+table+` WHERE `+column+` = $1`, id); err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		_, err := tx.Exec(`INSERT INTO `+table+` (`+column+`, tag_id) SELECT $1, unnest($2::uuid[])`, id, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func linkedTags(q Querier, table, column string, ids database.UUIDs) (map[uuid.UUID][]models.Tag, error) {
+	rows, err := q.Query(`SELECT l.`+column+`, t.id, t.group_id, t.name, t.created_at
+		FROM `+table+` l
+		JOIN tags t ON t.id = l.tag_id
+		WHERE l.`+column+` = ANY($1::uuid[])
+		ORDER BY lower(t.name)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[uuid.UUID][]models.Tag)
+	for rows.Next() {
+		var id uuid.UUID
+		var tag models.Tag
+		if err := rows.Scan(&id, &tag.ID, &tag.GroupID, &tag.Name, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		result[id] = append(result[id], tag)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,91 @@
+package tags
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownTag is returned for tags that are not the group's.
+	ErrUnknownTag = errors.New("unknown tag")
+	// ErrDuplicateTag is returned when the group already has a tag with the
+	// name, ignoring case.
+	ErrDuplicateTag = errors.New("a tag with this name already exists")
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+const tagColumns = `id, group_id, name, created_at`
+
+// GetTags returns the group's tags sorted by name.
+func (s *Service) GetTags(groupID uuid.UUID) ([]models.Tag, error) {
+	rows, err := s.db.Query(`SELECT `+tagColumns+` FROM tags WHERE group_id = $1 ORDER BY lower(name)`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *Service) CreateTag(groupID uuid.UUID, name string) (models.Tag, error) {
+	tag, err := scanTag(s.db.QueryRow(`INSERT INTO tags (group_id, name) VALUES ($1, $2)
+		ON CONFLICT (group_id, lower(name)) DO NOTHING RETURNING `+tagColumns, groupID, name))
+	if err == sql.ErrNoRows {
+		return models.Tag{}, ErrDuplicateTag
+	}
+	return tag, err
+}
+
+// RenameTag renames the tag. It returns sql.ErrNoRows when the tag is not the
+// group's.
+func (s *Service) RenameTag(groupID, tagID uuid.UUID, name string) (models.Tag, error) {
+	var taken bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tags WHERE group_id = $1 AND lower(name) = lower($2) AND id <> $3)`,
+		groupID, name, tagID).Scan(&taken)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	if taken {
+		return models.Tag{}, ErrDuplicateTag
+	}
+	return scanTag(s.db.QueryRow(`UPDATE tags SET name = $1 WHERE id = $2 AND group_id = $3 RETURNING `+tagColumns, name, tagID, groupID))
+}
+
+// DeleteTag deletes the tag and removes it from every expense and settlement.
+func (s *Service) DeleteTag(groupID, tagID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM tags WHERE id = $1 AND group_id = $2`, tagID, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanTag(row interface{ Scan(...any) error }) (models.Tag, error) {
+	var tag models.Tag
+	err := row.Scan(&tag.ID, &tag.GroupID, &tag.Name, &tag.CreatedAt)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return tag, nil
+}
@@ -0,0 +1,119 @@
+package tags_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/internal/tags"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestTags(t *testing.T) {
+	var userID, groupID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+		"User", "user1@test.com", "hashedpassword").Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to insert user: %s", err)
+	}
+	err = testDB.QueryRow(`INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id`, "Friends", userID).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	service := tags.NewService(testDB)
+	trip, err := service.CreateTag(groupID, "lisbon-trip")
+	if err != nil {
+		t.Fatalf("failed to create tag: %s", err)
+	}
+	party, err := service.CreateTag(groupID, "bday-party")
+	if err != nil {
+		t.Fatalf("failed to create tag: %s", err)
+	}
+
+	// names are unique per group, ignoring case
+	if _, err := service.CreateTag(groupID, "Lisbon-Trip"); !errors.Is(err, tags.ErrDuplicateTag) {
+		t.Errorf("expected ErrDuplicateTag, got %v", err)
+	}
+	if _, err := service.RenameTag(groupID, party.ID, "LISBON-TRIP"); !errors.Is(err, tags.ErrDuplicateTag) {
+		t.Errorf("expected ErrDuplicateTag, got %v", err)
+	}
+	if _, err := service.RenameTag(groupID, party.ID, "Bday-Party"); err != nil {
+		t.Fatalf("failed to rename tag: %s", err)
+	}
+
+	list, err := service.GetTags(groupID)
+	if err != nil {
+		t.Fatalf("failed to get tags: %s", err)
+	}
+	if len(list) != 2 || list[0].Name != "Bday-Party" || list[1].ID != trip.ID {
+		t.Errorf("expected Bday-Party and lisbon-trip, got %+v", list)
+	}
+
+	if err := service.DeleteTag(groupID, trip.ID); err != nil {
+		t.Fatalf("failed to delete tag: %s", err)
+	}
+	if err := service.DeleteTag(groupID, trip.ID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package trash
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/attachments"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// DefaultRetentionDays is how long deleted items stay in the trash when
+// TRASH_RETENTION_DAYS is not set.
+const DefaultRetentionDays = 30
+
+// RetentionFromEnv returns how long deleted items stay in the trash, in days,
+// from TRASH_RETENTION_DAYS.
+func RetentionFromEnv() (int, error) {
+	value := os.Getenv("TRASH_RETENTION_DAYS")
+	if value == "" {
+		return DefaultRetentionDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("TRASH_RETENTION_DAYS must be a positive number of days, got %q", value)
+	}
+	return days, nil
+}
+
+// Service lists what was deleted in a group and purges it for good once it
+// has been in the trash longer than the retention period. Deleting and
+// restoring are up to the services that own each kind of item.
+type Service struct {
+	expenses      *expenses.Service
+	settlements   *settlements.Service
+	attachments   *attachments.Service
+	retentionDays int
+}
+
+func NewService(expenses *expenses.Service, settlements *settlements.Service, attachments *attachments.Service, retentionDays int) *Service {
+	return &Service{expenses: expenses, settlements: settlements, attachments: attachments, retentionDays: retentionDays}
+}
+
+// GetTrash returns the group's deleted expenses and settlements.
+func (s *Service) GetTrash(groupID uuid.UUID) (models.Trash, error) {
+	deletedExpenses, err := s.expenses.GetDeletedExpenses(groupID)
+	if err != nil {
+		return models.Trash{}, err
+	}
+	deletedSettlements, err := s.settlements.GetDeletedSettlements(groupID)
+	if err != nil {
+		return models.Trash{}, err
+	}
+
+	trash := models.Trash{Expenses: deletedExpenses, Settlements: deletedSettlements, RetentionDays: s.retentionDays}
+	if trash.Expenses == nil {
+		trash.Expenses = []models.ExpenseDetail{}
+	}
+	if trash.Settlements == nil {
+		trash.Settlements = []models.Settlement{}
+	}
+	return trash, nil
+}
+
+// Purge permanently deletes everything that was moved to the trash more than
+// the retention period before now, in every group.
+func (s *Service) Purge(now time.Time) error {
+	cutoff := now.AddDate(0, 0, -s.retentionDays)
+	// attachments first, so their blobs go too
+	files, err := s.attachments.PurgeDeleted(cutoff)
+	if err != nil {
+		return err
+	}
+	purgedExpenses, err := s.expenses.PurgeDeleted(cutoff)
+	if err != nil {
+		return err
+	}
+	purgedSettlements, err := s.settlements.PurgeDeleted(cutoff)
+	if err != nil {
+		return err
+	}
+	if purgedExpenses+purgedSettlements > 0 {
+		log.Printf("trash: purged %d expenses with %d attachments and %d settlements deleted before %s",
+			purgedExpenses, files, purgedSettlements, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// RunRetention purges the trash now and then every interval, for as long as
+// the process runs. Failures are logged and retried at the next interval.
+func (s *Service) RunRetention(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Purge(time.Now()); err != nil {
+			log.Printf("trash: purge failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
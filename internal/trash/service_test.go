@@ -0,0 +1,165 @@
+package trash_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IvanLouren/GoSplit/internal/attachments"
+	"github.com/IvanLouren/GoSplit/internal/expenses"
+	"github.com/IvanLouren/GoSplit/internal/settlements"
+	"github.com/IvanLouren/GoSplit/internal/trash"
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("gosplit_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		log.Fatalf("failed to start container: %s", err)
+	}
+	defer pgContainer.Terminate(ctx)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		log.Fatalf("failed to get connection string: %s", err)
+	}
+
+	testDB, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+	defer testDB.Close()
+
+	if err := runMigrations(testDB); err != nil {
+		log.Fatalf("Failed to run migrations: %s", err)
+	}
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func TestTrash(t *testing.T) {
+	var ana, bruno, groupID uuid.UUID
+	for _, u := range []struct {
+		email string
+		id    *uuid.UUID
+	}{{"ana@test.com", &ana}, {"bruno@test.com", &bruno}} {
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			u.email, u.email, "hashedpassword").Scan(u.id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	err := testDB.QueryRow(`WITH g AS (INSERT INTO groups (name, created_by) VALUES ($1, $2) RETURNING id, created_by)
+		INSERT INTO group_members (group_id, user_id, role) SELECT id, created_by, 'owner' FROM g RETURNING group_id`,
+		"Flat", ana).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+	if _, err := testDB.Exec(`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`, groupID, bruno); err != nil {
+		t.Fatalf("failed to add member: %s", err)
+	}
+
+	dir := t.TempDir()
+	expenseService := expenses.NewService(testDB)
+	settlementService := settlements.NewService(testDB)
+	attachmentService := attachments.NewService(testDB, attachments.NewLocalStore(dir), attachments.NewURLSigner("secret", attachments.URLTTL))
+	service := trash.NewService(expenseService, settlementService, attachmentService, 30)
+
+	expense, err := expenseService.CreateExpense(groupID, ana, expenses.ExpenseInput{
+		Description: "Internet",
+		Amount:      models.NewMoney(4000, ""),
+		SplitType:   models.SplitEqual,
+		Splits:      []expenses.SplitInput{{UserID: ana}, {UserID: bruno}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create expense: %s", err)
+	}
+	var receipt bytes.Buffer
+	if err := png.Encode(&receipt, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attachmentService.Upload(groupID, expense.ID, ana, "bill.png", receipt.Bytes()); err != nil {
+		t.Fatalf("failed to upload: %s", err)
+	}
+	settlement, err := settlementService.CreateSettlement(groupID, bruno, ana, models.NewMoney(2000, ""), nil)
+	if err != nil {
+		t.Fatalf("failed to create settlement: %s", err)
+	}
+
+	if err := expenseService.DeleteExpense(groupID, expense.ID, ana); err != nil {
+		t.Fatalf("failed to delete expense: %s", err)
+	}
+	if err := settlementService.DeleteSettlement(groupID, settlement.ID, bruno, false); err != nil {
+		t.Fatalf("failed to delete settlement: %s", err)
+	}
+
+	got, err := service.GetTrash(groupID)
+	if err != nil {
+		t.Fatalf("failed to get trash: %s", err)
+	}
+	if len(got.Expenses) != 1 || len(got.Settlements) != 1 || got.RetentionDays != 30 {
+		t.Fatalf("expected one expense and one settlement kept for 30 days, got %+v", got)
+	}
+
+	// nothing is older than the retention period yet
+	if err := service.Purge(time.Now()); err != nil {
+		t.Fatalf("failed to purge: %s", err)
+	}
+	if got, _ := service.GetTrash(groupID); len(got.Expenses) != 1 || len(got.Settlements) != 1 {
+		t.Errorf("expected the trash to be kept, got %+v", got)
+	}
+
+	if err := service.Purge(time.Now().AddDate(0, 0, 31)); err != nil {
+		t.Fatalf("failed to purge: %s", err)
+	}
+	got, err = service.GetTrash(groupID)
+	if err != nil {
+		t.Fatalf("failed to get trash: %s", err)
+	}
+	if len(got.Expenses) != 0 || len(got.Settlements) != 0 {
+		t.Errorf("expected an empty trash, got %+v", got)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, groupID.String(), expense.ID.String()))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected the attachment blobs to be purged, got %d files (%v)", len(entries), err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/IvanLouren/GoSplit/pkg/middleware"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 )
 
@@ -100,3 +101,45 @@ func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedUser)
 }
+
+// GetSummary godoc
+// @Summary      Get current user's summary across all groups
+// @Description  Net balance per currency, balances with each other user, groups with outstanding balances and recent expenses and settlements. Amounts are not converted between currencies.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.UserSummary
+// @Failure      401  {string}  string  "unauthorized"
+// @Failure      500  {string}  string  "internal error"
+// @Router       /api/users/me/summary [get]
+func (h *Handler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	userIDStr := middleware.GetUserID(r)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := h.service.GetSummary(userID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if summary.Totals == nil {
+		summary.Totals = []models.CurrencyBalance{}
+	}
+	if summary.Counterparts == nil {
+		summary.Counterparts = []models.CounterpartBalance{}
+	}
+	if summary.OutstandingGroups == nil {
+		summary.OutstandingGroups = []models.GroupBalance{}
+	}
+	if summary.RecentActivity == nil {
+		summary.RecentActivity = []models.ActivityItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/IvanLouren/GoSplit/internal/balances"
 	"github.com/IvanLouren/GoSplit/internal/users"
+	"github.com/IvanLouren/GoSplit/pkg/models"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
@@ -52,12 +55,20 @@ func TestMain(m *testing.M) {
 }
 
 func runMigrations(db *sql.DB) error {
-	migration, err := os.ReadFile("../../migrations/001_init.sql")
+	files, err := filepath.Glob("../../migrations/*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration: %w", err)
+		return fmt.Errorf("failed to list migrations: %w", err)
 	}
-	_, err = db.Exec(string(migration))
-	return err
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", file, err)
+		}
+	}
+	return nil
 }
 
 func TestGetMe(t *testing.T) {
@@ -123,3 +134,184 @@ func TestUpdateMe(t *testing.T) {
 		t.Errorf("expected email unchanged, got %s", updated.Email)
 	}
 }
+
+func TestGetSummary(t *testing.T) {
+	insertUser := func(name, email string) uuid.UUID {
+		var id uuid.UUID
+		err := testDB.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+			name, email, "hashedpassword").Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+		return id
+	}
+	insertGroup := func(name, currency string, createdBy uuid.UUID) uuid.UUID {
+		var id uuid.UUID
+		err := testDB.QueryRow(`INSERT INTO groups (name, currency, created_by) VALUES ($1, $2, $3) RETURNING id`,
+			name, currency, createdBy).Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to insert group: %s", err)
+		}
+		return id
+	}
+	insertExpense := func(groupID, paidBy uuid.UUID, amount, currency string, splits map[uuid.UUID]string) uuid.UUID {
+		var id uuid.UUID
+		err := testDB.QueryRow(`WITH e AS (INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, $5) RETURNING id, paid_by, amount)
+			INSERT INTO expense_payers (expense_id, user_id, amount) SELECT id, paid_by, amount FROM e RETURNING expense_id`,
+			groupID, paidBy, "Expense", amount, currency).Scan(&id)
+		if err != nil {
+			t.Fatalf("failed to insert expense: %s", err)
+		}
+		for userID, share := range splits {
+			_, err := testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`,
+				id, userID, share)
+			if err != nil {
+				t.Fatalf("failed to insert split: %s", err)
+			}
+		}
+		return id
+	}
+
+	me := insertUser("User 3", "user3@test.com")
+	other := insertUser("User 4", "user4@test.com")
+	third := insertUser("User 5", "user5@test.com")
+
+	lisbon := insertGroup("Trip to Lisbon", "EUR", me)
+	ski := insertGroup("Ski Weekend", "CHF", other)
+
+	// I paid 90 EUR for all three, other paid 60 CHF for the two of us and
+	// then paid me back their 30 EUR.
+	insertExpense(lisbon, me, "90.00", "EUR", map[uuid.UUID]string{me: "30.00", other: "30.00", third: "30.00"})
+	insertExpense(ski, other, "60.00", "CHF", map[uuid.UUID]string{me: "30.00", other: "30.00"})
+	var settlementID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO settlements (group_id, paid_by, paid_to, amount, currency) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		lisbon, other, me, "30.00", "EUR").Scan(&settlementID)
+	if err != nil {
+		t.Fatalf("failed to insert settlement: %s", err)
+	}
+
+	service := users.NewService(testDB)
+	summary, err := service.GetSummary(me)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+
+	if len(summary.Totals) != 2 {
+		t.Fatalf("expected totals in 2 currencies, got %d", len(summary.Totals))
+	}
+	if summary.Totals[0].Currency != "CHF" || summary.Totals[0].Balance.Minor != -3000 {
+		t.Errorf("expected CHF total -30.00, got %s %s", summary.Totals[0].Currency, summary.Totals[0].Balance)
+	}
+	if summary.Totals[1].Currency != "EUR" || summary.Totals[1].Balance.Minor != 3000 {
+		t.Errorf("expected EUR total 30.00, got %s %s", summary.Totals[1].Currency, summary.Totals[1].Balance)
+	}
+
+	if len(summary.OutstandingGroups) != 2 {
+		t.Fatalf("expected 2 outstanding groups, got %d", len(summary.OutstandingGroups))
+	}
+	if summary.OutstandingGroups[0].GroupID != ski || summary.OutstandingGroups[1].GroupID != lisbon {
+		t.Errorf("expected outstanding groups [ski, lisbon], got %+v", summary.OutstandingGroups)
+	}
+
+	// other's 30 EUR split is settled, so only their CHF debt remains.
+	if len(summary.Counterparts) != 2 {
+		t.Fatalf("expected 2 counterpart balances, got %+v", summary.Counterparts)
+	}
+	if c := summary.Counterparts[0]; c.UserID != other || c.Currency != "CHF" || c.Balance.Minor != -3000 {
+		t.Errorf("expected to owe User 4 30.00 CHF, got %+v", c)
+	}
+	if c := summary.Counterparts[1]; c.UserID != third || c.Currency != "EUR" || c.Balance.Minor != 3000 {
+		t.Errorf("expected User 5 to owe 30.00 EUR, got %+v", c)
+	}
+
+	if len(summary.RecentActivity) != 3 {
+		t.Fatalf("expected 3 activity items, got %d", len(summary.RecentActivity))
+	}
+	latest := summary.RecentActivity[0]
+	if latest.Kind != models.ActivitySettlement || latest.ID != settlementID {
+		t.Errorf("expected the settlement first, got %s %s", latest.Kind, latest.ID)
+	}
+	if latest.PaidTo == nil || *latest.PaidTo != me {
+		t.Errorf("expected settlement paid to %s, got %v", me, latest.PaidTo)
+	}
+
+	empty, err := service.GetSummary(insertUser("User 6", "user6@test.com"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	if len(empty.Totals) != 0 || len(empty.RecentActivity) != 0 {
+		t.Errorf("expected an empty summary, got %+v", empty)
+	}
+}
+
+func TestGetSummary_SeveralPayers(t *testing.T) {
+	// other sorts before me, so leftover cents go to them
+	other := uuid.MustParse("00000000-0000-0000-0000-0000000000a1")
+	me := uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	third := uuid.MustParse("00000000-0000-0000-0000-0000000000c1")
+	for email, id := range map[string]uuid.UUID{"user7@test.com": me, "user8@test.com": other, "user9@test.com": third} {
+		_, err := testDB.Exec(`INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4)`,
+			id, email, email, "hashedpassword")
+		if err != nil {
+			t.Fatalf("failed to insert user: %s", err)
+		}
+	}
+	var groupID, expenseID uuid.UUID
+	err := testDB.QueryRow(`INSERT INTO groups (name, currency, created_by) VALUES ($1, $2, $3) RETURNING id`,
+		"Dinner", "EUR", me).Scan(&groupID)
+	if err != nil {
+		t.Fatalf("failed to insert group: %s", err)
+	}
+
+	// me and other paid 0.05 each of 0.10. Third's 0.03 can't be halved:
+	// other gets 0.02 and me 0.01, where rounding each half on its own would
+	// have third owe 0.04. Other owes me 0.01 of theirs and I owe them 0.02
+	// of mine.
+	err = testDB.QueryRow(`INSERT INTO expenses (group_id, paid_by, created_by, description, amount, currency) VALUES ($1, $2, $2, $3, $4, $5) RETURNING id`,
+		groupID, me, "Dinner", "0.10", "EUR").Scan(&expenseID)
+	if err != nil {
+		t.Fatalf("failed to insert expense: %s", err)
+	}
+	for _, payer := range []uuid.UUID{me, other} {
+		if _, err := testDB.Exec(`INSERT INTO expense_payers (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, payer, "0.05"); err != nil {
+			t.Fatalf("failed to insert payer: %s", err)
+		}
+	}
+	for userID, amount := range map[uuid.UUID]string{me: "0.04", other: "0.03", third: "0.03"} {
+		if _, err := testDB.Exec(`INSERT INTO expense_splits (expense_id, user_id, amount) VALUES ($1, $2, $3)`, expenseID, userID, amount); err != nil {
+			t.Fatalf("failed to insert split: %s", err)
+		}
+	}
+
+	summary, err := users.NewService(testDB).GetSummary(me)
+	if err != nil {
+		t.Fatalf("expected no error, got: %s", err)
+	}
+	pairs, err := balances.NewService(testDB).GetUserPairBalances(groupID, me, uuid.Nil)
+	if err != nil {
+		t.Fatalf("failed to get pair balances: %s", err)
+	}
+
+	// the dashboard shows what the group's pairwise view shows, to the cent
+	want := make(map[uuid.UUID]int64)
+	for _, pair := range pairs {
+		if !pair.Balance.IsZero() {
+			want[pair.OtherUserID] = pair.Balance.Minor
+		}
+	}
+	got := make(map[uuid.UUID]int64)
+	for _, c := range summary.Counterparts {
+		got[c.UserID] = c.Balance.Minor
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected counterparts %v, got %v", want, got)
+	}
+	for userID, balance := range want {
+		if got[userID] != balance {
+			t.Errorf("counterpart %s: expected %d, got %d", userID, balance, got[userID])
+		}
+	}
+	if got[third] != 1 || got[other] != -1 {
+		t.Errorf("expected third to owe me 0.01 and me to owe other 0.01, got %v", got)
+	}
+}
@@ -0,0 +1,194 @@
+package users
+
+import (
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+// RecentActivityLimit is how many expenses and settlements GetSummary returns.
+const RecentActivityLimit = 10
+
+// userEntries is every amount that moves userID's balance, one row per
+// expense paid, split owed and settlement paid or received, signed the same
+// way as the group balances: positive when userID is owed money.
+const userEntries = `
+	SELECT e.group_id, e.currency, p.amount
+	FROM expense_payers p JOIN expenses e ON e.id = p.expense_id
+	WHERE p.user_id = $1 AND e.deleted_at IS NULL
+	UNION ALL
+	SELECT e.group_id, e.currency, -s.amount
+	FROM expense_splits s JOIN expenses e ON e.id = s.expense_id
+	WHERE s.user_id = $1 AND e.deleted_at IS NULL
+	UNION ALL
+	SELECT group_id, currency, amount FROM settlements WHERE paid_by = $1 AND status = 'confirmed' AND deleted_at IS NULL
+	UNION ALL
+	SELECT group_id, currency, -amount FROM settlements WHERE paid_to = $1 AND status = 'confirmed' AND deleted_at IS NULL`
+
+// payerDebts is what every split of the expenses userID paid for or shares
+// owes each of their payers. Each split is divided over the payers in
+// proportion to what they paid the way Money.Allocate does it, the leftover
+// cents going to the largest remainders and ties to the lower payer ID, so
+// the amounts match the group's pairwise balances.
+const payerDebts = `
+	SELECT expense_id, currency, debtor_id, creditor_id,
+		((quotient + CASE WHEN ROW_NUMBER() OVER (PARTITION BY expense_id, debtor_id ORDER BY weight > 0 DESC, remainder DESC, creditor_id)
+			<= split - SUM(quotient) OVER (PARTITION BY expense_id, debtor_id) THEN 1 ELSE 0 END) / 100)::DECIMAL(12,2) AS owed
+	FROM (
+		SELECT e.id AS expense_id, e.currency, s.user_id AS debtor_id, p.user_id AS creditor_id,
+			s.amount * 100 AS split, p.amount * 100 AS weight,
+			div(s.amount * p.amount * 10000, SUM(p.amount * 100) OVER w) AS quotient,
+			mod(s.amount * p.amount * 10000, SUM(p.amount * 100) OVER w) AS remainder
+		FROM expenses e
+		JOIN expense_splits s ON s.expense_id = e.id
+		JOIN expense_payers p ON p.expense_id = e.id
+		WHERE e.deleted_at IS NULL AND e.id IN (
+			SELECT expense_id FROM expense_payers WHERE user_id = $1
+			UNION SELECT expense_id FROM expense_splits WHERE user_id = $1)
+		WINDOW w AS (PARTITION BY e.id, s.user_id)
+	) shares`
+
+// counterpartEntries is the same as userEntries, but attributed to the other
+// user on each side: splits owed to userID by others, splits userID owes
+// other payers, and settlements between userID and someone else.
+const counterpartEntries = `
+	SELECT debtor_id AS other_id, currency, owed AS amount
+	FROM (` + payerDebts + `) d
+	WHERE creditor_id = $1 AND debtor_id <> $1
+	UNION ALL
+	SELECT creditor_id, currency, -owed
+	FROM (` + payerDebts + `) d
+	WHERE debtor_id = $1 AND creditor_id <> $1
+	UNION ALL
+	SELECT paid_to, currency, amount FROM settlements WHERE paid_by = $1 AND paid_to <> $1 AND status = 'confirmed' AND deleted_at IS NULL
+	UNION ALL
+	SELECT paid_by, currency, -amount FROM settlements WHERE paid_to = $1 AND paid_by <> $1 AND status = 'confirmed' AND deleted_at IS NULL`
+
+// GetSummary aggregates userID's position across all of their groups. Each
+// section is a single query, so the cost does not grow with the number of
+// groups the user belongs to.
+func (s *Service) GetSummary(userID uuid.UUID) (*models.UserSummary, error) {
+	summary := &models.UserSummary{UserID: userID}
+
+	groups, err := s.groupBalances(userID)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]int)
+	for _, g := range groups {
+		i, ok := totals[g.Currency]
+		if !ok {
+			i = len(summary.Totals)
+			totals[g.Currency] = i
+			summary.Totals = append(summary.Totals, models.CurrencyBalance{
+				Currency: g.Currency,
+				Balance:  models.NewMoney(0, g.Currency),
+			})
+		}
+		summary.Totals[i].Balance = summary.Totals[i].Balance.Add(g.Balance)
+		if !g.Balance.IsZero() {
+			summary.OutstandingGroups = append(summary.OutstandingGroups, g)
+		}
+	}
+
+	if summary.Counterparts, err = s.counterpartBalances(userID); err != nil {
+		return nil, err
+	}
+	if summary.RecentActivity, err = s.recentActivity(userID, RecentActivityLimit); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// groupBalances returns userID's balance in every group and currency they
+// have entries in, ordered by currency so the totals come out sorted.
+func (s *Service) groupBalances(userID uuid.UUID) ([]models.GroupBalance, error) {
+	rows, err := s.db.Query(`
+		SELECT g.id, g.name, x.currency, SUM(x.amount)
+		FROM (`+userEntries+`) x
+		JOIN groups g ON g.id = x.group_id
+		GROUP BY g.id, g.name, x.currency
+		ORDER BY x.currency, g.name, g.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.GroupBalance
+	for rows.Next() {
+		var b models.GroupBalance
+		if err := rows.Scan(&b.GroupID, &b.GroupName, &b.Currency, &b.Balance); err != nil {
+			return nil, err
+		}
+		b.Balance.Currency = b.Currency
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+func (s *Service) counterpartBalances(userID uuid.UUID) ([]models.CounterpartBalance, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.name, x.currency, SUM(x.amount)
+		FROM (`+counterpartEntries+`) x
+		JOIN users u ON u.id = x.other_id
+		GROUP BY u.id, u.name, x.currency
+		HAVING SUM(x.amount) <> 0
+		ORDER BY u.name, u.id, x.currency`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.CounterpartBalance
+	for rows.Next() {
+		var b models.CounterpartBalance
+		if err := rows.Scan(&b.UserID, &b.Name, &b.Currency, &b.Balance); err != nil {
+			return nil, err
+		}
+		b.Balance.Currency = b.Currency
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+func (s *Service) recentActivity(userID uuid.UUID, limit int) ([]models.ActivityItem, error) {
+	rows, err := s.db.Query(`
+		SELECT 'expense', e.id, e.group_id, g.name, e.description, e.paid_by, NULL::uuid,
+			e.amount, e.currency, e.created_at
+		FROM expenses e JOIN groups g ON g.id = e.group_id
+		WHERE e.deleted_at IS NULL
+			AND (EXISTS (SELECT 1 FROM expense_payers p WHERE p.expense_id = e.id AND p.user_id = $1)
+				OR EXISTS (SELECT 1 FROM expense_splits s WHERE s.expense_id = e.id AND s.user_id = $1))
+		UNION ALL
+		SELECT 'settlement', st.id, st.group_id, g.name, '', st.paid_by, st.paid_to,
+			st.amount, st.currency, st.created_at
+		FROM settlements st JOIN groups g ON g.id = st.group_id
+		WHERE st.deleted_at IS NULL AND (st.paid_by = $1 OR st.paid_to = $1)
+		ORDER BY created_at DESC, id
+		LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ActivityItem
+	for rows.Next() {
+		var item models.ActivityItem
+		var paidTo uuid.NullUUID
+		if err := rows.Scan(&item.Kind, &item.ID, &item.GroupID, &item.GroupName, &item.Description,
+			&item.PaidBy, &paidTo, &item.Amount, &item.Currency, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		if paidTo.Valid {
+			item.PaidTo = &paidTo.UUID
+		}
+		item.Amount.Currency = item.Currency
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,21 @@
+-- Members can only appear once per group
+DELETE FROM group_members a
+USING group_members b
+WHERE a.group_id = b.group_id
+  AND a.user_id = b.user_id
+  AND (a.joined_at, a.id) > (b.joined_at, b.id);
+
+CREATE UNIQUE INDEX group_members_group_user_idx ON group_members (group_id, user_id);
+
+ALTER TABLE group_members
+    ADD COLUMN role VARCHAR NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'admin', 'member', 'viewer'));
+
+-- The creator of an existing group becomes its owner
+UPDATE group_members gm
+SET role = 'owner'
+FROM groups g
+WHERE g.id = gm.group_id AND g.created_by = gm.user_id;
+
+-- Exactly one owner per group is enforced by the service; at most one by the schema
+CREATE UNIQUE INDEX group_members_owner_idx ON group_members (group_id) WHERE role = 'owner';
@@ -0,0 +1,8 @@
+-- How the expense was divided, so it can be edited again in the same mode
+ALTER TABLE expenses
+    ADD COLUMN split_type VARCHAR NOT NULL DEFAULT 'exact'
+    CHECK (split_type IN ('exact', 'equal', 'percentage', 'shares', 'adjustment'));
+
+-- The participant's input for the strategy: a percentage, a number of shares
+-- or the fixed amount of an adjustment split. NULL when the strategy has none.
+ALTER TABLE expense_splits ADD COLUMN share DECIMAL(12,2);
@@ -0,0 +1,25 @@
+-- Base currency of the group; balances are reported in it
+ALTER TABLE groups ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'EUR';
+
+-- Existing expenses and settlements were recorded in the group's currency
+ALTER TABLE expenses ADD COLUMN currency CHAR(3);
+UPDATE expenses SET currency = groups.currency FROM groups WHERE groups.id = expenses.group_id;
+ALTER TABLE expenses ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE settlements ADD COLUMN currency CHAR(3);
+UPDATE settlements SET currency = groups.currency FROM groups WHERE groups.id = settlements.group_id;
+ALTER TABLE settlements ALTER COLUMN currency SET NOT NULL;
+
+-- rate = units of quote for one unit of base on rate_date, e.g. EUR/CHF 0.9312
+CREATE TABLE exchange_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    base CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    rate_date DATE NOT NULL,
+    rate NUMERIC(20,10) NOT NULL CHECK (rate > 0),
+    source VARCHAR NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'import')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (base <> quote),
+    UNIQUE (group_id, base, quote, rate_date)
+);
@@ -0,0 +1,4 @@
+-- How the group's "who pays whom" view is computed
+ALTER TABLE groups
+    ADD COLUMN debt_mode VARCHAR NOT NULL DEFAULT 'simplified'
+    CHECK (debt_mode IN ('simplified', 'pairwise'));
@@ -0,0 +1,6 @@
+-- Lookups by user for the cross-group summary
+CREATE INDEX IF NOT EXISTS expenses_paid_by_idx ON expenses (paid_by);
+CREATE INDEX IF NOT EXISTS expense_splits_user_id_idx ON expense_splits (user_id);
+CREATE INDEX IF NOT EXISTS expense_splits_expense_id_idx ON expense_splits (expense_id);
+CREATE INDEX IF NOT EXISTS settlements_paid_by_idx ON settlements (paid_by);
+CREATE INDEX IF NOT EXISTS settlements_paid_to_idx ON settlements (paid_to);
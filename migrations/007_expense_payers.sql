@@ -0,0 +1,15 @@
+-- Who paid an expense and how much each; the amounts add up to the expense
+-- amount. expenses.paid_by keeps the payer who paid the largest part.
+CREATE TABLE expense_payers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    UNIQUE (expense_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS expense_payers_user_id_idx ON expense_payers (user_id);
+
+-- every existing expense was paid in full by its paid_by
+INSERT INTO expense_payers (expense_id, user_id, amount)
+SELECT id, paid_by, amount FROM expenses;
@@ -0,0 +1,6 @@
+-- Who recorded the expense, which is no longer always one of its payers
+ALTER TABLE expenses ADD COLUMN created_by UUID REFERENCES users(id);
+
+UPDATE expenses SET created_by = paid_by;
+
+ALTER TABLE expenses ALTER COLUMN created_by SET NOT NULL;
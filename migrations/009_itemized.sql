@@ -0,0 +1,29 @@
+ALTER TABLE expenses DROP CONSTRAINT expenses_split_type_check;
+ALTER TABLE expenses ADD CONSTRAINT expenses_split_type_check
+    CHECK (split_type IN ('exact', 'equal', 'percentage', 'shares', 'adjustment', 'itemized'));
+
+-- The receipt behind an itemized expense: its line items plus the extras
+-- shared in proportion to each member's item subtotal
+CREATE TABLE expense_receipts (
+    expense_id UUID PRIMARY KEY REFERENCES expenses(id) ON DELETE CASCADE,
+    tax DECIMAL(10,2) NOT NULL DEFAULT 0,
+    service_charge DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tip DECIMAL(10,2) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE expense_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    expense_id UUID NOT NULL REFERENCES expense_receipts(expense_id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    description VARCHAR NOT NULL,
+    price DECIMAL(10,2) NOT NULL CHECK (price > 0),
+    UNIQUE (expense_id, position)
+);
+
+-- What each member assigned to an item pays for it
+CREATE TABLE expense_item_shares (
+    item_id UUID NOT NULL REFERENCES expense_items(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (item_id, user_id)
+);
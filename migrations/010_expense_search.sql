@@ -0,0 +1,7 @@
+-- Keyset pagination over a group's expenses, by date and by amount
+CREATE INDEX IF NOT EXISTS expenses_group_created_idx ON expenses (group_id, created_at, id);
+CREATE INDEX IF NOT EXISTS expenses_group_amount_idx ON expenses (group_id, amount, id);
+
+-- Case-insensitive substring search on descriptions
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS expenses_description_trgm_idx ON expenses USING gin (description gin_trgm_ops);
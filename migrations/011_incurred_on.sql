@@ -0,0 +1,16 @@
+-- The day an expense was incurred, which may be earlier than when it was
+-- recorded, and optionally the time zone it was incurred in
+ALTER TABLE expenses ADD COLUMN incurred_on DATE;
+
+UPDATE expenses SET incurred_on = created_at::date;
+
+ALTER TABLE expenses ALTER COLUMN incurred_on SET NOT NULL;
+ALTER TABLE expenses ALTER COLUMN incurred_on SET DEFAULT CURRENT_DATE;
+ALTER TABLE expenses ADD COLUMN time_zone VARCHAR;
+
+-- Expenses incurred on or before locked_until can no longer change
+ALTER TABLE groups ADD COLUMN locked_until DATE;
+
+-- Lists are ordered by the day incurred, then by when they were recorded
+DROP INDEX IF EXISTS expenses_group_created_idx;
+CREATE INDEX IF NOT EXISTS expenses_group_incurred_idx ON expenses (group_id, incurred_on, created_at, id);
@@ -0,0 +1,34 @@
+-- System categories have no group; every group can add its own
+CREATE TABLE categories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX categories_system_name_idx ON categories (lower(name)) WHERE group_id IS NULL;
+CREATE UNIQUE INDEX categories_group_name_idx ON categories (group_id, lower(name)) WHERE group_id IS NOT NULL;
+
+INSERT INTO categories (name) VALUES
+    ('Groceries'), ('Rent'), ('Utilities'), ('Travel'), ('Restaurants'),
+    ('Transport'), ('Entertainment'), ('Shopping'), ('Health'), ('Other');
+
+ALTER TABLE expenses ADD COLUMN category_id UUID REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS expenses_group_category_idx ON expenses (group_id, category_id);
+
+-- Rules assign a category to new and edited expenses; the lowest position
+-- whose conditions all hold wins
+CREATE TABLE category_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    pattern VARCHAR,
+    paid_by UUID REFERENCES users(id),
+    min_amount DECIMAL(10,2),
+    max_amount DECIMAL(10,2),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (pattern IS NOT NULL OR paid_by IS NOT NULL OR min_amount IS NOT NULL OR max_amount IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS category_rules_group_idx ON category_rules (group_id, position);
@@ -0,0 +1,25 @@
+-- Free-form labels a group puts on expenses and settlements, e.g. per sub-event
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX tags_group_name_idx ON tags (group_id, lower(name));
+
+CREATE TABLE expense_tags (
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (expense_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS expense_tags_tag_id_idx ON expense_tags (tag_id);
+
+CREATE TABLE settlement_tags (
+    settlement_id UUID NOT NULL REFERENCES settlements(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (settlement_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS settlement_tags_tag_id_idx ON settlement_tags (tag_id);
@@ -0,0 +1,25 @@
+-- Discussion threads on expenses and settlements; each comment is on exactly
+-- one of them
+CREATE TABLE comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    expense_id UUID REFERENCES expenses(id) ON DELETE CASCADE,
+    settlement_id UUID REFERENCES settlements(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id),
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    edited_at TIMESTAMPTZ,
+    CHECK ((expense_id IS NULL) <> (settlement_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS comments_expense_idx ON comments (expense_id, created_at, id) WHERE expense_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS comments_settlement_idx ON comments (settlement_id, created_at, id) WHERE settlement_id IS NOT NULL;
+
+-- Members a comment @mentions, for notifications
+CREATE TABLE comment_mentions (
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS comment_mentions_user_id_idx ON comment_mentions (user_id);
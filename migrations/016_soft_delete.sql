@@ -0,0 +1,9 @@
+-- Deleted expenses and settlements stay in the group's trash, out of lists
+-- and balances, until they are restored or purged after the retention period
+ALTER TABLE expenses ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE expenses ADD COLUMN deleted_by UUID REFERENCES users(id);
+ALTER TABLE settlements ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE settlements ADD COLUMN deleted_by UUID REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS expenses_deleted_idx ON expenses (group_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS settlements_deleted_idx ON settlements (group_id, deleted_at) WHERE deleted_at IS NOT NULL;
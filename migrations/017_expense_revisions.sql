@@ -0,0 +1,11 @@
+-- Every saved state of an expense, oldest revision first. Snapshots are never
+-- changed; reverting records a new revision copying an older one.
+CREATE TABLE expense_revisions (
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    snapshot JSONB NOT NULL,
+    edited_by UUID NOT NULL REFERENCES users(id),
+    reverted_from INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (expense_id, revision)
+);
@@ -0,0 +1,23 @@
+-- What members did in a group, newest first. object_id isn't a foreign key:
+-- entries outlive the expenses and settlements purged from the trash.
+CREATE TABLE activity (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id),
+    verb TEXT NOT NULL,
+    object_type TEXT NOT NULL,
+    object_id UUID NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS activity_group_idx ON activity (group_id, created_at DESC, id DESC);
+
+-- How far each member has read a group's activity
+CREATE TABLE activity_reads (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    read_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, group_id)
+);
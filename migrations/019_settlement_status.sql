@@ -0,0 +1,10 @@
+-- A settlement only counts in balances once its payee confirms it. Settlements
+-- recorded before this are taken as confirmed.
+ALTER TABLE settlements
+    ADD COLUMN status VARCHAR NOT NULL DEFAULT 'confirmed'
+    CHECK (status IN ('pending', 'confirmed', 'rejected', 'cancelled'));
+ALTER TABLE settlements ADD COLUMN rejection_reason TEXT;
+ALTER TABLE settlements ADD COLUMN resolved_at TIMESTAMPTZ;
+ALTER TABLE settlements ADD COLUMN resolved_by UUID REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS settlements_pending_idx ON settlements (created_at) WHERE status = 'pending';
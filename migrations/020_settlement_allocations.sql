@@ -0,0 +1,10 @@
+-- What part of a settlement pays off the payer's share of each expense the
+-- payee paid, in the settlement's currency
+CREATE TABLE settlement_allocations (
+    settlement_id UUID NOT NULL REFERENCES settlements(id) ON DELETE CASCADE,
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    PRIMARY KEY (settlement_id, expense_id)
+);
+
+CREATE INDEX IF NOT EXISTS settlement_allocations_expense_idx ON settlement_allocations (expense_id);
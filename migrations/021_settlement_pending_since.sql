@@ -0,0 +1,8 @@
+-- When a settlement last became pending. Editing who paid whom how much, or
+-- restoring it from the trash, makes it pending again, so auto-confirmation
+-- waits from here rather than from created_at.
+ALTER TABLE settlements ADD COLUMN pending_since TIMESTAMPTZ;
+UPDATE settlements SET pending_since = created_at WHERE status = 'pending';
+
+DROP INDEX IF EXISTS settlements_pending_idx;
+CREATE INDEX IF NOT EXISTS settlements_pending_idx ON settlements (pending_since) WHERE status = 'pending';
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// UUIDs passes a list of IDs as a Postgres uuid[] parameter, for queries like
+// `WHERE id = ANY($1::uuid[])`. It is sent as an array literal so it works
+// with any driver.
+type UUIDs []uuid.UUID
+
+// Value formats the IDs as an array literal such as {id1,id2}.
+func (ids UUIDs) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(id.String())
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
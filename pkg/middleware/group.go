@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+	"github.com/google/uuid"
+)
+
+const GroupRoleKey contextKey = "groupRole"
+
+// MembershipChecker looks up a user's role in a group. It returns
+// sql.ErrNoRows when the user is not a member.
+type MembershipChecker interface {
+	GetMemberRole(groupID, userID uuid.UUID) (models.Role, error)
+}
+
+// GroupMemberRequired only lets the request through when the logged-in user
+// is a member of the group in the {id} path segment, and stores their role in
+// the request context. It must run after AuthRequired. Non-members get the
+// same 404 as a missing group so group IDs cannot be probed.
+func GroupMemberRequired(checker MembershipChecker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		userID, err := uuid.Parse(GetUserID(r))
+		if err != nil {
+			http.Error(w, "invalid user ID in token", http.StatusUnauthorized)
+			return
+		}
+
+		groupID, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		role, err := checker.GetMemberRole(groupID, userID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), GroupRoleKey, role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Helper — call this in group-scoped handlers to get the caller's role
+func GetGroupRole(r *http.Request) models.Role {
+	role, _ := r.Context().Value(GroupRoleKey).(models.Role)
+	return role
+}
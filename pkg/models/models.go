@@ -1,11 +1,15 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DateLayout is the format of calendar dates in the API.
+const DateLayout = "2006-01-02"
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
@@ -15,45 +19,491 @@ type User struct {
 }
 
 type Group struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	CreatedBy uuid.UUID `json:"created_by"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Currency string    `json:"currency" example:"EUR"`
+	DebtMode DebtMode  `json:"debt_mode"`
+	// LockedUntil is the last day of the locked period: expenses incurred on
+	// or before it can't be added, changed or deleted.
+	LockedUntil string    `json:"locked_until,omitempty" example:"2024-03-31"`
+	CreatedBy   uuid.UUID `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type GroupMember struct {
 	ID       uuid.UUID `json:"id"`
 	GroupID  uuid.UUID `json:"group_id"`
 	UserID   uuid.UUID `json:"user_id"`
+	Role     Role      `json:"role"`
 	JoinedAt time.Time `json:"joined_at"`
 }
 
 type Expense struct {
-	ID          uuid.UUID `json:"id"`
-	GroupID     uuid.UUID `json:"group_id"`
-	PaidBy      uuid.UUID `json:"paid_by"`
-	Description string    `json:"description"`
-	Amount      float64   `json:"amount"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID      uuid.UUID `json:"id"`
+	GroupID uuid.UUID `json:"group_id"`
+	// PaidBy is the payer who paid the largest part; Payers lists everyone.
+	PaidBy      uuid.UUID      `json:"paid_by"`
+	Payers      []ExpensePayer `json:"payers"`
+	Description string         `json:"description"`
+	Amount      Money          `json:"amount" swaggertype:"number"`
+	Currency    string         `json:"currency" example:"EUR"`
+	SplitType   SplitType      `json:"split_type"`
+	// IncurredOn is the day the expense was incurred, in TimeZone when set.
+	IncurredOn string `json:"incurred_on" example:"2024-01-02"`
+	TimeZone   string `json:"time_zone,omitempty" example:"Europe/Lisbon"`
+	// CategoryID is set by hand or by the group's category rules.
+	CategoryID *uuid.UUID `json:"category_id"`
+	Tags       []Tag      `json:"tags"`
+	// Receipt is the item breakdown of an itemized expense.
+	Receipt   *Receipt  `json:"receipt,omitempty"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// DeletedAt and DeletedBy are only set on expenses in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID `json:"deleted_by,omitempty"`
+}
+
+// Tag is a free-form label on expenses and settlements, such as the
+// sub-event they belong to.
+type Tag struct {
+	ID        uuid.UUID `json:"id"`
+	GroupID   uuid.UUID `json:"group_id"`
+	Name      string    `json:"name" example:"lisbon-trip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Category classifies expenses. System categories have no GroupID and are
+// available in every group.
+type Category struct {
+	ID        uuid.UUID  `json:"id"`
+	GroupID   *uuid.UUID `json:"group_id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CategoryRule assigns CategoryID to expenses for which every condition it
+// sets holds: Pattern is found in the description ignoring case, PaidBy is
+// one of the payers, and the amount is within MinAmount and MaxAmount in the
+// expense's own currency. Rules are tried by ascending Position.
+type CategoryRule struct {
+	ID         uuid.UUID  `json:"id"`
+	GroupID    uuid.UUID  `json:"group_id"`
+	CategoryID uuid.UUID  `json:"category_id"`
+	Position   int        `json:"position"`
+	Pattern    string     `json:"pattern,omitempty" example:"supermarket"`
+	PaidBy     *uuid.UUID `json:"paid_by,omitempty"`
+	MinAmount  *Money     `json:"min_amount,omitempty" swaggertype:"number"`
+	MaxAmount  *Money     `json:"max_amount,omitempty" swaggertype:"number"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Receipt lists the items of an itemized expense. Tax, service charge and tip
+// are shared in proportion to each member's item subtotal.
+type Receipt struct {
+	Items         []ExpenseItem `json:"items"`
+	Tax           Money         `json:"tax" swaggertype:"number"`
+	ServiceCharge Money         `json:"service_charge" swaggertype:"number"`
+	Tip           Money         `json:"tip" swaggertype:"number"`
+}
+
+// ExpenseItem is a receipt line and what each member assigned to it pays.
+type ExpenseItem struct {
+	ID          uuid.UUID   `json:"id"`
+	Description string      `json:"description"`
+	Price       Money       `json:"price" swaggertype:"number"`
+	Shares      []ItemShare `json:"shares"`
+}
+
+// ItemShare is one member's part of a receipt item.
+type ItemShare struct {
+	UserID uuid.UUID `json:"user_id"`
+	Amount Money     `json:"amount" swaggertype:"number"`
+}
+
+// ExpenseDetail is an expense together with its splits, as returned when
+// expenses are read. Payers and splits carry the users' names.
+type ExpenseDetail struct {
+	Expense
+	Splits []ExpenseSplit `json:"splits"`
+}
+
+// ExpensePage is one page of a group's expenses. NextCursor is empty on the
+// last page.
+type ExpensePage struct {
+	Expenses   []ExpenseDetail `json:"expenses"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ExpensePayer is how much of an expense one user paid. Name is only set
+// when the expense is read.
+type ExpensePayer struct {
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name,omitempty"`
+	Amount Money     `json:"amount" swaggertype:"number"`
 }
 
 type ExpenseSplit struct {
 	ID        uuid.UUID `json:"id"`
 	ExpenseID uuid.UUID `json:"expense_id"`
 	UserID    uuid.UUID `json:"user_id"`
-	Amount    float64   `json:"amount"`
+	Name      string    `json:"name,omitempty"`
+	Amount    Money     `json:"amount" swaggertype:"number"`
+	// Share is the percentage, number of shares or fixed adjustment amount
+	// the split was computed from; nil for exact and equal splits.
+	Share *Weight `json:"share,omitempty" swaggertype:"number"`
+}
+
+// ExpenseRevision is an expense as one create, update or revert saved it.
+// Changes lists what differs from the previous revision and is empty for the
+// first one.
+type ExpenseRevision struct {
+	ExpenseID  uuid.UUID `json:"expense_id"`
+	Revision   int       `json:"revision" example:"2"`
+	EditedBy   uuid.UUID `json:"edited_by"`
+	EditorName string    `json:"editor_name"`
+	// RevertedFrom is the revision this one reverted the expense to.
+	RevertedFrom *int            `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Snapshot     ExpenseSnapshot `json:"snapshot"`
+	Changes      []FieldChange   `json:"changes"`
+}
+
+// ExpenseSnapshot is the state of an expense kept by a revision.
+type ExpenseSnapshot struct {
+	Description string          `json:"description"`
+	Amount      Money           `json:"amount" swaggertype:"number"`
+	Currency    string          `json:"currency" example:"EUR"`
+	SplitType   SplitType       `json:"split_type"`
+	IncurredOn  string          `json:"incurred_on" example:"2024-01-02"`
+	TimeZone    string          `json:"time_zone,omitempty" example:"Europe/Lisbon"`
+	CategoryID  *uuid.UUID      `json:"category_id"`
+	TagIDs      []uuid.UUID     `json:"tag_ids"`
+	Payers      []ExpensePayer  `json:"payers"`
+	Splits      []SplitSnapshot `json:"splits"`
+	Receipt     *Receipt        `json:"receipt,omitempty"`
+}
+
+// SplitSnapshot is one participant's split as kept by a revision.
+type SplitSnapshot struct {
+	UserID uuid.UUID `json:"user_id"`
+	Amount Money     `json:"amount" swaggertype:"number"`
+	Share  *Weight   `json:"share,omitempty" swaggertype:"number"`
+}
+
+// FieldChange is one field that differs between two revisions. Payers and
+// splits change per user: UserID is set and From or To is null when the
+// user was added or removed.
+type FieldChange struct {
+	Field  string     `json:"field" example:"amount"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	From   any        `json:"from"`
+	To     any        `json:"to"`
 }
 
 type Settlement struct {
+	ID       uuid.UUID `json:"id"`
+	GroupID  uuid.UUID `json:"group_id"`
+	PaidBy   uuid.UUID `json:"paid_by"`
+	PaidTo   uuid.UUID `json:"paid_to"`
+	Amount   Money     `json:"amount" swaggertype:"number"`
+	Currency string    `json:"currency" example:"EUR"`
+	Tags     []Tag     `json:"tags"`
+	// Allocations are the expenses the settlement pays off, oldest first.
+	Allocations []Allocation `json:"allocations"`
+	CreatedAt   time.Time    `json:"created_at"`
+	// Status is pending until the payee confirms or rejects the settlement,
+	// or the payer cancels it. Only confirmed settlements count in balances.
+	Status SettlementStatus `json:"status" example:"pending"`
+	// RejectionReason is the payee's reason for rejecting the settlement.
+	RejectionReason string `json:"rejection_reason,omitempty"`
+	// ResolvedAt and ResolvedBy are set once the settlement is no longer
+	// pending. ResolvedBy is empty when it was confirmed automatically.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+	// DeletedAt and DeletedBy are only set on settlements in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID `json:"deleted_by,omitempty"`
+}
+
+// Allocation is the part of a settlement that pays off the payer's share of
+// an expense the payee paid.
+type Allocation struct {
+	ExpenseID uuid.UUID `json:"expense_id"`
+	Amount    Money     `json:"amount" swaggertype:"number"`
+}
+
+// SplitDebt is what DebtorID owes CreditorID for their split of an expense.
+// Paid and Pending are the parts confirmed and pending settlements were
+// allocated to, and Outstanding what confirmed settlements leave to pay.
+type SplitDebt struct {
+	DebtorID    uuid.UUID `json:"debtor_id"`
+	CreditorID  uuid.UUID `json:"creditor_id"`
+	Owed        Money     `json:"owed" swaggertype:"number"`
+	Paid        Money     `json:"paid" swaggertype:"number"`
+	Pending     Money     `json:"pending" swaggertype:"number"`
+	Outstanding Money     `json:"outstanding" swaggertype:"number"`
+	Settled     bool      `json:"settled"`
+}
+
+// ExpenseSettlement is how far the debts of an expense have been paid off.
+// SettledForMe is set when nothing is outstanding on the debts the reader
+// owes or is owed.
+type ExpenseSettlement struct {
+	ExpenseID    uuid.UUID   `json:"expense_id"`
+	Description  string      `json:"description"`
+	IncurredOn   string      `json:"incurred_on" example:"2024-01-02"`
+	Currency     string      `json:"currency" example:"EUR"`
+	Debts        []SplitDebt `json:"debts"`
+	SettledForMe bool        `json:"settled_for_me"`
+}
+
+// SettlementStatus is where a settlement is in its confirmation.
+type SettlementStatus string
+
+const (
+	SettlementPending   SettlementStatus = "pending"
+	SettlementConfirmed SettlementStatus = "confirmed"
+	SettlementRejected  SettlementStatus = "rejected"
+	SettlementCancelled SettlementStatus = "cancelled"
+)
+
+// Valid reports whether s is one of the known settlement statuses.
+func (s SettlementStatus) Valid() bool {
+	switch s {
+	case SettlementPending, SettlementConfirmed, SettlementRejected, SettlementCancelled:
+		return true
+	}
+	return false
+}
+
+// Trash is what was deleted in a group and can still be restored, most
+// recently deleted first. Items are purged RetentionDays after deletion.
+type Trash struct {
+	Expenses      []ExpenseDetail `json:"expenses"`
+	Settlements   []Settlement    `json:"settlements"`
+	RetentionDays int             `json:"retention_days" example:"30"`
+}
+
+// Comment is a message on an expense or a settlement. Mentions are the
+// members it @mentions.
+type Comment struct {
+	ID           uuid.UUID   `json:"id"`
+	GroupID      uuid.UUID   `json:"group_id"`
+	ExpenseID    *uuid.UUID  `json:"expense_id,omitempty"`
+	SettlementID *uuid.UUID  `json:"settlement_id,omitempty"`
+	AuthorID     uuid.UUID   `json:"author_id"`
+	AuthorName   string      `json:"author_name"`
+	Body         string      `json:"body"`
+	Mentions     []uuid.UUID `json:"mentions"`
+	CreatedAt    time.Time   `json:"created_at"`
+	EditedAt     *time.Time  `json:"edited_at,omitempty"`
+}
+
+// CommentPage is one page of a thread, oldest first. NextCursor is empty on
+// the last page.
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Attachment is a file attached to an expense, such as a receipt. URL and
+// ThumbnailURL are signed download links that expire after a while; images
+// in formats the server can decode have a thumbnail.
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	GroupID      uuid.UUID `json:"group_id"`
+	ExpenseID    uuid.UUID `json:"expense_id"`
+	FileName     string    `json:"file_name" example:"receipt.jpg"`
+	ContentType  string    `json:"content_type" example:"image/jpeg"`
+	Size         int64     `json:"size" example:"482113"`
+	UploadedBy   uuid.UUID `json:"uploaded_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+}
+
+type Balance struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Balance is converted into the group's currency.
+	Balance  Money  `json:"balance" swaggertype:"number"`
+	Currency string `json:"currency" example:"EUR"`
+	// ByCurrency is the unconverted balance in each currency the user's
+	// expenses and settlements were recorded in.
+	ByCurrency []CurrencyBalance `json:"by_currency"`
+}
+
+type CurrencyBalance struct {
+	Currency string `json:"currency" example:"GBP"`
+	Balance  Money  `json:"balance" swaggertype:"number"`
+}
+
+// PairBalance is what UserID and OtherUserID owe each other, seen from
+// UserID: positive when OtherUserID owes UserID. The IDs list the expenses
+// and settlements between the two that make up the balance.
+type PairBalance struct {
+	UserID        uuid.UUID   `json:"user_id"`
+	OtherUserID   uuid.UUID   `json:"other_user_id"`
+	Balance       Money       `json:"balance" swaggertype:"number"`
+	Currency      string      `json:"currency" example:"EUR"`
+	ExpenseIDs    []uuid.UUID `json:"expense_ids"`
+	SettlementIDs []uuid.UUID `json:"settlement_ids"`
+}
+
+// DebtMode chooses how a group's balances are turned into transfers.
+type DebtMode string
+
+const (
+	// DebtModeSimplified settles net balances with as few transfers as possible.
+	DebtModeSimplified DebtMode = "simplified"
+	// DebtModePairwise keeps debts between the people who shared expenses.
+	DebtModePairwise DebtMode = "pairwise"
+)
+
+// Valid reports whether m is one of the known debt modes.
+func (m DebtMode) Valid() bool {
+	return m == DebtModeSimplified || m == DebtModePairwise
+}
+
+// Transfer is a payment that settles part of the group's debts.
+type Transfer struct {
+	From   uuid.UUID `json:"from"`
+	To     uuid.UUID `json:"to"`
+	Amount Money     `json:"amount" swaggertype:"number"`
+}
+
+// DebtPlan is the set of transfers that settles every balance in a group,
+// in the group's currency.
+type DebtPlan struct {
+	Mode      DebtMode   `json:"mode"`
+	Currency  string     `json:"currency" example:"EUR"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+// SettleUp is the transfers that settle a group, or the caller's part of
+// them, and the settlements recording them unless it was a dry run.
+type SettleUp struct {
+	DryRun      bool         `json:"dry_run"`
+	Mode        DebtMode     `json:"mode"`
+	Currency    string       `json:"currency" example:"EUR"`
+	Transfers   []Transfer   `json:"transfers"`
+	Settlements []Settlement `json:"settlements"`
+}
+
+// ExchangeRate says how many units of Quote one unit of Base was worth on Date.
+type ExchangeRate struct {
 	ID        uuid.UUID `json:"id"`
 	GroupID   uuid.UUID `json:"group_id"`
-	PaidBy    uuid.UUID `json:"paid_by"`
-	PaidTo    uuid.UUID `json:"paid_to"`
-	Amount    float64   `json:"amount"`
+	Base      string    `json:"base" example:"EUR"`
+	Quote     string    `json:"quote" example:"CHF"`
+	Date      string    `json:"date" example:"2024-01-02"`
+	Rate      string    `json:"rate" example:"0.9312"`
+	Source    string    `json:"source" example:"manual"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type Balance struct {
-	UserID  uuid.UUID `json:"user_id"`
-	Balance float64   `json:"balance"`
+// UserSummary is a user's position across every group they have expenses
+// or settlements in. Groups keep their own currencies and exchange rates, so
+// nothing is converted: every amount is reported in the currency it was
+// recorded in.
+type UserSummary struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Totals is the user's net balance in each currency; positive when the
+	// user is owed money.
+	Totals []CurrencyBalance `json:"totals"`
+	// Counterparts is what each other user owes the user (or is owed by
+	// them) across all groups; settled pairs are left out.
+	Counterparts []CounterpartBalance `json:"counterparts"`
+	// OutstandingGroups lists the groups where the user's balance in some
+	// currency is not zero.
+	OutstandingGroups []GroupBalance `json:"outstanding_groups"`
+	// RecentActivity is the newest expenses and settlements involving the
+	// user, newest first.
+	RecentActivity []ActivityItem `json:"recent_activity"`
+}
+
+// CounterpartBalance is positive when UserID owes the summarised user.
+type CounterpartBalance struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Name     string    `json:"name"`
+	Balance  Money     `json:"balance" swaggertype:"number"`
+	Currency string    `json:"currency" example:"EUR"`
+}
+
+// GroupBalance is a user's net balance in one group and currency.
+type GroupBalance struct {
+	GroupID   uuid.UUID `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	Balance   Money     `json:"balance" swaggertype:"number"`
+	Currency  string    `json:"currency" example:"EUR"`
+}
+
+// ActivityKind says what an ActivityItem refers to.
+type ActivityKind string
+
+const (
+	ActivityExpense    ActivityKind = "expense"
+	ActivitySettlement ActivityKind = "settlement"
+	ActivityGroup      ActivityKind = "group"
+	ActivityMember     ActivityKind = "member"
+)
+
+// ActivityVerb is what the actor of an activity entry did.
+type ActivityVerb string
+
+const (
+	VerbCreated  ActivityVerb = "created"
+	VerbUpdated  ActivityVerb = "updated"
+	VerbDeleted  ActivityVerb = "deleted"
+	VerbRestored ActivityVerb = "restored"
+	VerbReverted ActivityVerb = "reverted"
+	VerbAdded    ActivityVerb = "added"
+	VerbRemoved  ActivityVerb = "removed"
+	// settlements only
+	VerbConfirmed ActivityVerb = "confirmed"
+	VerbRejected  ActivityVerb = "rejected"
+	VerbCancelled ActivityVerb = "cancelled"
+)
+
+// ActivityEntry records that ActorID did Verb to an object of a group: an
+// expense, a settlement, the group itself or one of its members, whose user
+// ID is then the ObjectID. Before and After summarise the object around the
+// change and are null when it didn't exist. Unread is relative to the
+// reader; their own entries are never unread.
+type ActivityEntry struct {
+	ID         uuid.UUID       `json:"id"`
+	GroupID    uuid.UUID       `json:"group_id"`
+	GroupName  string          `json:"group_name"`
+	ActorID    uuid.UUID       `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	Verb       ActivityVerb    `json:"verb" example:"created"`
+	ObjectType ActivityKind    `json:"object_type" example:"expense"`
+	ObjectID   uuid.UUID       `json:"object_id"`
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Unread     bool            `json:"unread"`
+}
+
+// ActivityPage is one page of activity, newest first. Unread counts the
+// reader's unread entries in the whole feed. NextCursor is empty on the last
+// page.
+type ActivityPage struct {
+	Activity   []ActivityEntry `json:"activity"`
+	Unread     int             `json:"unread"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ActivityItem is an expense or settlement shown in a user's summary.
+// Description is empty and PaidTo is set for settlements.
+type ActivityItem struct {
+	Kind        ActivityKind `json:"kind"`
+	ID          uuid.UUID    `json:"id"`
+	GroupID     uuid.UUID    `json:"group_id"`
+	GroupName   string       `json:"group_name"`
+	Description string       `json:"description,omitempty"`
+	PaidBy      uuid.UUID    `json:"paid_by"`
+	PaidTo      *uuid.UUID   `json:"paid_to,omitempty"`
+	Amount      Money        `json:"amount" swaggertype:"number"`
+	Currency    string       `json:"currency" example:"EUR"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// MinorDigits is the number of decimal places stored for every amount. It
+// matches the DECIMAL(10,2) columns in the schema.
+const MinorDigits = 2
+
+const minorPerMajor = 100
+
+// Money is an exact amount stored as an integer number of minor units
+// (cents) plus an ISO 4217 currency code. Arithmetic never goes through
+// floating point, so totals cannot drift.
+//
+// On the wire Money is a plain JSON number with two decimals ("12.30"), and
+// in SQL it maps to a DECIMAL column. The currency travels separately.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// DefaultCurrency is used for groups created without a currency.
+const DefaultCurrency = "EUR"
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+var ErrInvalidCurrency = errors.New("invalid currency code")
+
+// NewMoney builds an amount from minor units.
+func NewMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseCurrency normalizes a three-letter ISO 4217 code such as "chf" to "CHF".
+func ParseCurrency(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 3 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, s)
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, s)
+		}
+	}
+	return s, nil
+}
+
+// ParseMoney parses a decimal string such as "12", "12.5" or "-0.05".
+// More than two decimal places are rejected unless they are zeros.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, ErrInvalidMoney
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return Money{}, ErrInvalidMoney
+	}
+	if len(frac) > MinorDigits {
+		if strings.TrimRight(frac[MinorDigits:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidMoney, s, MinorDigits)
+		}
+		frac = frac[:MinorDigits]
+	}
+	frac += strings.Repeat("0", MinorDigits-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	for _, part := range []string{whole, frac} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+			}
+		}
+	}
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor}, nil
+}
+
+// String formats the amount with exactly two decimals, without the currency.
+func (m Money) String() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Add returns m + o. An empty currency adopts the other operand's currency;
+// mixing two different currencies is a programming error and panics.
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: sameCurrency(m, o)}
+}
+
+// Sub returns m - o with the same currency rules as Add.
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: sameCurrency(m, o)}
+}
+
+func sameCurrency(a, b Money) string {
+	switch {
+	case a.Currency == "":
+		return b.Currency
+	case b.Currency == "" || a.Currency == b.Currency:
+		return a.Currency
+	}
+	panic(fmt.Sprintf("models: mixing currencies %s and %s", a.Currency, b.Currency))
+}
+
+// Allocate splits m into len(weights) parts proportional to the weights. The
+// parts always sum to m exactly: each part is rounded down and the leftover
+// minor units go one by one to the largest remainders, ties going to the
+// lower index. Callers that need a stable order sort their inputs first. It
+// returns an error when a weight is negative, or the weights are all zero or
+// add up to more than an int64 holds.
+func (m Money) Allocate(weights []int64) ([]Money, error) {
+	var total int64
+	for _, w := range weights {
+		if w < 0 {
+			return nil, errors.New("allocation weights must not be negative")
+		}
+		if w > math.MaxInt64-total {
+			return nil, errors.New("allocation weights are too large")
+		}
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("allocation weights must not all be zero")
+	}
+
+	amount := m.Minor
+	if amount < 0 {
+		amount = -amount
+	}
+
+	parts := make([]Money, len(weights))
+	remainders := make([]int64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		// amount*w is computed in 128 bits; the quotient fits since w <= total
+		hi, lo := bits.Mul64(uint64(amount), uint64(w))
+		quo, rem := bits.Div64(hi, lo, uint64(total))
+		parts[i] = Money{Minor: int64(quo), Currency: m.Currency}
+		remainders[i] = int64(rem)
+		allocated += parts[i].Minor
+	}
+
+	for left := amount - allocated; left > 0; left-- {
+		best := -1
+		for i, r := range remainders {
+			if weights[i] > 0 && (best == -1 || r > remainders[best]) {
+				best = i
+			}
+		}
+		parts[best].Minor++
+		remainders[best] = -1
+	}
+
+	if m.Minor < 0 {
+		for i := range parts {
+			parts[i].Minor = -parts[i].Minor
+		}
+	}
+	return parts, nil
+}
+
+// Split divides m into n equal parts, see Allocate.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, errors.New("cannot split into zero parts")
+	}
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return m.Allocate(weights)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. The currency is
+// left untouched.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponents are not supported", ErrInvalidMoney)
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	m.Minor = parsed.Minor
+	return nil
+}
+
+// Scan reads a DECIMAL column. The currency is left untouched.
+func (m *Money) Scan(src any) error {
+	var parsed Money
+	var err error
+	switch v := src.(type) {
+	case string:
+		parsed, err = ParseMoney(v)
+	case []byte:
+		parsed, err = ParseMoney(string(v))
+	case int64:
+		parsed = Money{Minor: v * minorPerMajor}
+	case float64:
+		parsed, err = ParseMoney(strconv.FormatFloat(v, 'f', MinorDigits, 64))
+	case nil:
+		return fmt.Errorf("%w: NULL", ErrInvalidMoney)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidMoney, src)
+	}
+	if err != nil {
+		return err
+	}
+	m.Minor = parsed.Minor
+	return nil
+}
+
+// Value writes the amount as a decimal string for DECIMAL columns.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
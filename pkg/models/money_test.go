@@ -0,0 +1,213 @@
+package models_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.3", 1230},
+		{"12.34", 1234},
+		{"12.3400", 1234},
+		{"-0.05", -5},
+		{".5", 50},
+		{"+7.10", 710},
+	}
+
+	for _, tt := range tests {
+		got, err := models.ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): unexpected error %s", tt.in, err)
+			continue
+		}
+		if got.Minor != tt.want {
+			t.Errorf("ParseMoney(%q): expected %d minor units, got %d", tt.in, tt.want, got.Minor)
+		}
+	}
+
+	for _, in := range []string{"", "-", "12.345", "1,50", "abc", "1.2.3", "99999999999999999999"} {
+		if _, err := models.ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q): expected an error, got nil", in)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1230, "12.30"},
+		{-4500, "-45.00"},
+		{-1, "-0.01"},
+	}
+
+	for _, tt := range tests {
+		if got := models.NewMoney(tt.minor, "EUR").String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String(): expected %s, got %s", tt.minor, tt.want, got)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var body struct {
+		Amount models.Money `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 10.1}`), &body); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if body.Amount.Minor != 1010 {
+		t.Errorf("expected 1010 minor units, got %d", body.Amount.Minor)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": "3.33"}`), &body); err != nil {
+		t.Fatalf("failed to unmarshal string amount: %s", err)
+	}
+	if body.Amount.Minor != 333 {
+		t.Errorf("expected 333 minor units, got %d", body.Amount.Minor)
+	}
+
+	for _, in := range []string{`{"amount": 10.001}`, `{"amount": 1e3}`, `{"amount": true}`} {
+		if err := json.Unmarshal([]byte(in), &body); err == nil {
+			t.Errorf("expected error unmarshalling %s, got nil", in)
+		}
+	}
+
+	out, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	if string(out) != `{"amount":3.33}` {
+		t.Errorf("expected {\"amount\":3.33}, got %s", out)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want int64
+	}{
+		{"90.00", 9000},
+		{[]byte("-45.50"), -4550},
+		{int64(3), 300},
+		{float64(0.1) + float64(0.2), 30},
+	}
+
+	for _, tt := range tests {
+		var m models.Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v): unexpected error %s", tt.src, err)
+			continue
+		}
+		if m.Minor != tt.want {
+			t.Errorf("Scan(%v): expected %d minor units, got %d", tt.src, tt.want, m.Minor)
+		}
+	}
+
+	var m models.Money
+	if err := m.Scan(nil); err == nil {
+		t.Errorf("expected error scanning NULL, got nil")
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		minor   int64
+		weights []int64
+		want    []int64
+	}{
+		{"even", 900, []int64{1, 1, 1}, []int64{300, 300, 300}},
+		{"penny to first", 1000, []int64{1, 1, 1}, []int64{334, 333, 333}},
+		{"two pennies", 1001, []int64{1, 1, 1}, []int64{334, 334, 333}},
+		{"largest remainder", 100, []int64{1, 2, 3}, []int64{17, 33, 50}},
+		{"percentages", 1999, []int64{5000, 3000, 2000}, []int64{999, 600, 400}},
+		{"zero weight", 500, []int64{0, 1}, []int64{0, 500}},
+		{"negative", -1000, []int64{1, 1, 1}, []int64{-334, -333, -333}},
+		{"large weights", 9999999999, []int64{4000000000, 5999999999}, []int64{4000000000, 5999999999}},
+		{"weights up to the int64 limit", 1000, []int64{math.MaxInt64 - 1, 1}, []int64{1000, 0}},
+	}
+
+	for _, tt := range tests {
+		parts, err := models.NewMoney(tt.minor, "EUR").Allocate(tt.weights)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		var sum int64
+		for i, p := range parts {
+			sum += p.Minor
+			if p.Minor != tt.want[i] {
+				t.Errorf("%s: part %d expected %d, got %d", tt.name, i, tt.want[i], p.Minor)
+			}
+			if p.Currency != "EUR" {
+				t.Errorf("%s: part %d expected currency EUR, got %s", tt.name, i, p.Currency)
+			}
+		}
+		if sum != tt.minor {
+			t.Errorf("%s: parts sum to %d, expected %d", tt.name, sum, tt.minor)
+		}
+	}
+
+	if _, err := models.NewMoney(100, "").Allocate([]int64{0, 0}); err == nil {
+		t.Errorf("expected error for all-zero weights, got nil")
+	}
+	if _, err := models.NewMoney(100, "").Allocate([]int64{1, -1}); err == nil {
+		t.Errorf("expected error for negative weights, got nil")
+	}
+
+	overflowing := []struct {
+		name    string
+		weights []int64
+	}{
+		{"sum wraps", []int64{1 << 62, 1 << 62, 1 << 62, 1<<62 + 5}},
+		{"sum just past max", []int64{math.MaxInt64, 1}},
+		{"two halves", []int64{1 << 62, 1 << 62}},
+	}
+	for _, tt := range overflowing {
+		if _, err := models.NewMoney(100, "").Allocate(tt.weights); err == nil {
+			t.Errorf("%s: expected error for overflowing weights, got nil", tt.name)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	sum := models.NewMoney(1050, "").Add(models.NewMoney(250, "EUR"))
+	if sum.Minor != 1300 || sum.Currency != "EUR" {
+		t.Errorf("expected 13.00 EUR, got %s %s", sum, sum.Currency)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic when mixing currencies")
+		}
+	}()
+	models.NewMoney(100, "EUR").Add(models.NewMoney(100, "GBP"))
+}
+
+func TestParseCurrency(t *testing.T) {
+	got, err := models.ParseCurrency(" chf")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "CHF" {
+		t.Errorf("expected CHF, got %s", got)
+	}
+
+	for _, in := range []string{"", "EU", "EURO", "E1R", "€UR"} {
+		if _, err := models.ParseCurrency(in); err == nil {
+			t.Errorf("ParseCurrency(%q): expected an error, got nil", in)
+		}
+	}
+}
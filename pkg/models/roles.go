@@ -0,0 +1,70 @@
+package models
+
+// Role is a member's role inside a group.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+// Permission is an action on a group that depends on the member's role.
+type Permission string
+
+const (
+	PermissionEditGroup         Permission = "edit_group"
+	PermissionDeleteGroup       Permission = "delete_group"
+	PermissionTransferOwnership Permission = "transfer_ownership"
+	PermissionManageMembers     Permission = "manage_members"
+	PermissionManageAdmins      Permission = "manage_admins"
+	PermissionAddExpense        Permission = "add_expense"
+	PermissionEditAnyExpense    Permission = "edit_any_expense"
+	PermissionRecordForOthers   Permission = "record_for_others"
+	PermissionRecordSettlement  Permission = "record_settlement"
+	PermissionComment           Permission = "comment"
+)
+
+// permissions is the role/permission matrix. Viewers can only read.
+var permissions = map[Role]map[Permission]bool{
+	RoleOwner: {
+		PermissionEditGroup:         true,
+		PermissionDeleteGroup:       true,
+		PermissionTransferOwnership: true,
+		PermissionManageMembers:     true,
+		PermissionManageAdmins:      true,
+		PermissionAddExpense:        true,
+		PermissionEditAnyExpense:    true,
+		PermissionRecordForOthers:   true,
+		PermissionRecordSettlement:  true,
+		PermissionComment:           true,
+	},
+	RoleAdmin: {
+		PermissionEditGroup:        true,
+		PermissionManageMembers:    true,
+		PermissionAddExpense:       true,
+		PermissionEditAnyExpense:   true,
+		PermissionRecordForOthers:  true,
+		PermissionRecordSettlement: true,
+		PermissionComment:          true,
+	},
+	RoleMember: {
+		PermissionAddExpense:       true,
+		PermissionRecordForOthers:  true,
+		PermissionRecordSettlement: true,
+		PermissionComment:          true,
+	},
+	RoleViewer: {},
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	_, ok := permissions[r]
+	return ok
+}
+
+// Can reports whether a member with role r is allowed to perform p.
+func (r Role) Can(p Permission) bool {
+	return permissions[r][p]
+}
@@ -0,0 +1,49 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/IvanLouren/GoSplit/pkg/models"
+)
+
+func TestRoleCan(t *testing.T) {
+	tests := []struct {
+		role       models.Role
+		permission models.Permission
+		want       bool
+	}{
+		{models.RoleOwner, models.PermissionDeleteGroup, true},
+		{models.RoleOwner, models.PermissionManageAdmins, true},
+		{models.RoleAdmin, models.PermissionEditGroup, true},
+		{models.RoleAdmin, models.PermissionManageMembers, true},
+		{models.RoleAdmin, models.PermissionDeleteGroup, false},
+		{models.RoleAdmin, models.PermissionManageAdmins, false},
+		{models.RoleMember, models.PermissionAddExpense, true},
+		{models.RoleMember, models.PermissionEditAnyExpense, false},
+		{models.RoleMember, models.PermissionManageMembers, false},
+		{models.RoleViewer, models.PermissionAddExpense, false},
+		{models.RoleViewer, models.PermissionRecordSettlement, false},
+		{models.RoleMember, models.PermissionRecordForOthers, true},
+		{models.RoleViewer, models.PermissionRecordForOthers, false},
+		{models.RoleMember, models.PermissionComment, true},
+		{models.RoleViewer, models.PermissionComment, false},
+		{models.Role("stranger"), models.PermissionAddExpense, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.permission); got != tt.want {
+			t.Errorf("%s.Can(%s): expected %t, got %t", tt.role, tt.permission, tt.want, got)
+		}
+	}
+}
+
+func TestRoleValid(t *testing.T) {
+	for _, role := range []models.Role{models.RoleOwner, models.RoleAdmin, models.RoleMember, models.RoleViewer} {
+		if !role.Valid() {
+			t.Errorf("expected %s to be valid", role)
+		}
+	}
+	if models.Role("superuser").Valid() {
+		t.Errorf("expected unknown role to be invalid")
+	}
+}
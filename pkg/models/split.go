@@ -0,0 +1,69 @@
+package models
+
+import "database/sql/driver"
+
+// SplitType is the strategy used to divide an expense between participants.
+type SplitType string
+
+const (
+	// SplitExact takes every participant's amount as given.
+	SplitExact SplitType = "exact"
+	// SplitEqual divides the total equally among the participants.
+	SplitEqual SplitType = "equal"
+	// SplitPercentage divides the total by percentages adding up to 100.
+	SplitPercentage SplitType = "percentage"
+	// SplitShares divides the total proportionally to each participant's shares.
+	SplitShares SplitType = "shares"
+	// SplitAdjustment charges participants with an amount exactly that amount
+	// and divides the remainder equally among the others.
+	SplitAdjustment SplitType = "adjustment"
+	// SplitItemized charges members for the receipt items assigned to them,
+	// plus their proportional part of tax, service charge and tip.
+	SplitItemized SplitType = "itemized"
+)
+
+// Valid reports whether t is one of the known split strategies.
+func (t SplitType) Valid() bool {
+	switch t {
+	case SplitExact, SplitEqual, SplitPercentage, SplitShares, SplitAdjustment, SplitItemized:
+		return true
+	}
+	return false
+}
+
+// Weight is a percentage or a number of shares with two decimals, stored in
+// hundredths. It shares Money's wire and SQL format.
+type Weight int64
+
+// MaxWeight is the largest Weight the DECIMAL(12,2) share columns hold.
+const MaxWeight Weight = 999999999999
+
+func (w Weight) String() string {
+	return Money{Minor: int64(w)}.String()
+}
+
+func (w Weight) MarshalJSON() ([]byte, error) {
+	return Money{Minor: int64(w)}.MarshalJSON()
+}
+
+func (w *Weight) UnmarshalJSON(data []byte) error {
+	var m Money
+	if err := m.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*w = Weight(m.Minor)
+	return nil
+}
+
+func (w *Weight) Scan(src any) error {
+	var m Money
+	if err := m.Scan(src); err != nil {
+		return err
+	}
+	*w = Weight(m.Minor)
+	return nil
+}
+
+func (w Weight) Value() (driver.Value, error) {
+	return w.String(), nil
+}